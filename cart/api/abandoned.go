@@ -0,0 +1,125 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	db "encore.app/cart/db"
+	models "encore.app/cart/models"
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	"encore.dev/beta/errs"
+	"encore.dev/config"
+	"encore.dev/cron"
+)
+
+// ------------------------------------------------------
+// Setup Config
+
+// Config holds the configuration of the cart service, set in config.cue.
+type Config struct {
+	// AbandonedCartIdleHours is the number of hours a cart must sit unchanged
+	// before it is considered abandoned.
+	AbandonedCartIdleHours config.Int
+}
+
+var cfg = config.Load[*Config]()
+
+// ------------------------------------------------------
+// Setup Database
+
+// AbandonedCartsTable instance.
+var AbandonedCartsTable = &db.AbandonedCartsTable{DB: PlamatioDB}
+
+// Default and maximum number of carts in the abandoned cart report.
+const (
+	defaultAbandonedCartReportLimit = 100
+	maxAbandonedCartReportLimit     = 1000
+)
+
+// ------------------------------------------------------
+// Setup Cron Jobs
+
+// Detects the carts left idle past the abandoned cart threshold.
+var _ = cron.NewJob("detect-abandoned-carts", cron.JobConfig{
+	Title:    "Detect abandoned carts",
+	Every:    1 * cron.Hour,
+	Endpoint: DetectAbandonedCarts,
+})
+
+// ------------------------------------------------------
+// Setup API
+
+// POST: /cart/abandoned/detect
+// Finds the carts that have not changed for the configured number of hours and
+// records a cart-abandoned event, with the items left in the cart, in the outbox for
+// each of them. A cart is reported once until it is changed and abandoned again.
+// Called by the abandoned carts cron job.
+//encore:api private method=POST path=/cart/abandoned/detect
+func DetectAbandonedCarts(ctx context.Context) error {
+	now := time.Now()
+	idleSince := now.Add(-time.Duration(cfg.AbandonedCartIdleHours()) * time.Hour)
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Record the newly abandoned carts.
+	events, err := AbandonedCartsTable.DetectTx(ctx, tx, idleSince, now)
+	if err != nil {
+		return err
+	}
+	// Record each abandoned cart, with its items, in the outbox.
+	for _, e := range events {
+		items, err := CartItemsTable.GetCartItemsByUserTx(ctx, tx, e.UserID)
+		if err != nil {
+			return err
+		}
+		e.Items = items.Data
+		if err := enqueueCartAbandonedTx(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	// Commit the transaction.
+	return tx.Commit()
+}
+
+// GET: /cart/abandoned/report
+// Retrieves the carts that have not changed for the configured number of hours, or for
+// idle_hours if given, longest idle first, with their value at current product prices.
+//encore:api auth method=GET path=/cart/abandoned/report
+func GetAbandonedCartReport(ctx context.Context, p *models.AbandonedCartReportParams) (*models.AbandonedCartReport, error) {
+	// Confirm the caller is a support agent.
+	if err := coreutils.RequireRole(coremodels.RoleSupport); err != nil {
+		return nil, err
+	}
+	// Validate the report parameters.
+	idleHours := p.IdleHours
+	if idleHours == 0 {
+		idleHours = cfg.AbandonedCartIdleHours()
+	}
+	if idleHours < 0 {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "idle_hours must be positive",
+		}
+	}
+	limit := p.Limit
+	if limit == 0 {
+		limit = defaultAbandonedCartReportLimit
+	}
+	if limit < 0 || limit > maxAbandonedCartReportLimit {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "limit must be between 1 and 1000",
+		}
+	}
+	// Retrieve the abandoned carts.
+	carts, err := AbandonedCartsTable.GetReport(ctx, time.Now().Add(-time.Duration(idleHours)*time.Hour), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AbandonedCartReport{IdleHours: idleHours, Data: carts}, nil
+}
@@ -3,10 +3,14 @@ package cart
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	coreutils "encore.app/core/utils"
 	db "encore.app/cart/db"
 	models "encore.app/cart/models"
+	productsdb "encore.app/products/db"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
 	"encore.dev/storage/sqldb"
@@ -21,6 +25,9 @@ var PlamatioDB = sqldb.Named("plamatio_db")
 // CartItemsTable instance.
 var CartItemsTable = &db.CartItemsTable{DB: PlamatioDB}
 
+// ProductsTB instance, used to check product stock.
+var ProductsTB = &productsdb.ProductsTB{DB: PlamatioDB}
+
 // ------------------------------------------------------
 // Setup Caching
 
@@ -51,8 +58,11 @@ var CartItemsCacheKeyspace = cache.NewStructKeyspace[string, models.CartItems](C
 func GetCartItem(ctx context.Context, id int) (*models.CartItem, error) {
 	// First, try retrieving the cart item from cache if it exists.
 	c, err := CartItemCacheKeyspace.Get(ctx, id)
-	// if cart item is found (i.e., no error), return it
+	// if cart item is found (i.e., no error), return it if the caller owns it
 	if err == nil {
+		if err := coreutils.RequireUser(c.UserID); err != nil {
+			return nil, err
+		}
 		return &c, nil
 	}
 	// If the cart item is not found in cache, retrieve it from the database.
@@ -60,6 +70,10 @@ func GetCartItem(ctx context.Context, id int) (*models.CartItem, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Confirm the caller owns the cart item.
+	if err := coreutils.RequireUser(r.UserID); err != nil {
+		return nil, err
+	}
 	// Fire go routine to cache the cart item.
 	go func() {
 		// Cache the cart item.
@@ -80,6 +94,10 @@ func GetCartItems(ctx context.Context, user_id string) (*models.CartItems, error
 	if user_id == "" {
 		return nil, errors.New("invalid user_id")
 	}
+	// Confirm the caller owns the cart.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
 	// First, try retrieving all cart items for a user from cache if they exist.
 	c, err := CartItemsCacheKeyspace.Get(ctx, user_id)
 	// if cart items are found (i.e., no error), return them
@@ -107,11 +125,33 @@ func GetCartItems(ctx context.Context, user_id string) (*models.CartItems, error
 // Inserts a cart item into the database.
 //encore:api auth method=POST path=/cart/add
 func AddCartItem(ctx context.Context, newCartItem *models.NewCartItem) (*models.CartItem, error) {
+	// Confirm the caller owns the cart.
+	if err := coreutils.RequireUser(newCartItem.UserID); err != nil {
+		return nil, err
+	}
+	// Confirm there is enough stock for the requested quantity.
+	if err := checkStock(ctx, newCartItem.ProductID, newCartItem.Quantity); err != nil {
+		return nil, err
+	}
+	// Start the transaction, so the change is never made without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 	// Insert the cart item into the database.
-	r, err := CartItemsTable.InsertCartItem(ctx, newCartItem.ProductID, newCartItem.Quantity, newCartItem.UserID)
+	r, err := CartItemsTable.InsertCartItemTx(ctx, tx, newCartItem.ProductID, newCartItem.Quantity, newCartItem.UserID)
 	if err != nil {
 		return nil, err
 	}
+	// Record the cart change in the outbox.
+	if err := enqueueCartChangedTx(ctx, tx, models.CartItemsAdded, r.UserID, r); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	// Fire go routine to invalidate the cache for the user's cart items.
 	go func() {
 		// Invalidate the cache for the user's cart items.
@@ -122,8 +162,6 @@ func AddCartItem(ctx context.Context, newCartItem *models.NewCartItem) (*models.
 		}
 	}()
 
-	// TODO: Send event on Kafka topic for cart items update for the user.
-
 	return r, nil
 }
 
@@ -131,11 +169,34 @@ func AddCartItem(ctx context.Context, newCartItem *models.NewCartItem) (*models.
 // Inserts multiple cart items into the database.
 //encore:api auth method=POST path=/cart/add/all
 func AddCartItems(ctx context.Context, newCartItems *models.NewCartItems) (*models.CartItems, error) {
+	// Confirm the caller owns the cart and there is enough stock for each requested quantity.
+	for _, newCartItem := range newCartItems.Data {
+		if err := coreutils.RequireUser(newCartItem.UserID); err != nil {
+			return nil, err
+		}
+		if err := checkStock(ctx, newCartItem.ProductID, newCartItem.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	// Start the transaction, so the change is never made without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 	// Insert the cart items into the database.
-	r, err := CartItemsTable.InsertCartItems(ctx, newCartItems)
+	r, err := CartItemsTable.InsertCartItemsTx(ctx, tx, newCartItems)
 	if err != nil {
 		return nil, err
 	}
+	// Record the cart change in the outbox.
+	if err := enqueueCartChangedTx(ctx, tx, models.CartItemsAdded, newCartItems.Data[0].UserID, r.Data...); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	// Fire go routine to invalidate the cache for the user's cart items.
 	go func() {
 		// Invalidate the cache for the user's cart items.
@@ -146,8 +207,6 @@ func AddCartItems(ctx context.Context, newCartItems *models.NewCartItems) (*mode
 		}
 	}()
 
-	// TODO: Send event on Kafka topic for cart items update for the user.
-
 	return r, nil
 }
 
@@ -155,11 +214,36 @@ func AddCartItems(ctx context.Context, newCartItems *models.NewCartItems) (*mode
 // Updates a cart item in the database.
 //encore:api auth method=PUT path=/cart/update
 func UpdateCartItem(ctx context.Context, updatedCartItem *models.CartItem) (*models.CartChangeRequestReturn, error) {
+	// Confirm the caller owns the cart item, both before and after the update.
+	if _, err := requireCartItemOwner(ctx, updatedCartItem.ID); err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(updatedCartItem.UserID); err != nil {
+		return nil, err
+	}
+	// Confirm there is enough stock for the requested quantity.
+	if err := checkStock(ctx, updatedCartItem.ProductID, updatedCartItem.Quantity); err != nil {
+		return nil, err
+	}
+	// Start the transaction, so the change is never made without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 	// Update the cart item in the database.
-	err := CartItemsTable.UpdateCartItem(ctx, updatedCartItem.ProductID, updatedCartItem.Quantity, updatedCartItem.UserID, updatedCartItem.ID)
+	updated, err := CartItemsTable.UpdateCartItemTx(ctx, tx, updatedCartItem.ProductID, updatedCartItem.Quantity, updatedCartItem.UserID, updatedCartItem.ID)
 	if err != nil {
 		return nil, err
 	}
+	// Record the cart change in the outbox.
+	if err := enqueueCartChangedTx(ctx, tx, models.CartItemsUpdated, updated.UserID, updated); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	// Fire go routine to invalidate the cache for the user's cart items.
 	go func() {
 		// Invalidate the cache for the user's cart items.
@@ -170,8 +254,6 @@ func UpdateCartItem(ctx context.Context, updatedCartItem *models.CartItem) (*mod
 		}
 	}()
 
-	// TODO: Send event on Kafka topic for cart items update for the user.
-
 	return &models.CartChangeRequestReturn{CartID: updatedCartItem.ID}, nil
 }
 
@@ -179,11 +261,37 @@ func UpdateCartItem(ctx context.Context, updatedCartItem *models.CartItem) (*mod
 // Deletes a cart item from the database.
 //encore:api auth method=DELETE path=/cart/delete/:id/user/:user_id
 func DeleteCartItem(ctx context.Context, id int, user_id string) (*models.CartChangeRequestReturn, error) {
+	// Confirm the caller owns the cart item.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	item, err := requireCartItemOwner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Start the transaction, so the change is never made without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 	// Delete the cart item from the database.
-	err := CartItemsTable.DeleteCartItem(ctx, id)
+	err = CartItemsTable.DeleteCartItemTx(ctx, tx, id)
 	if err != nil {
 		return nil, err
 	}
+	// Mark the rest of the cart as changed, so it is not considered idle.
+	if err := CartItemsTable.TouchCartTx(ctx, tx, user_id); err != nil {
+		return nil, err
+	}
+	// Record the cart change in the outbox.
+	if err := enqueueCartChangedTx(ctx, tx, models.CartItemsDeleted, user_id, item); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	// Fire go routine to invalidate the cache for the user's cart items.
 	go func() {
 		// Invalidate the cache for the user's cart items.
@@ -194,7 +302,52 @@ func DeleteCartItem(ctx context.Context, id int, user_id string) (*models.CartCh
 		}
 	}()
 
-	// TODO: Send event on Kafka topic for cart items update for the user.
-
 	return &models.CartChangeRequestReturn{CartID: id}, nil
-}
\ No newline at end of file
+}
+
+// requireCartItemOwner confirms the authenticated caller owns the stored cart item with the given ID,
+// and returns the stored cart item.
+func requireCartItemOwner(ctx context.Context, id int) (*models.CartItem, error) {
+	c, err := CartItemsTable.GetCartItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(c.UserID); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkStock confirms the product has at least quantity units in stock.
+func checkStock(ctx context.Context, productID int, quantity int) error {
+	stock, err := ProductsTB.GetStock(ctx, productID)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("product %d not found", productID),
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if quantity > stock {
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("insufficient stock for product %d: requested %d, available %d", productID, quantity, stock),
+		}
+	}
+	return nil
+}
+
+// DELETE: /cart/cache/:user_id
+// Invalidates the cached cart items for a user. Used by other services that mutate cart items directly.
+//encore:api private method=DELETE path=/cart/cache/:user_id
+func InvalidateCartCache(ctx context.Context, user_id string) error {
+	// Invalidate the cache for the user's cart items.
+	if _, err := CartItemsCacheKeyspace.Delete(ctx, user_id); err != nil {
+		// log error
+		rlog.Error("Error deleting user cart items cache", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+// Hours a cart must sit unchanged before it is considered abandoned.
+AbandonedCartIdleHours: 24
@@ -0,0 +1,39 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/cart/models"
+	outboxdb "encore.app/outbox/db"
+	"encore.dev/pubsub"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Events
+
+// OutboxTable instance, used to publish cart events.
+var OutboxTable = &outboxdb.OutboxTable{DB: PlamatioDB}
+
+// CartChangedTopic carries an event for every change to a user's cart.
+var CartChangedTopic = pubsub.NewTopic[*models.CartChangedEvent]("cart-changed", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// CartAbandonedTopic carries an event for every cart left idle past the abandoned cart threshold.
+var CartAbandonedTopic = pubsub.NewTopic[*models.CartAbandonedEvent]("cart-abandoned", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// enqueueCartChangedTx adds a cart-changed event for the given cart items to the outbox as
+// part of the given transaction. The outbox relay publishes it once the transaction is committed.
+func enqueueCartChangedTx(ctx context.Context, tx *sqldb.Tx, action string, userID string, items ...*models.CartItem) error {
+	event := &models.CartChangedEvent{Action: action, UserID: userID, Items: items, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "cart-changed", event)
+}
+
+// enqueueCartAbandonedTx adds a cart-abandoned event to the outbox as part of the given transaction.
+func enqueueCartAbandonedTx(ctx context.Context, tx *sqldb.Tx, event *models.CartAbandonedEvent) error {
+	return OutboxTable.InsertTx(ctx, tx, "cart-abandoned", event)
+}
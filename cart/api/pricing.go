@@ -0,0 +1,81 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	models "encore.app/cart/models"
+	coreutils "encore.app/core/utils"
+	promotionsdb "encore.app/promotions/db"
+	promotionsmodels "encore.app/promotions/models"
+	promotionsutils "encore.app/promotions/utils"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// PromotionsTable instance, used to look up coupons.
+var PromotionsTable = &promotionsdb.PromotionsTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /cart/price/:user_id
+// Prices the user's cart from current product prices, in the requested currency if one
+// is given, applying the coupon if one is given. The coupon is only checked, not redeemed; it is redeemed when the order is placed.
+//encore:api auth method=GET path=/cart/price/:user_id
+func GetCartPrice(ctx context.Context, user_id string, params *models.CartPriceParams) (*promotionsmodels.PriceBreakdown, error) {
+	// Confirm the caller owns the cart.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	// Validate the currency, if one is given.
+	if params.Currency != "" {
+		if err := coreutils.ValidateCurrency(params.Currency); err != nil {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: err.Error(),
+			}
+		}
+	}
+	// Retrieve the user's cart items.
+	cartItems, err := GetCartItems(ctx, user_id)
+	if err != nil {
+		return nil, err
+	}
+	// Price each item from current product prices.
+	var lines []*promotionsmodels.PricedLine
+	var currencies []string
+	for _, ci := range cartItems.Data {
+		p, err := ProductsTB.GetPricing(ctx, ci.ProductID, params.Currency)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, &promotionsmodels.PricedLine{ProductID: p.ID, CategoryId: p.CategoryId, SubCategoryId: p.SubCategoryId, Quantity: ci.Quantity, UnitPrice: p.Display.Price, Currency: p.Display.Currency, BaseCurrency: p.Currency, Rate: p.Display.Rate})
+		currencies = append(currencies, p.Currency)
+	}
+	// Reject carts mixing currencies, since they cannot be checked out as one order.
+	if _, err := coreutils.CommonCurrency(currencies...); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: err.Error(),
+		}
+	}
+	// Price the cart without a coupon if none is given.
+	code := promotionsutils.NormalizeCouponCode(params.CouponCode)
+	if code == "" {
+		return promotionsutils.PriceLines(lines, nil), nil
+	}
+	// Apply the coupon.
+	promotion, err := PromotionsTable.GetByCode(ctx, code)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, promotionsutils.CouponNotFound(code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return promotionsutils.ApplyCoupon(promotion, lines, time.Now())
+}
@@ -0,0 +1,88 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/cart/models"
+	coremodels "encore.app/core/models"
+	"encore.dev/storage/sqldb"
+)
+
+type AbandonedCartsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_DETECT_ABANDONED_CARTS = `
+				INSERT INTO abandoned_carts (user_id, last_activity_at, item_count, detected_at)
+				SELECT user_id, MAX(updated_at), SUM(quantity), $2 FROM cart_items
+				GROUP BY user_id
+				HAVING MAX(updated_at) <= $1
+				ON CONFLICT (user_id, last_activity_at) DO NOTHING
+				RETURNING id, user_id, last_activity_at
+		`
+		SQL_GET_ABANDONED_CARTS_REPORT = `
+				WITH carts AS (
+					SELECT user_id, MAX(updated_at) AS last_activity_at, SUM(quantity) AS item_count FROM cart_items
+					GROUP BY user_id
+					HAVING MAX(updated_at) <= $1
+					ORDER BY last_activity_at, user_id
+					LIMIT $2
+				)
+				SELECT c.user_id, c.item_count, c.last_activity_at, ac.detected_at, p.currency, SUM(ci.quantity * p.price) FROM carts c
+				JOIN cart_items ci ON ci.user_id = c.user_id
+				JOIN products p ON p.id = ci.product_id
+				LEFT JOIN abandoned_carts ac ON ac.user_id = c.user_id AND ac.last_activity_at = c.last_activity_at
+				GROUP BY c.user_id, c.item_count, c.last_activity_at, ac.detected_at, p.currency
+				ORDER BY c.last_activity_at, c.user_id, p.currency
+		`
+)
+
+// Records the carts that have not changed since idleSince and were not yet recorded
+// for their last activity, as part of the given transaction. Returns an event for each
+// newly abandoned cart, without its items.
+func (tb *AbandonedCartsTable) DetectTx(ctx context.Context, tx *sqldb.Tx, idleSince time.Time, now time.Time) ([]*models.CartAbandonedEvent, error) {
+	rows, err := tx.Query(ctx, SQL_DETECT_ABANDONED_CARTS, idleSince, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.CartAbandonedEvent
+	for rows.Next() {
+		e := &models.CartAbandonedEvent{OccurredAt: now}
+		if err := rows.Scan(&e.AbandonedCartID, &e.UserID, &e.LastActivityAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Retrieves up to limit carts that have not changed since idleSince, longest idle first,
+// valued at current product prices.
+func (tb *AbandonedCartsTable) GetReport(ctx context.Context, idleSince time.Time, limit int) ([]*models.AbandonedCart, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ABANDONED_CARTS_REPORT, idleSince, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Each row holds the value of a cart in one currency; carts span consecutive rows.
+	var carts []*models.AbandonedCart
+	for rows.Next() {
+		c := &models.AbandonedCart{}
+		value := &coremodels.Money{}
+		if err := rows.Scan(&c.UserID, &c.ItemCount, &c.LastActivityAt, &c.DetectedAt, &value.Currency, &value.Amount); err != nil {
+			return nil, err
+		}
+		if n := len(carts); n > 0 && carts[n-1].UserID == c.UserID {
+			carts[n-1].EstimatedValue = append(carts[n-1].EstimatedValue, value)
+			continue
+		}
+		c.EstimatedValue = []*coremodels.Money{value}
+		carts = append(carts, c)
+	}
+	return carts, rows.Err()
+}
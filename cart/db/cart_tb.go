@@ -3,6 +3,7 @@ package cart
 import (
 	"context"
 	"errors"
+	"time"
 
 	models "encore.app/cart/models"
 	utils "encore.app/cart/utils"
@@ -22,6 +23,8 @@ CREATE TABLE cart_items (
     product_id BIGINT NOT NULL,
     quantity INT NOT NULL,
     user_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
     FOREIGN KEY (product_id) REFERENCES products(id),
     FOREIGN KEY (user_id) REFERENCES users(id)
 );
@@ -32,31 +35,43 @@ CREATE INDEX idx_user_id_cart_items ON cart_items (user_id);
 
 const (
     SQL_GET_CART_ITEM = `
-				SELECT product_id, quantity, user_id FROM cart_items
+				SELECT product_id, quantity, user_id, created_at, updated_at FROM cart_items
 				WHERE id = $1
 		`
 		SQL_GET_ALL_CART_ITEMS = `
-				SELECT id, product_id, quantity, user_id FROM cart_items
+				SELECT id, product_id, quantity, user_id, created_at, updated_at FROM cart_items
 		`
 		SQL_GET_CART_ITEMS_BY_USER = `
-				SELECT id, product_id, quantity, user_id FROM cart_items
+				SELECT id, product_id, quantity, user_id, created_at, updated_at FROM cart_items
 				WHERE user_id = $1
 		`
 		SQL_INSERT_CART_ITEM = `
-				INSERT INTO cart_items (product_id, quantity, user_id) VALUES ($1, $2, $3) RETURNING id
+				INSERT INTO cart_items (product_id, quantity, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $4) RETURNING id, created_at, updated_at
 		`
 		SQL_UPDATE_CART_ITEM = `
-				UPDATE cart_items SET product_id = $1, quantity = $2, user_id = $3 WHERE id = $4
+				UPDATE cart_items SET product_id = $1, quantity = $2, user_id = $3, updated_at = $4 WHERE id = $5
+				RETURNING created_at, updated_at
 		`
 		SQL_DELETE_CART_ITEM = `
 				DELETE FROM cart_items WHERE id = $1
 		`
+		SQL_GET_CART_ITEMS_BY_USER_FOR_UPDATE = `
+				SELECT id, product_id, quantity, user_id, created_at, updated_at FROM cart_items
+				WHERE user_id = $1
+				FOR UPDATE
+		`
+		SQL_DELETE_CART_ITEMS_BY_USER = `
+				DELETE FROM cart_items WHERE user_id = $1
+		`
+		SQL_TOUCH_CART = `
+				UPDATE cart_items SET updated_at = $1 WHERE user_id = $2
+		`
 )
 
 // Retrieves a cart item from the database.
 func (tb *CartItemsTable) GetCartItem(ctx context.Context, id int) (*models.CartItem, error) {
 	ci := &models.CartItem{ID: id}
-	err := tb.DB.QueryRow(ctx, SQL_GET_CART_ITEM, id).Scan(&ci.ProductID, &ci.Quantity, &ci.UserID)
+	err := tb.DB.QueryRow(ctx, SQL_GET_CART_ITEM, id).Scan(&ci.ProductID, &ci.Quantity, &ci.UserID, &ci.CreatedAt, &ci.UpdatedAt)
 	return ci, err
 }
 
@@ -71,7 +86,7 @@ func (tb *CartItemsTable) GetAllCartItems(ctx context.Context) (*models.CartItem
 	var cartItems []*models.CartItem
 	for rows.Next() {
 		ci := &models.CartItem{}
-		if err := rows.Scan(&ci.ID, &ci.ProductID, &ci.Quantity, &ci.UserID); err != nil {
+		if err := rows.Scan(&ci.ID, &ci.ProductID, &ci.Quantity, &ci.UserID, &ci.CreatedAt, &ci.UpdatedAt); err != nil {
 			return nil, err
 		}
 		cartItems = append(cartItems, ci)
@@ -90,7 +105,7 @@ func (tb *CartItemsTable) GetCartItemsByUser(ctx context.Context, userId string)
 	var cartItems []*models.CartItem
 	for rows.Next() {
 		ci := &models.CartItem{}
-		if err := rows.Scan(&ci.ID, &ci.ProductID, &ci.Quantity, &ci.UserID); err != nil {
+		if err := rows.Scan(&ci.ID, &ci.ProductID, &ci.Quantity, &ci.UserID, &ci.CreatedAt, &ci.UpdatedAt); err != nil {
 			return nil, err
 		}
 		cartItems = append(cartItems, ci)
@@ -106,7 +121,22 @@ func (tb *CartItemsTable) InsertCartItem(ctx context.Context, productID int, qua
 		return nil, err
 	}
 	ci := &models.CartItem{ProductID: productID, Quantity: quantity, UserID: userID}
-	err = tb.DB.QueryRow(ctx, SQL_INSERT_CART_ITEM, productID, quantity, userID).Scan(&ci.ID)
+	err = tb.DB.QueryRow(ctx, SQL_INSERT_CART_ITEM, productID, quantity, userID, time.Now()).Scan(&ci.ID, &ci.CreatedAt, &ci.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+// Inserts a cart item into the database as part of the given transaction.
+func (tb *CartItemsTable) InsertCartItemTx(ctx context.Context, tx *sqldb.Tx, productID int, quantity int, userID string) (*models.CartItem, error) {
+	// validate cart item data
+	err := utils.ValidateCartData(&models.CartItem{ProductID: productID, Quantity: quantity, UserID: userID}, false, false)
+	if err != nil {
+		return nil, err
+	}
+	ci := &models.CartItem{ProductID: productID, Quantity: quantity, UserID: userID}
+	err = tx.QueryRow(ctx, SQL_INSERT_CART_ITEM, productID, quantity, userID, time.Now()).Scan(&ci.ID, &ci.CreatedAt, &ci.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +163,24 @@ func (tb *CartItemsTable) InsertCartItems(ctx context.Context, newCartItems *mod
 	return &models.CartItems{Data: cartItems}, nil
 }
 
+// Insert cart items into the database as part of the given transaction.
+func (tb *CartItemsTable) InsertCartItemsTx(ctx context.Context, tx *sqldb.Tx, newCartItems *models.NewCartItems) (*models.CartItems, error) {
+	// validate cart items data
+	if err := utils.ValidateNewCartItems(newCartItems); err != nil {
+		return nil, err
+	}
+	// store the cart items to be returned
+	var cartItems []*models.CartItem
+	for _, newCartItem := range newCartItems.Data {
+		ci, err := tb.InsertCartItemTx(ctx, tx, newCartItem.ProductID, newCartItem.Quantity, newCartItem.UserID)
+		if err != nil {
+			return nil, err
+		}
+		cartItems = append(cartItems, ci)
+	}
+	return &models.CartItems{Data: cartItems}, nil
+}
+
 // Updates a cart item in the database.
 func (tb *CartItemsTable) UpdateCartItem(ctx context.Context, productID int, quantity int, userID string, id int) error {
 	// validate cart item data
@@ -140,10 +188,23 @@ func (tb *CartItemsTable) UpdateCartItem(ctx context.Context, productID int, qua
 	if err != nil {
 		return err
 	}
-	_, err = tb.DB.Exec(ctx, SQL_UPDATE_CART_ITEM, productID, quantity, userID, id)
+	_, err = tb.DB.Exec(ctx, SQL_UPDATE_CART_ITEM, productID, quantity, userID, time.Now(), id)
 	return err
 }
 
+// Updates a cart item as part of the given transaction, returning the updated cart item.
+func (tb *CartItemsTable) UpdateCartItemTx(ctx context.Context, tx *sqldb.Tx, productID int, quantity int, userID string, id int) (*models.CartItem, error) {
+	// validate cart item data
+	ci := &models.CartItem{ID: id, ProductID: productID, Quantity: quantity, UserID: userID}
+	if err := utils.ValidateCartData(ci, true, false); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(ctx, SQL_UPDATE_CART_ITEM, productID, quantity, userID, time.Now(), id).Scan(&ci.CreatedAt, &ci.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
 // Deletes a cart item from the database.
 func (tb *CartItemsTable) DeleteCartItem(ctx context.Context, id int) error {
 	// Validate ID
@@ -152,4 +213,50 @@ func (tb *CartItemsTable) DeleteCartItem(ctx context.Context, id int) error {
 	}
 	_, err := tb.DB.Exec(ctx, SQL_DELETE_CART_ITEM, id)
 	return err
+}
+
+// Deletes a cart item as part of the given transaction.
+func (tb *CartItemsTable) DeleteCartItemTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	// Validate ID
+	if id <= 0 {
+		return errors.New("invalid cart item ID")
+	}
+	_, err := tx.Exec(ctx, SQL_DELETE_CART_ITEM, id)
+	return err
+}
+
+// Marks a user's cart as changed now, as part of the given transaction.
+func (tb *CartItemsTable) TouchCartTx(ctx context.Context, tx *sqldb.Tx, userId string) error {
+	_, err := tx.Exec(ctx, SQL_TOUCH_CART, time.Now(), userId)
+	return err
+}
+
+// Retrieves all cart items for a user as part of the given transaction.
+// Rows are locked until the transaction completes.
+func (tb *CartItemsTable) GetCartItemsByUserTx(ctx context.Context, tx *sqldb.Tx, userId string) (*models.CartItems, error) {
+	rows, err := tx.Query(ctx, SQL_GET_CART_ITEMS_BY_USER_FOR_UPDATE, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cartItems []*models.CartItem
+	for rows.Next() {
+		ci := &models.CartItem{}
+		if err := rows.Scan(&ci.ID, &ci.ProductID, &ci.Quantity, &ci.UserID, &ci.CreatedAt, &ci.UpdatedAt); err != nil {
+			return nil, err
+		}
+		cartItems = append(cartItems, ci)
+	}
+	return &models.CartItems{Data: cartItems}, nil
+}
+
+// Deletes all cart items for a user as part of the given transaction.
+func (tb *CartItemsTable) DeleteCartItemsByUserTx(ctx context.Context, tx *sqldb.Tx, userId string) error {
+	// Validate user ID
+	if userId == "" {
+		return errors.New("invalid user ID")
+	}
+	_, err := tx.Exec(ctx, SQL_DELETE_CART_ITEMS_BY_USER, userId)
+	return err
 }
\ No newline at end of file
@@ -1,11 +1,19 @@
 package cart
 
+import (
+	"time"
+
+	coremodels "encore.app/core/models"
+)
+
 // CartItem represents an item in the cart.
 type CartItem struct {
 	ID        int `json:"id"`          // ID is the unique identifier of the cart item.
 	ProductID int `json:"product_id"`  // ProductID is the identifier of the product associated with the cart item.
 	Quantity  int   `json:"quantity"`   // Quantity is the number of items in the cart.
 	UserID    string `json:"user_id"`     // UserID is the identifier of the user who owns the cart item.
+	CreatedAt time.Time `json:"created_at"`  // CreatedAt is when the item was added to the cart.
+	UpdatedAt time.Time `json:"updated_at"`  // UpdatedAt is when the user last changed the cart.
 }
 
 // CartItems represents a collection of cart items.
@@ -25,7 +33,58 @@ type NewCartItems struct {
 	Data []*NewCartItem `json:"data"`  // Data is the list of new cart items.
 }
 
+// CartPriceParams represents the query parameters for pricing a cart.
+type CartPriceParams struct {
+	CouponCode string `query:"coupon"`  // CouponCode is the coupon code to apply to the cart, if any.
+	Currency   string `query:"currency"`  // Currency is the currency to price the cart in; defaults to the currency of the products.
+}
+
 // Return type for cart mutation requests.
 type CartChangeRequestReturn struct {
 	CartID int `json:"id"`  // CartID is the identifier of the cart.
-}
\ No newline at end of file
+}
+// Cart change actions carried by cart-changed events.
+const (
+	CartItemsAdded   = "added"   // Items were added to the cart.
+	CartItemsUpdated = "updated" // An item in the cart was updated.
+	CartItemsDeleted = "deleted" // An item was removed from the cart.
+	CartCleared      = "cleared" // The cart was emptied, e.g. on checkout.
+)
+
+// CartChangedEvent is published on the cart-changed topic after a user's cart changes.
+type CartChangedEvent struct {
+	Action     string      `json:"action"`      // Action is the kind of change, one of the cart change actions.
+	UserID     string      `json:"user_id"`     // UserID is the identifier of the user who owns the cart.
+	Items      []*CartItem `json:"items"`       // Items are the cart items affected by the change, as stored after it.
+	OccurredAt time.Time   `json:"occurred_at"` // OccurredAt is when the change was made.
+}
+
+// CartAbandonedEvent is published on the cart-abandoned topic once for each cart left idle past the abandoned cart threshold.
+type CartAbandonedEvent struct {
+	AbandonedCartID int         `json:"abandoned_cart_id"` // AbandonedCartID is the identifier of the abandoned cart record.
+	UserID          string      `json:"user_id"`           // UserID is the identifier of the user who owns the cart.
+	Items           []*CartItem `json:"items"`             // Items are the items left in the cart.
+	LastActivityAt  time.Time   `json:"last_activity_at"`  // LastActivityAt is when the user last changed the cart.
+	OccurredAt      time.Time   `json:"occurred_at"`       // OccurredAt is when the cart was detected as abandoned.
+}
+
+// AbandonedCart represents a cart left idle past the abandoned cart threshold.
+type AbandonedCart struct {
+	UserID         string              `json:"user_id"`          // UserID is the identifier of the user who owns the cart.
+	ItemCount      int                 `json:"item_count"`       // ItemCount is the number of units in the cart.
+	LastActivityAt time.Time           `json:"last_activity_at"` // LastActivityAt is when the user last changed the cart.
+	EstimatedValue []*coremodels.Money `json:"estimated_value"`  // EstimatedValue is the value of the cart at current product prices, per currency.
+	DetectedAt     *time.Time          `json:"detected_at"`      // DetectedAt is when the abandoned cart event was emitted; nil if not yet.
+}
+
+// AbandonedCartReport represents the carts left idle past a threshold.
+type AbandonedCartReport struct {
+	IdleHours int              `json:"idle_hours"` // IdleHours is the threshold, in hours, after which an idle cart is abandoned.
+	Data      []*AbandonedCart `json:"data"`       // Data is the list of abandoned carts, longest idle first.
+}
+
+// AbandonedCartReportParams represents the query parameters for the abandoned cart report.
+type AbandonedCartReportParams struct {
+	IdleHours int `query:"idle_hours"` // IdleHours overrides the configured abandoned cart threshold, in hours.
+	Limit     int `query:"limit"`      // Limit is the maximum number of carts; defaults to 100.
+}
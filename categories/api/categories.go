@@ -2,13 +2,20 @@ package categories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	db "encore.app/categories/db"
 	models "encore.app/categories/models"
+	products "encore.app/products/api"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
 	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
 )
 
 // ------------------------------------------------------
@@ -96,4 +103,126 @@ func GetCategories(ctx context.Context) (*models.Categories, error) {
 	}()
 	// Return the categories.
 	return r, err
-}
\ No newline at end of file
+}
+
+// POST: /categories/add
+// Inserts a category into the database.
+//encore:api auth method=POST path=/categories/add
+func AddCategory(ctx context.Context, p *models.CategoryRequestParams) (*models.Category, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Insert the category into the database.
+	id, err := CategoriesTable.Insert(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the categories cache.
+	go invalidateCategoryCache(ctx, id)
+	// Return the category.
+	return &models.Category{ID: id, Name: p.Name, Description: p.Description, Offered: p.Offered}, nil
+}
+
+// PUT: /categories/update/:id
+// Updates the category in the database with the given ID.
+//encore:api auth method=PUT path=/categories/update/:id
+func UpdateCategory(ctx context.Context, id int, p *models.CategoryRequestParams) (*models.Category, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Update the category in the database.
+	if err := CategoriesTable.Update(ctx, id, p); err != nil {
+		return nil, categoryError(err, id)
+	}
+	// Fire go routine to invalidate the category cache.
+	go invalidateCategoryCache(ctx, id)
+	// Return the updated category.
+	return &models.Category{ID: id, Name: p.Name, Description: p.Description, Offered: p.Offered}, nil
+}
+
+// PUT: /categories/offered/:id
+// Updates whether the category with the given ID is offered.
+//encore:api auth method=PUT path=/categories/offered/:id
+func SetCategoryOffered(ctx context.Context, id int, p *models.OfferedRequestParams) (*models.CategoryChangeRequestReturn, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Update the category in the database.
+	if err := CategoriesTable.SetOffered(ctx, id, p.Offered); err != nil {
+		return nil, categoryError(err, id)
+	}
+	// Fire go routine to invalidate the category cache.
+	go invalidateCategoryCache(ctx, id)
+	// Return request status.
+	return &models.CategoryChangeRequestReturn{ID: id}, nil
+}
+
+// DELETE: /categories/delete/:id
+// Deletes the category with the given ID. Categories that still have products
+// or sub-categories cannot be deleted.
+//encore:api auth method=DELETE path=/categories/delete/:id
+func DeleteCategory(ctx context.Context, id int) (*models.CategoryChangeRequestReturn, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Confirm no products or sub-categories reference the category.
+	products, subCategories, err := CategoriesTable.CountDependents(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if products > 0 || subCategories > 0 {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("category %d still has %d products and %d sub-categories", id, products, subCategories),
+		}
+	}
+	// Delete the category from the database.
+	if err := CategoriesTable.Delete(ctx, id); err != nil {
+		return nil, categoryError(err, id)
+	}
+	// Fire go routine to invalidate the category cache.
+	go invalidateCategoryCache(ctx, id)
+	// Return request status.
+	return &models.CategoryChangeRequestReturn{ID: id}, nil
+}
+
+// invalidateCategoryCache removes the category and the list of all categories from cache,
+// and retires the cached product query results.
+func invalidateCategoryCache(ctx context.Context, id int) {
+	// Invalidate the cache for the category.
+	if _, err := CategoryCacheKeyspace.Delete(ctx, id); err != nil {
+		// log error
+		rlog.Error("Error deleting category cache", err)
+	}
+	// Invalidate the cache for all categories.
+	if _, err := CategoriesCacheKeyspace.Delete(ctx, "all"); err != nil {
+		// log error
+		rlog.Error("Error deleting categories cache", err)
+	}
+	// Retire the cached product search, list and suggestion results, which carry category data.
+	if err := products.RetireProductQueryCache(ctx); err != nil {
+		// log error
+		rlog.Error("Error retiring product query cache", err)
+	}
+}
+
+// categoryError converts database errors for the category with the given ID into API errors.
+func categoryError(err error, id int) error {
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("category %d not found", id),
+		}
+	}
+	if sqldb.ErrCode(err) == sqlerr.ForeignKeyViolation {
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("category %d is still referenced", id),
+		}
+	}
+	return err
+}
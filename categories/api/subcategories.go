@@ -2,12 +2,20 @@ package categories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	db "encore.app/categories/db"
 	models "encore.app/categories/models"
+	products "encore.app/products/api"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
 )
 
 // ------------------------------------------------------
@@ -118,4 +126,172 @@ func GetSubCategoriesByCategory(ctx context.Context, category_id int) (*models.S
 	}()
 	// Return the sub-categories.
 	return r, err
-}
\ No newline at end of file
+}
+
+// POST: /categories/subcategories/add
+// Inserts a sub-category into the database.
+//encore:api auth method=POST path=/categories/subcategories/add
+func AddSubCategory(ctx context.Context, p *models.SubCategoryRequestParams) (*models.SubCategory, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Insert the sub-category into the database.
+	id, err := SubCategoriesTable.Insert(ctx, p)
+	if err != nil {
+		return nil, subCategoryError(err, 0, p.CategoryId)
+	}
+	// Fire go routine to invalidate the sub-categories cache.
+	go invalidateSubCategoryCache(ctx, id, p.CategoryId)
+	// Return the sub-category.
+	return &models.SubCategory{ID: id, Name: p.Name, Description: p.Description, CategoryId: p.CategoryId, Offered: p.Offered}, nil
+}
+
+// PUT: /categories/subcategories/update/:id
+// Updates the sub-category in the database with the given ID. A sub-category that
+// still has products cannot be moved to another category.
+//encore:api auth method=PUT path=/categories/subcategories/update/:id
+func UpdateSubCategory(ctx context.Context, id int, p *models.SubCategoryRequestParams) (*models.SubCategory, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Retrieve the current sub-category.
+	current, err := SubCategoriesTable.GetSubCategory(ctx, id)
+	if err != nil {
+		return nil, subCategoryError(err, id, p.CategoryId)
+	}
+	// Confirm no products would be left under the previous category.
+	if current.CategoryId != p.CategoryId {
+		products, _, err := SubCategoriesTable.CountDependents(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if products > 0 {
+			return nil, &errs.Error{
+				Code:    errs.FailedPrecondition,
+				Message: fmt.Sprintf("sub-category %d still has %d products and cannot change category", id, products),
+			}
+		}
+	}
+	// Update the sub-category in the database.
+	if err := SubCategoriesTable.Update(ctx, id, p); err != nil {
+		return nil, subCategoryError(err, id, p.CategoryId)
+	}
+	// Fire go routine to invalidate the sub-category cache for the previous and new category.
+	go func() {
+		invalidateSubCategoryCache(ctx, id, p.CategoryId)
+		if current.CategoryId != p.CategoryId {
+			invalidateSubCategoryCache(ctx, id, current.CategoryId)
+		}
+	}()
+	// Return the updated sub-category.
+	return &models.SubCategory{ID: id, Name: p.Name, Description: p.Description, CategoryId: p.CategoryId, Offered: p.Offered}, nil
+}
+
+// PUT: /categories/subcategories/offered/:id
+// Updates whether the sub-category with the given ID is offered.
+//encore:api auth method=PUT path=/categories/subcategories/offered/:id
+func SetSubCategoryOffered(ctx context.Context, id int, p *models.OfferedRequestParams) (*models.CategoryChangeRequestReturn, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Retrieve the current sub-category.
+	current, err := SubCategoriesTable.GetSubCategory(ctx, id)
+	if err != nil {
+		return nil, subCategoryError(err, id, 0)
+	}
+	// Update the sub-category in the database.
+	if err := SubCategoriesTable.SetOffered(ctx, id, p.Offered); err != nil {
+		return nil, subCategoryError(err, id, 0)
+	}
+	// Fire go routine to invalidate the sub-category cache.
+	go invalidateSubCategoryCache(ctx, id, current.CategoryId)
+	// Return request status.
+	return &models.CategoryChangeRequestReturn{ID: id}, nil
+}
+
+// DELETE: /categories/subcategories/delete/:id
+// Deletes the sub-category with the given ID. Sub-categories that still have
+// products or hero products cannot be deleted.
+//encore:api auth method=DELETE path=/categories/subcategories/delete/:id
+func DeleteSubCategory(ctx context.Context, id int) (*models.CategoryChangeRequestReturn, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Retrieve the current sub-category.
+	current, err := SubCategoriesTable.GetSubCategory(ctx, id)
+	if err != nil {
+		return nil, subCategoryError(err, id, 0)
+	}
+	// Confirm no products or hero products reference the sub-category.
+	products, heroProducts, err := SubCategoriesTable.CountDependents(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if products > 0 || heroProducts > 0 {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("sub-category %d still has %d products and %d hero products", id, products, heroProducts),
+		}
+	}
+	// Delete the sub-category from the database.
+	if err := SubCategoriesTable.Delete(ctx, id); err != nil {
+		return nil, subCategoryError(err, id, 0)
+	}
+	// Fire go routine to invalidate the sub-category cache.
+	go invalidateSubCategoryCache(ctx, id, current.CategoryId)
+	// Return request status.
+	return &models.CategoryChangeRequestReturn{ID: id}, nil
+}
+
+// invalidateSubCategoryCache removes the sub-category, the list of all sub-categories
+// and the sub-categories of the given category from cache, and retires the cached
+// product query results.
+func invalidateSubCategoryCache(ctx context.Context, id int, categoryId int) {
+	// Invalidate the cache for the sub-category.
+	if _, err := SubCategoryCacheKeyspace.Delete(ctx, id); err != nil {
+		// log error
+		rlog.Error("Error deleting sub-category cache", err)
+	}
+	// Invalidate the cache for all sub-categories.
+	if _, err := SubCategoriesCacheKeyspace.Delete(ctx, "all"); err != nil {
+		// log error
+		rlog.Error("Error deleting sub-categories cache", err)
+	}
+	// Invalidate the cache for the sub-categories of the category.
+	if _, err := SubCategoriesByCategoryCacheKeyspace.Delete(ctx, categoryId); err != nil {
+		// log error
+		rlog.Error("Error deleting sub-categories by category cache", err)
+	}
+	// Retire the cached product search, list and suggestion results, which carry category data.
+	if err := products.RetireProductQueryCache(ctx); err != nil {
+		// log error
+		rlog.Error("Error retiring product query cache", err)
+	}
+}
+
+// subCategoryError converts database errors for the sub-category with the given ID into API errors.
+func subCategoryError(err error, id int, categoryId int) error {
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("sub-category %d not found", id),
+		}
+	}
+	if sqldb.ErrCode(err) == sqlerr.ForeignKeyViolation {
+		if categoryId > 0 {
+			return &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: fmt.Sprintf("category %d does not exist", categoryId),
+			}
+		}
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("sub-category %d is still referenced", id),
+		}
+	}
+	return err
+}
@@ -40,6 +40,36 @@ const (
     SQL_GET_ALL_CATEGORIES = `
         SELECT id, name, description, offered FROM categories
     `
+    SQL_INSERT_CATEGORY = `
+        INSERT INTO categories (name, description, offered) VALUES ($1, $2, $3)
+        RETURNING id
+    `
+    SQL_UPDATE_CATEGORY = `
+        UPDATE categories SET name = $1, description = $2, offered = $3
+        WHERE id = $4
+        RETURNING id
+    `
+    SQL_UPDATE_CATEGORY_OFFERED = `
+        UPDATE categories SET offered = $1
+        WHERE id = $2
+        RETURNING id
+    `
+    SQL_DELETE_CATEGORY = `
+        DELETE FROM categories
+        WHERE id = $1
+        RETURNING id
+    `
+    SQL_COUNT_CATEGORY_DEPENDENTS = `
+        SELECT
+            (SELECT COUNT(*) FROM products WHERE category_id = $1),
+            (SELECT COUNT(*) FROM sub_categories WHERE category_id = $1)
+    `
+    SQL_SUGGEST_CATEGORIES = `
+        SELECT id, name, description, offered FROM categories
+        WHERE offered AND (name ILIKE $2 OR $1 <% name)
+        ORDER BY name ILIKE $2 DESC, word_similarity($1, name) DESC, name
+        LIMIT $3
+    `
 )
 
 // Retrieves a category from the database.
@@ -71,4 +101,63 @@ func (tb *CategoriesTable) GetAll(ctx context.Context) (*models.Categories, erro
 		categories = append(categories, c)
 	}
 	return &models.Categories{Data: categories}, nil
-}
\ No newline at end of file
+}
+
+// Retrieves offered categories whose name starts with, or closely resembles, the given prefix,
+// as part of the given transaction.
+func (tb *CategoriesTable) SuggestTx(ctx context.Context, tx *sqldb.Tx, prefix string, likePattern string, limit int) (*models.Categories, error) {
+	rows, err := tx.Query(ctx, SQL_SUGGEST_CATEGORIES, prefix, likePattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		c := &models.Category{}
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Offered); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return &models.Categories{Data: categories}, nil
+}
+
+// Inserts a category into the database and returns the id of the newly added record.
+func (tb *CategoriesTable) Insert(ctx context.Context, p *models.CategoryRequestParams) (int, error) {
+	// validate category data
+	if err := utils.ValidateCategoryRequestParams(p); err != nil {
+		return 0, err
+	}
+	var id int
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_CATEGORY, p.Name, p.Description, p.Offered).Scan(&id)
+	return id, err
+}
+
+// Updates a category in the database.
+// Returns sqldb.ErrNoRows if the category does not exist.
+func (tb *CategoriesTable) Update(ctx context.Context, id int, p *models.CategoryRequestParams) error {
+	// validate category data
+	if err := utils.ValidateCategoryRequestParams(p); err != nil {
+		return err
+	}
+	return tb.DB.QueryRow(ctx, SQL_UPDATE_CATEGORY, p.Name, p.Description, p.Offered, id).Scan(&id)
+}
+
+// Updates whether a category is offered.
+// Returns sqldb.ErrNoRows if the category does not exist.
+func (tb *CategoriesTable) SetOffered(ctx context.Context, id int, offered bool) error {
+	return tb.DB.QueryRow(ctx, SQL_UPDATE_CATEGORY_OFFERED, offered, id).Scan(&id)
+}
+
+// Deletes a category from the database.
+// Returns sqldb.ErrNoRows if the category does not exist.
+func (tb *CategoriesTable) Delete(ctx context.Context, id int) error {
+	return tb.DB.QueryRow(ctx, SQL_DELETE_CATEGORY, id).Scan(&id)
+}
+
+// Counts the products and sub-categories that reference a category.
+func (tb *CategoriesTable) CountDependents(ctx context.Context, id int) (products int, subCategories int, err error) {
+	err = tb.DB.QueryRow(ctx, SQL_COUNT_CATEGORY_DEPENDENTS, id).Scan(&products, &subCategories)
+	return products, subCategories, err
+}
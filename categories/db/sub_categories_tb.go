@@ -44,6 +44,36 @@ const (
 				SELECT id, name, description, category_id, offered FROM sub_categories
 				WHERE category_id = $1
 		`
+		SQL_INSERT_SUB_CATEGORY = `
+				INSERT INTO sub_categories (name, description, category_id, offered) VALUES ($1, $2, $3, $4)
+				RETURNING id
+		`
+		SQL_UPDATE_SUB_CATEGORY = `
+				UPDATE sub_categories SET name = $1, description = $2, category_id = $3, offered = $4
+				WHERE id = $5
+				RETURNING id
+		`
+		SQL_UPDATE_SUB_CATEGORY_OFFERED = `
+				UPDATE sub_categories SET offered = $1
+				WHERE id = $2
+				RETURNING id
+		`
+		SQL_DELETE_SUB_CATEGORY = `
+				DELETE FROM sub_categories
+				WHERE id = $1
+				RETURNING id
+		`
+		SQL_COUNT_SUB_CATEGORY_DEPENDENTS = `
+				SELECT
+						(SELECT COUNT(*) FROM products WHERE sub_category_id = $1),
+						(SELECT COUNT(*) FROM category_hero_products WHERE sub_category_id = $1)
+		`
+		SQL_SUGGEST_SUB_CATEGORIES = `
+				SELECT id, name, description, category_id, offered FROM sub_categories
+				WHERE offered AND (name ILIKE $2 OR $1 <% name)
+				ORDER BY name ILIKE $2 DESC, word_similarity($1, name) DESC, name
+				LIMIT $3
+		`
 )
 
 // Retrieves a sub-category from the database.
@@ -94,4 +124,63 @@ func (tb *SubCategoriesTable) GetSubCategoriesByCategory(ctx context.Context, ca
 		subCategories = append(subCategories, sc)
 	}
 	return &models.SubCategories{Data: subCategories}, nil
-}
\ No newline at end of file
+}
+
+// Retrieves offered sub-categories whose name starts with, or closely resembles, the given prefix,
+// as part of the given transaction.
+func (tb *SubCategoriesTable) SuggestTx(ctx context.Context, tx *sqldb.Tx, prefix string, likePattern string, limit int) (*models.SubCategories, error) {
+	rows, err := tx.Query(ctx, SQL_SUGGEST_SUB_CATEGORIES, prefix, likePattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subCategories []*models.SubCategory
+	for rows.Next() {
+		sc := &models.SubCategory{}
+		if err := rows.Scan(&sc.ID, &sc.Name, &sc.Description, &sc.CategoryId, &sc.Offered); err != nil {
+			return nil, err
+		}
+		subCategories = append(subCategories, sc)
+	}
+	return &models.SubCategories{Data: subCategories}, nil
+}
+
+// Inserts a sub-category into the database and returns the id of the newly added record.
+func (tb *SubCategoriesTable) Insert(ctx context.Context, p *models.SubCategoryRequestParams) (int, error) {
+	// validate sub-category data
+	if err := utils.ValidateSubCategoryRequestParams(p); err != nil {
+		return 0, err
+	}
+	var id int
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_SUB_CATEGORY, p.Name, p.Description, p.CategoryId, p.Offered).Scan(&id)
+	return id, err
+}
+
+// Updates a sub-category in the database.
+// Returns sqldb.ErrNoRows if the sub-category does not exist.
+func (tb *SubCategoriesTable) Update(ctx context.Context, id int, p *models.SubCategoryRequestParams) error {
+	// validate sub-category data
+	if err := utils.ValidateSubCategoryRequestParams(p); err != nil {
+		return err
+	}
+	return tb.DB.QueryRow(ctx, SQL_UPDATE_SUB_CATEGORY, p.Name, p.Description, p.CategoryId, p.Offered, id).Scan(&id)
+}
+
+// Updates whether a sub-category is offered.
+// Returns sqldb.ErrNoRows if the sub-category does not exist.
+func (tb *SubCategoriesTable) SetOffered(ctx context.Context, id int, offered bool) error {
+	return tb.DB.QueryRow(ctx, SQL_UPDATE_SUB_CATEGORY_OFFERED, offered, id).Scan(&id)
+}
+
+// Deletes a sub-category from the database.
+// Returns sqldb.ErrNoRows if the sub-category does not exist.
+func (tb *SubCategoriesTable) Delete(ctx context.Context, id int) error {
+	return tb.DB.QueryRow(ctx, SQL_DELETE_SUB_CATEGORY, id).Scan(&id)
+}
+
+// Counts the products and category hero products that reference a sub-category.
+func (tb *SubCategoriesTable) CountDependents(ctx context.Context, id int) (products int, heroProducts int, err error) {
+	err = tb.DB.QueryRow(ctx, SQL_COUNT_SUB_CATEGORY_DEPENDENTS, id).Scan(&products, &heroProducts)
+	return products, heroProducts, err
+}
@@ -25,4 +25,29 @@ type SubCategory struct {
 // SubCategories represents a collection of sub-categories.
 type SubCategories struct {
 	Data []*SubCategory `json:"data"`
-}
\ No newline at end of file
+}
+
+// CategoryRequestParams represents the parameters required to create or update a category.
+type CategoryRequestParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Offered     bool   `json:"offered"`
+}
+
+// SubCategoryRequestParams represents the parameters required to create or update a sub-category.
+type SubCategoryRequestParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CategoryId  int    `json:"category"`
+	Offered     bool   `json:"offered"`
+}
+
+// OfferedRequestParams represents the parameters required to change whether a category or sub-category is offered.
+type OfferedRequestParams struct {
+	Offered bool `json:"offered"`
+}
+
+// Return type for mutations to category and sub-category data.
+type CategoryChangeRequestReturn struct {
+	ID int `json:"id"`
+}
@@ -2,23 +2,50 @@ package categories
 
 import (
 	"errors"
+
+	models "encore.app/categories/models"
 )
 
+// ValidateProductCategory checks the category ID is well-formed.
+// Whether the category exists is determined by the categories table.
 func ValidateProductCategory(categoryId int) error {
 	// validate the product category
-	if categoryId < 0 || categoryId > 3 {
-		return errors.New("invalid category id; can be from 1 to 3")
+	if categoryId <= 0 {
+		return errors.New("invalid category id; must be a positive integer")
 	}
 
 	return nil
 }
 
+// ValidateProductSubCategory checks the sub-category ID is well-formed.
+// Whether the sub-category exists is determined by the sub_categories table.
 func ValidateProductSubCategory(subCategoryId int) error {
 	// validate the product sub-category
-	if subCategoryId < 0 || subCategoryId > 10 {
-		return errors.New("invalid sub-category id; can be from 1 to 10")
+	if subCategoryId <= 0 {
+		return errors.New("invalid sub-category id; must be a positive integer")
 	}
 
 	return nil
 }
 
+func ValidateCategoryRequestParams(p *models.CategoryRequestParams) error {
+	// validate the category request parameters
+	if p.Name == "" {
+		return errors.New("category name is required")
+	}
+	return nil
+}
+
+func ValidateSubCategoryRequestParams(p *models.SubCategoryRequestParams) error {
+	// validate the sub-category request parameters
+	if p.Name == "" {
+		return errors.New("sub-category name is required")
+	}
+	if p.Description == "" {
+		return errors.New("sub-category description is required")
+	}
+	if p.CategoryId <= 0 {
+		return errors.New("sub-category category is required")
+	}
+	return nil
+}
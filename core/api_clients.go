@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	db "encore.app/core/db"
+	models "encore.app/core/models"
+	utils "encore.app/core/utils"
+	"encore.dev/beta/errs"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/cache"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// ApiClientsTable instance.
+var ApiClientsTable = &db.ApiClientsTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup Caching
+
+// CoreCluster is the cache cluster for core data.
+var CoreCluster = cache.NewCluster("core-cache-cluster", cache.ClusterConfig{
+    // Use LRU policy to evict keys when the cache reaches memory limit.
+    EvictionPolicy: cache.AllKeysLRU,
+})
+
+// Api Client Cache Keyspace to store API clients and their key hashes by key prefix.
+var ApiClientCacheKeyspace = cache.NewStructKeyspace[string, models.ApiClientCredentials](CoreCluster, cache.KeyspaceConfig{
+	KeyPattern:    "api-client-cache/:key",
+	DefaultExpiry: cache.ExpireIn(5 * time.Minute),
+})
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints to manage API clients (super-admin only):
+
+- POST: /core/clients/add
+- GET: /core/clients/all
+- PUT: /core/clients/revoke/:id
+
+To rotate a client key without downtime, issue a new key for the client,
+switch the client over to it, then revoke the old key.
+*/
+
+// POST: /core/clients/add
+// Issues a key for a new API client. The key is only returned once; only its hash is stored.
+//encore:api auth method=POST path=/core/clients/add
+func AddApiClient(ctx context.Context, p *models.ApiClientRequestParams) (*models.IssuedApiClient, error) {
+	// Confirm the caller may manage API clients.
+	if err := utils.RequireRole(models.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the request parameters.
+	if err := utils.ValidateApiClientRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Generate the key.
+	key, prefix, err := utils.GenerateApiKey()
+	if err != nil {
+		return nil, err
+	}
+	// Insert the API client into the database.
+	c := &models.ApiClient{Name: p.Name, KeyPrefix: prefix, Scopes: p.Scopes, CreatedAt: time.Now().UTC(), ExpiresAt: p.ExpiresAt}
+	id, err := ApiClientsTable.Insert(ctx, c, utils.HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	c.ID = id
+	// Return the API client and its key.
+	return &models.IssuedApiClient{Client: c, Key: key}, nil
+}
+
+// GET: /core/clients/all
+// Retrieves all API clients. Keys are never returned.
+//encore:api auth method=GET path=/core/clients/all
+func GetApiClients(ctx context.Context) (*models.ApiClients, error) {
+	// Confirm the caller may manage API clients.
+	if err := utils.RequireRole(models.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	return ApiClientsTable.GetAll(ctx)
+}
+
+// PUT: /core/clients/revoke/:id
+// Revokes the key of the API client with the given ID.
+//encore:api auth method=PUT path=/core/clients/revoke/:id
+func RevokeApiClient(ctx context.Context, id int) (*models.ApiClientChangeRequestReturn, error) {
+	// Confirm the caller may manage API clients.
+	if err := utils.RequireRole(models.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	// Revoke the API client in the database.
+	prefix, err := ApiClientsTable.Revoke(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("API client %d not found", id),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Cache the revoked API client as a tombstone, so the key stops working immediately
+	// and a concurrent cache fill with the client read before it was revoked cannot
+	// replace it.
+	creds, err := ApiClientsTable.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApiClientCacheKeyspace.Set(ctx, prefix, *creds); err != nil {
+		return nil, err
+	}
+	return &models.ApiClientChangeRequestReturn{ID: id}, nil
+}
+
+// authenticateApiClient returns the active API client the key belongs to.
+func authenticateApiClient(ctx context.Context, key string) (*models.ApiClient, error) {
+	invalid := &errs.Error{
+		Code:    errs.Unauthenticated,
+		Message: "invalid API key",
+	}
+	if key == "" {
+		return nil, invalid
+	}
+	// Accept the legacy shared API key, if configured.
+	if secrets.PlamatioWebFrontendApiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(secrets.PlamatioWebFrontendApiKey)) == 1 {
+		return &models.ApiClient{Name: "legacy", KeyPrefix: "legacy", Scopes: []string{models.RoleSuperAdmin}}, nil
+	}
+	prefix, err := utils.ApiKeyPrefix(key)
+	if err != nil {
+		return nil, invalid
+	}
+	// First, try retrieving the API client from cache if it exists.
+	creds, err := ApiClientCacheKeyspace.Get(ctx, prefix)
+	if err != nil {
+		// If the API client is not found in cache, retrieve it from the database.
+		r, err := ApiClientsTable.GetByPrefix(ctx, prefix)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return nil, invalid
+		}
+		if err != nil {
+			return nil, err
+		}
+		creds = *r
+		// Fire a go routine to cache the API client.
+		go func() {
+			// Cache the API client, unless it has been cached since, e.g. as revoked.
+			if err := ApiClientCacheKeyspace.SetIfNotExists(ctx, prefix, creds); err != nil && !errors.Is(err, cache.KeyExists) {
+				// Log the error
+				rlog.Error("error caching API client", err)
+			}
+		}()
+	}
+	// Confirm the key matches and the client is active.
+	if !utils.MatchesApiKey(key, creds.KeyHash) || !utils.IsActiveApiClient(creds.Client, time.Now()) {
+		return nil, invalid
+	}
+	return creds.Client, nil
+}
@@ -2,9 +2,14 @@ package core
 
 import (
 	"context"
+	"time"
 
+	models "encore.app/core/models"
+	utils "encore.app/core/utils"
 	"encore.dev/beta/auth"
 	"encore.dev/beta/errs"
+	"encore.dev/middleware"
+	rlog "encore.dev/rlog"
 	"encore.dev/storage/sqldb"
 )
 
@@ -18,24 +23,71 @@ var PlamatioDB = sqldb.NewDatabase("plamatio_db", sqldb.DatabaseConfig{
 // ------------------------------------------------------
 // Setup Authentication
 
-// secrets struct for API-key authentication.
+// secrets struct for authentication.
 var secrets struct {
-    PlamatioWebFrontendApiKey string    // API key for the Plamatio Web Frontend
+    PlamatioJwtSigningKey     string    // HMAC key used to verify user access tokens (HS256)
+    PlamatioWebFrontendApiKey string    // legacy shared API key; only accepted together with a user access token
 }
 
-// AuthHandler - authentication handler to validate API key for authenticated endpoints.
+// AuthHandler - authentication handler to validate the API client key and the user access token
+// for authenticated endpoints. The token subject is the ID of the authenticated user, and the
+// user roles are limited to the scopes of the API client. Without a user access token, the API
+// client calls on its own behalf with its scopes as roles.
 //encore:authhandler
-func AuthHandler(ctx context.Context, token string) (auth.UID, error) {
-    // Validate the token - confirm it matches the API key.
-		if token == secrets.PlamatioWebFrontendApiKey {
-			// Return nil if the token is valid.
-			return auth.UID("authenticated"), nil
+func AuthHandler(ctx context.Context, p *models.AuthParams) (auth.UID, *models.AuthData, error) {
+    // Validate the API key - confirm it belongs to an active API client.
+		client, err := authenticateApiClient(ctx, p.ApiKey)
+		if err != nil {
+			return "", nil, err
 		}
-		// Return an error if API key is invalid.
-		return "", &errs.Error{
-        Code: errs.Unauthenticated,
-        Message: "invalid API key",	
-    }
+		token := utils.BearerToken(p.Authorization)
+		if token == "" {
+			// The legacy API key cannot be used on its own.
+			if client.ID == 0 {
+				return "", nil, &errs.Error{
+					Code: errs.Unauthenticated,
+					Message: "user access token is required",
+				}
+			}
+			// Return the client as the caller.
+			return auth.UID("client:" + client.KeyPrefix), &models.AuthData{Roles: client.Scopes, ClientID: client.ID, ClientName: client.Name}, nil
+		}
+    // Validate the token - confirm it is signed with the signing key and not expired.
+		claims, err := utils.VerifyToken(token, []byte(secrets.PlamatioJwtSigningKey), time.Now())
+		if err != nil {
+			// Return an error if the token is invalid.
+			return "", nil, &errs.Error{
+				Code: errs.Unauthenticated,
+				Message: "invalid access token",
+			}
+		}
+		// Return the user ID and roles if the token is valid.
+		roles := utils.LimitRoles(utils.TokenRoles(claims), client.Scopes)
+		return auth.UID(claims.Subject), &models.AuthData{UserID: claims.Subject, Roles: roles, ClientID: client.ID, ClientName: client.Name}, nil
+}
+
+// ------------------------------------------------------
+// Setup Middleware
+
+// ApiClientAttribution - records the API client, and the user if any, that made each
+// authenticated call, along with the endpoint called and whether the call failed.
+//encore:middleware global target=all
+func ApiClientAttribution(req middleware.Request, next middleware.Next) middleware.Response {
+	resp := next(req)
+	data, ok := auth.Data().(*models.AuthData)
+	if !ok || data == nil {
+		return resp
+	}
+	r := req.Data()
+	rlog.Info("api client call",
+		"client_id", data.ClientID,
+		"client_name", data.ClientName,
+		"user_id", data.UserID,
+		"service", r.Service,
+		"endpoint", r.Endpoint,
+		"failed", resp.Err != nil,
+	)
+	return resp
 }
 
 // ------------------------------------------------------
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "encore.app/core/models"
+	"encore.dev/storage/sqldb"
+)
+
+type ApiClientsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_INSERT_API_CLIENT = `
+				INSERT INTO api_clients (name, key_prefix, key_hash, scopes, created_at, expires_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id
+		`
+		SQL_GET_API_CLIENT_BY_PREFIX = `
+				SELECT id, name, key_prefix, key_hash, scopes, created_at, expires_at, revoked FROM api_clients
+				WHERE key_prefix = $1
+		`
+		SQL_GET_ALL_API_CLIENTS = `
+				SELECT id, name, key_prefix, scopes, created_at, expires_at, revoked FROM api_clients
+				ORDER BY id
+		`
+		SQL_REVOKE_API_CLIENT = `
+				UPDATE api_clients SET revoked = TRUE
+				WHERE id = $1
+				RETURNING key_prefix
+		`
+)
+
+// Inserts an API client with the hash of its key into the database.
+func (tb *ApiClientsTable) Insert(ctx context.Context, c *models.ApiClient, keyHash string) (int, error) {
+	var id int
+	expiresAt := sql.NullTime{}
+	if c.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *c.ExpiresAt, Valid: true}
+	}
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_API_CLIENT, c.Name, c.KeyPrefix, keyHash, c.Scopes, c.CreatedAt, expiresAt).Scan(&id)
+	return id, err
+}
+
+// Retrieves an API client and the hash of its key from the database by key prefix.
+func (tb *ApiClientsTable) GetByPrefix(ctx context.Context, prefix string) (*models.ApiClientCredentials, error) {
+	c := &models.ApiClient{}
+	var keyHash string
+	var expiresAt sql.NullTime
+	err := tb.DB.QueryRow(ctx, SQL_GET_API_CLIENT_BY_PREFIX, prefix).Scan(&c.ID, &c.Name, &c.KeyPrefix, &keyHash, &c.Scopes, &c.CreatedAt, &expiresAt, &c.Revoked)
+	if err != nil {
+		return nil, err
+	}
+	c.ExpiresAt = nullTime(expiresAt)
+	return &models.ApiClientCredentials{Client: c, KeyHash: keyHash}, nil
+}
+
+// Retrieves all API clients from the database.
+func (tb *ApiClientsTable) GetAll(ctx context.Context) (*models.ApiClients, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_API_CLIENTS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := &models.ApiClients{}
+	for rows.Next() {
+		c := &models.ApiClient{}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Name, &c.KeyPrefix, &c.Scopes, &c.CreatedAt, &expiresAt, &c.Revoked); err != nil {
+			return nil, err
+		}
+		c.ExpiresAt = nullTime(expiresAt)
+		clients.Data = append(clients.Data, c)
+	}
+	return clients, nil
+}
+
+// Revokes an API client in the database, returning its key prefix.
+func (tb *ApiClientsTable) Revoke(ctx context.Context, id int) (string, error) {
+	var prefix string
+	err := tb.DB.QueryRow(ctx, SQL_REVOKE_API_CLIENT, id).Scan(&prefix)
+	return prefix, err
+}
+
+// nullTime converts a nullable database time into a time pointer.
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,45 @@
+-- Normalize existing free-text statuses to the defined order lifecycle. Known legacy
+-- spellings are mapped explicitly; any other status fails the migration so it can be
+-- reviewed rather than silently rewritten.
+UPDATE orders SET status = LOWER(TRIM(status));
+UPDATE orders SET status = CASE
+    WHEN status IN ('new', 'created', 'placed', 'open', 'awaiting payment', 'awaiting_payment') THEN 'pending'
+    WHEN status IN ('processing', 'payment received') THEN 'paid'
+    WHEN status IN ('packed', 'ready to ship', 'ready_to_ship') THEN 'fulfilled'
+    WHEN status IN ('dispatched', 'sent', 'in transit', 'in_transit') THEN 'shipped'
+    WHEN status IN ('complete', 'completed') THEN 'delivered'
+    WHEN status IN ('canceled') THEN 'cancelled'
+    WHEN status IN ('refund', 'refunded') THEN 'refunded'
+    ELSE status
+END;
+
+DO $$
+DECLARE
+    unknown TEXT;
+BEGIN
+    SELECT string_agg(DISTINCT status, ', ') INTO unknown FROM orders
+    WHERE status NOT IN ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded');
+    IF unknown IS NOT NULL THEN
+        RAISE EXCEPTION 'orders have unknown statuses: %', unknown;
+    END IF;
+END $$;
+
+ALTER TABLE orders
+ADD CONSTRAINT chk_orders_status
+CHECK (status IN ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded'));
+
+CREATE TABLE order_status_history (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    order_id BIGINT NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE INDEX idx_order_id_order_status_history ON order_status_history (order_id);
+
+-- Seed the history of existing orders with their current status.
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, changed_at)
+SELECT id, NULL, status, 'system', created_at FROM orders;
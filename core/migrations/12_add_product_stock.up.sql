@@ -0,0 +1,5 @@
+-- Existing products start with no stock; stock levels must be imported explicitly
+-- through the stock import endpoint before they can be ordered.
+ALTER TABLE products
+ADD COLUMN stock INT NOT NULL DEFAULT 0,
+ADD CONSTRAINT chk_products_stock CHECK (stock >= 0);
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_name_trgm_products ON products USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_name_trgm_categories ON categories USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_name_trgm_sub_categories ON sub_categories USING GIN (name gin_trgm_ops);
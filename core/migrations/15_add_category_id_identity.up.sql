@@ -0,0 +1,6 @@
+-- Generate IDs for new categories and sub-categories, continuing after the seeded rows.
+ALTER TABLE categories ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+SELECT setval(pg_get_serial_sequence('categories', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM categories), false);
+
+ALTER TABLE sub_categories ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+SELECT setval(pg_get_serial_sequence('sub_categories', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM sub_categories), false);
@@ -0,0 +1,9 @@
+CREATE TABLE wishlist_items (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    product_id BIGINT NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, product_id)
+);
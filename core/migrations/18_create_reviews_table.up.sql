@@ -0,0 +1,20 @@
+CREATE TABLE reviews (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    product_id BIGINT NOT NULL,
+    user_id TEXT NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (product_id, user_id)
+);
+
+CREATE INDEX idx_product_id_created_at_reviews ON reviews (product_id, created_at DESC, id DESC);
+
+-- Aggregate ratings, maintained alongside review writes.
+ALTER TABLE products
+ADD COLUMN average_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN review_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,31 @@
+CREATE TABLE promotions (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    code TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed', 'buy_x_get_y')),
+    percent_off INT NOT NULL DEFAULT 0,
+    amount_off INT NOT NULL DEFAULT 0,
+    buy_quantity INT NOT NULL DEFAULT 0,
+    get_quantity INT NOT NULL DEFAULT 0,
+    scope TEXT NOT NULL CHECK (scope IN ('all', 'category', 'sub_category', 'product')),
+    scope_id BIGINT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    usage_limit INT NOT NULL DEFAULT 0,
+    usage_count INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE order_discounts (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    order_id BIGINT NOT NULL,
+    promotion_id BIGINT NOT NULL,
+    code TEXT NOT NULL,
+    amount INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id)
+);
+
+CREATE INDEX idx_order_id_order_discounts ON order_discounts (order_id);
@@ -0,0 +1,30 @@
+CREATE TABLE product_prices (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    product_id BIGINT NOT NULL,
+    price INT NOT NULL,
+    previous_price INT NOT NULL,
+    actor TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_id_changed_at_product_prices ON product_prices (product_id, changed_at, id);
+
+CREATE TABLE scheduled_prices (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    product_id BIGINT NOT NULL,
+    price INT NOT NULL CHECK (price > 0),
+    effective_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'applied', 'cancelled')),
+    actor TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_status_effective_at_scheduled_prices ON scheduled_prices (status, effective_at);
+CREATE INDEX idx_product_id_scheduled_prices ON scheduled_prices (product_id);
+
+-- Start the history of existing products at their current price.
+INSERT INTO product_prices (product_id, price, previous_price, actor)
+SELECT id, price, previous_price, 'system' FROM products;
@@ -0,0 +1,8 @@
+-- Order totals are computed from product prices in cents; round away any
+-- fractions of a cent accumulated while the column was a FLOAT.
+ALTER TABLE orders
+ALTER COLUMN total_price TYPE BIGINT USING ROUND(total_price)::BIGINT,
+ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE products
+ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
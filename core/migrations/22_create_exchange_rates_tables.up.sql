@@ -0,0 +1,27 @@
+CREATE TABLE exchange_rates (
+    base_currency TEXT NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    quote_currency TEXT NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+    rate DOUBLE PRECISION NOT NULL CHECK (rate > 0),
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (base_currency, quote_currency),
+    CHECK (base_currency <> quote_currency)
+);
+
+CREATE TABLE product_price_overrides (
+    product_id BIGINT NOT NULL,
+    currency TEXT NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    price INT NOT NULL CHECK (price > 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, currency),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Orders record the currency of the products they were priced from and the rate
+-- used to convert those prices into the order currency. The rate is NULL for orders
+-- priced entirely from price overrides set for the order currency, without a rate.
+ALTER TABLE orders
+ADD COLUMN base_currency TEXT NOT NULL DEFAULT 'USD' CHECK (base_currency ~ '^[A-Z]{3}$'),
+ADD COLUMN exchange_rate DOUBLE PRECISION DEFAULT 1 CHECK (exchange_rate > 0);
+
+UPDATE orders SET base_currency = currency;
@@ -0,0 +1,39 @@
+CREATE TABLE tax_rates (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    country TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    rate DOUBLE PRECISION NOT NULL CHECK (rate >= 0 AND rate < 1),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (country, state)
+);
+
+CREATE TABLE tax_exemptions (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    country TEXT NOT NULL,
+    state TEXT NOT NULL DEFAULT '',
+    category_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+    UNIQUE (country, state, category_id)
+);
+
+CREATE TABLE order_tax_lines (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    order_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    quantity INT NOT NULL,
+    taxable_amount BIGINT NOT NULL,
+    rate DOUBLE PRECISION NOT NULL,
+    tax_amount BIGINT NOT NULL,
+    exempt BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_order_id_order_tax_lines ON order_tax_lines (order_id);
+
+-- Order-level tax, included in total_price.
+ALTER TABLE orders
+ADD COLUMN tax_amount BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN tax_country TEXT NOT NULL DEFAULT '',
+ADD COLUMN tax_state TEXT NOT NULL DEFAULT '';
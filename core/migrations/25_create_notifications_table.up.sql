@@ -0,0 +1,19 @@
+-- Emails sent to customers about their orders, with their delivery state. An order
+-- gets at most one email of each kind, so redelivered events do not send duplicates.
+CREATE TABLE notifications (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id TEXT NOT NULL,
+    order_id BIGINT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('order_placed', 'order_shipped', 'order_delivered', 'order_cancelled')),
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP,
+    UNIQUE (order_id, kind)
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at DESC);
@@ -0,0 +1,23 @@
+ALTER TABLE cart_items
+ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Carts that sat idle past the abandoned cart threshold. A cart is recorded once per
+-- last activity, so it is only reported again if it is changed and abandoned again.
+CREATE TABLE abandoned_carts (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id TEXT NOT NULL,
+    last_activity_at TIMESTAMP NOT NULL,
+    item_count INT NOT NULL,
+    detected_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, last_activity_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Abandoned cart reminders are notifications about a cart rather than an order.
+ALTER TABLE notifications
+ALTER COLUMN order_id DROP NOT NULL,
+ADD COLUMN abandoned_cart_id BIGINT REFERENCES abandoned_carts(id) ON DELETE CASCADE,
+ADD CONSTRAINT notifications_abandoned_cart_id_kind_key UNIQUE (abandoned_cart_id, kind),
+DROP CONSTRAINT notifications_kind_check,
+ADD CONSTRAINT notifications_kind_check CHECK (kind IN ('order_placed', 'order_shipped', 'order_delivered', 'order_cancelled', 'cart_abandoned'));
@@ -0,0 +1,5 @@
+-- The unit price each item was ordered at, in minor units of the order currency, so
+-- changing one item of an order does not reprice the others. Items ordered before it
+-- was recorded have no unit price until their order is next repriced.
+ALTER TABLE order_items
+ADD COLUMN unit_price INT;
@@ -0,0 +1,91 @@
+package core
+
+import "time"
+
+// Roles that may be granted to a user through their access token.
+const (
+	RoleCustomer     = "customer"      // manages their own cart, orders and user data
+	RoleSupport      = "support"       // reads and manages customer data and moves orders through fulfillment
+	RoleCatalogAdmin = "catalog-admin" // manages products, stock, categories and sub-categories
+	RoleSuperAdmin   = "super-admin"   // has every role
+)
+
+// DefaultCurrency is the currency of prices and orders created without an explicit currency.
+const DefaultCurrency = "USD"
+
+// Money represents an amount of money as an integer number of minor units (e.g. cents)
+// in the given currency.
+type Money struct {
+	Amount   int    `json:"amount"`   // amount in minor units of the currency
+	Currency string `json:"currency"` // ISO 4217 currency code, e.g. "USD"
+}
+
+// ConvertedMoney represents an amount converted into another currency.
+type ConvertedMoney struct {
+	Amount   int     `json:"amount"`   // converted amount in minor units of the currency
+	Currency string  `json:"currency"` // ISO 4217 currency code the amount was converted to
+	Rate     float64 `json:"rate"`     // exchange rate used, in units of Currency per unit of the original currency
+}
+
+// AuthData represents the authenticated caller, available to endpoints through auth.Data().
+type AuthData struct {
+	UserID     string   `json:"userId"`     // ID of the authenticated user; empty when the client calls on its own behalf
+	Roles      []string `json:"roles"`      // roles the caller may exercise
+	ClientID   int      `json:"clientId"`   // ID of the calling API client
+	ClientName string   `json:"clientName"` // name of the calling API client
+}
+
+// TokenClaims represents the claims of a user access token.
+type TokenClaims struct {
+	Subject   string   `json:"sub"`             // ID of the user the token was issued to
+	Roles     []string `json:"roles,omitempty"` // roles granted to the user; customer if empty
+	ExpiresAt int64    `json:"exp"`             // expiry time, in seconds since the Unix epoch
+	NotBefore int64    `json:"nbf,omitempty"`   // time before which the token is not valid, in seconds since the Unix epoch
+	IssuedAt  int64    `json:"iat,omitempty"`   // issue time, in seconds since the Unix epoch
+}
+
+// AuthParams represents the credentials of an authenticated request.
+type AuthParams struct {
+	ApiKey        string `header:"X-Api-Key"`     // key of the calling API client
+	Authorization string `header:"Authorization"` // optional user access token, as "Bearer <token>"
+}
+
+// ApiClient represents an application (e.g. web frontend, mobile app, internal job) allowed to call the API.
+type ApiClient struct {
+	ID        int        `json:"id"`        // unique identifier
+	Name      string     `json:"name"`      // name of the client
+	KeyPrefix string     `json:"keyPrefix"` // public prefix of the client key, used to look up the client
+	Scopes    []string   `json:"scopes"`    // roles the client may exercise
+	CreatedAt time.Time  `json:"createdAt"` // time the key was issued
+	ExpiresAt *time.Time `json:"expiresAt"` // time the key expires; never if nil
+	Revoked   bool       `json:"revoked"`   // whether the key has been revoked
+}
+
+// ApiClients represents a collection of API clients.
+type ApiClients struct {
+	Data []*ApiClient `json:"data"`
+}
+
+// ApiClientCredentials represents an API client together with the hash of its key.
+type ApiClientCredentials struct {
+	Client  *ApiClient `json:"client"`
+	KeyHash string     `json:"keyHash"`
+}
+
+// ApiClientRequestParams represents the request parameters for issuing an API client key.
+type ApiClientRequestParams struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// IssuedApiClient represents a newly issued API client. The key is only returned once.
+type IssuedApiClient struct {
+	Client *ApiClient `json:"client"`
+	Key    string     `json:"key"`
+}
+
+// Return type for mutations to API clients.
+type ApiClientChangeRequestReturn struct {
+	ID int `json:"id"`
+}
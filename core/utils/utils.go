@@ -0,0 +1,286 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	models "encore.app/core/models"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+)
+
+// tokenHeader represents the header of a user access token.
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// VerifyToken verifies a JWT signed with HS256 using the given key and returns its claims.
+// The token must carry a subject and an expiry, and must be valid at the given time.
+func VerifyToken(token string, key []byte, now time.Time) (*models.TokenClaims, error) {
+	if len(key) == 0 {
+		return nil, errors.New("token signing key is not configured")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	// Verify the header; only HS256 is accepted.
+	h := &tokenHeader{}
+	if err := decodeTokenPart(parts[0], h); err != nil {
+		return nil, err
+	}
+	if h.Alg != "HS256" {
+		return nil, errors.New("unsupported token algorithm")
+	}
+	// Verify the signature.
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+	// Verify the claims.
+	c := &models.TokenClaims{}
+	if err := decodeTokenPart(parts[1], c); err != nil {
+		return nil, err
+	}
+	if c.Subject == "" {
+		return nil, errors.New("token subject is required")
+	}
+	if c.ExpiresAt == 0 || now.Unix() >= c.ExpiresAt {
+		return nil, errors.New("token has expired")
+	}
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return nil, errors.New("token is not yet valid")
+	}
+	return c, nil
+}
+
+// decodeTokenPart decodes a base64url-encoded JSON token part into v.
+func decodeTokenPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// IsValidRole checks whether the given role is one of the known roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case models.RoleCustomer, models.RoleSupport, models.RoleCatalogAdmin, models.RoleSuperAdmin:
+		return true
+	}
+	return false
+}
+
+// TokenRoles returns the known roles granted by the token claims, defaulting to customer.
+func TokenRoles(c *models.TokenClaims) []string {
+	var roles []string
+	for _, r := range c.Roles {
+		if IsValidRole(r) {
+			roles = append(roles, r)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{models.RoleCustomer}
+	}
+	return roles
+}
+
+// HasRole checks whether the authenticated caller has any of the given roles.
+// A super-admin has every role.
+func HasRole(roles ...string) bool {
+	d, ok := auth.Data().(*models.AuthData)
+	if !ok || d == nil {
+		return false
+	}
+	for _, granted := range d.Roles {
+		if granted == models.RoleSuperAdmin {
+			return true
+		}
+		for _, r := range roles {
+			if granted == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireRole returns a PermissionDenied error unless the authenticated caller has any of the given roles.
+func RequireRole(roles ...string) error {
+	if HasRole(roles...) {
+		return nil
+	}
+	return &errs.Error{
+		Code:    errs.PermissionDenied,
+		Message: fmt.Sprintf("requires one of the roles: %s", strings.Join(roles, ", ")),
+	}
+}
+
+// RequireUser returns a PermissionDenied error unless the authenticated caller is the given user,
+// or has the support role.
+func RequireUser(userID string) error {
+	if uid, ok := auth.UserID(); ok && userID != "" && string(uid) == userID {
+		return nil
+	}
+	if HasRole(models.RoleSupport) {
+		return nil
+	}
+	return &errs.Error{
+		Code:    errs.PermissionDenied,
+		Message: "not allowed to access resources of another user",
+	}
+}
+
+// LimitRoles limits the user roles to the scopes of the calling API client.
+// A client with the super-admin scope does not limit the user roles.
+func LimitRoles(roles []string, scopes []string) []string {
+	allowed := map[string]bool{}
+	for _, s := range scopes {
+		allowed[s] = true
+	}
+	if allowed[models.RoleSuperAdmin] {
+		return roles
+	}
+	var limited []string
+	for _, s := range scopes {
+		for _, r := range roles {
+			if r == s || r == models.RoleSuperAdmin {
+				limited = append(limited, s)
+				break
+			}
+		}
+	}
+	return limited
+}
+
+// BearerToken returns the token of a "Bearer <token>" authorization header, or an empty string.
+func BearerToken(authorization string) string {
+	const prefix = "Bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
+
+// GenerateApiKey generates a new API client key of the form "plm_<prefix>_<secret>",
+// returning the key and its public prefix.
+func GenerateApiKey() (string, string, error) {
+	p := make([]byte, 8)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(p)
+	return "plm_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// ApiKeyPrefix returns the public prefix of an API client key.
+func ApiKeyPrefix(key string) (string, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != "plm" || len(parts[1]) != 16 || parts[2] == "" {
+		return "", errors.New("malformed API key")
+	}
+	return parts[1], nil
+}
+
+// HashApiKey returns the hex-encoded SHA-256 hash of an API client key.
+func HashApiKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// MatchesApiKey checks, in constant time, whether the key matches the stored key hash.
+func MatchesApiKey(key string, keyHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashApiKey(key)), []byte(keyHash)) == 1
+}
+
+// IsActiveApiClient checks whether the API client is neither revoked nor expired at the given time.
+func IsActiveApiClient(c *models.ApiClient, now time.Time) bool {
+	return !c.Revoked && (c.ExpiresAt == nil || now.Before(*c.ExpiresAt))
+}
+
+func ValidateApiClientRequestParams(p *models.ApiClientRequestParams) error {
+	// validate the API client request parameters
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("client name is required")
+	}
+	if len(p.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, s := range p.Scopes {
+		if !IsValidRole(s) {
+			return fmt.Errorf("invalid scope %q", s)
+		}
+	}
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+// ValidateCurrency checks that the given code looks like an ISO 4217 currency code.
+func ValidateCurrency(code string) error {
+	if len(code) != 3 || strings.ToUpper(code) != code || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("invalid currency %q; should be an ISO 4217 code such as USD", code)
+	}
+	return nil
+}
+
+// CommonCurrency returns the currency shared by all of the given currency codes, ignoring
+// empty codes, or DefaultCurrency if all are empty. Mixed currencies are rejected.
+func CommonCurrency(currencies ...string) (string, error) {
+	common := ""
+	for _, c := range currencies {
+		if c == "" || c == common {
+			continue
+		}
+		if common != "" {
+			return "", fmt.Errorf("mixed currencies %s and %s; all amounts must be in the same currency", common, c)
+		}
+		common = c
+	}
+	if common == "" {
+		return models.DefaultCurrency, nil
+	}
+	return common, nil
+}
+
+// CurrencyExponent returns the number of decimal places of the minor unit of the given currency.
+func CurrencyExponent(code string) int {
+	switch code {
+	case "BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF", "UGX", "VND", "VUV", "XAF", "XOF", "XPF":
+		return 0
+	case "BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND":
+		return 3
+	}
+	return 2
+}
+
+// ConvertAmount converts an amount in minor units of one currency into minor units of
+// another at the given rate, in units of to per unit of from, rounding to the nearest minor unit.
+func ConvertAmount(amount int, from string, to string, rate float64) int {
+	return int(math.Round(float64(amount) * rate * math.Pow10(CurrencyExponent(to)-CurrencyExponent(from))))
+}
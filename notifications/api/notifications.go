@@ -0,0 +1,228 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	cart "encore.app/cart/api"
+	cartmodels "encore.app/cart/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/notifications/db"
+	mailer "encore.app/notifications/mailer"
+	models "encore.app/notifications/models"
+	utils "encore.app/notifications/utils"
+	orders "encore.app/orders/api"
+	ordersmodels "encore.app/orders/models"
+	usersdb "encore.app/users/db"
+	"encore.dev/pubsub"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// NotificationsTable instance.
+var NotificationsTable = &db.NotificationsTable{DB: PlamatioDB}
+
+// UsersTable instance, used to find the email address of customers.
+var UsersTable = &usersdb.UsersTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup Mailer
+
+// secrets struct for sending emails.
+var secrets struct {
+	NotificationsSmtpAddr     string // host:port of the SMTP server; emails are not sent if empty
+	NotificationsSmtpUsername string // SMTP username; no authentication if empty
+	NotificationsSmtpPassword string // SMTP password
+	NotificationsFromEmail    string // address emails are sent from
+}
+
+// Mailer sends the notification emails. It is configured as an SMTP mailer from the
+// secrets on first use.
+var Mailer mailer.Mailer
+
+var mailerOnce sync.Once
+
+// errNoMailer is returned when notification emails cannot be sent because no SMTP
+// server is configured. The notification is recorded as failed, not sent.
+var errNoMailer = errors.New("no SMTP server configured")
+
+// getMailer returns the mailer used to send notification emails.
+func getMailer() (mailer.Mailer, error) {
+	mailerOnce.Do(func() {
+		if Mailer != nil || secrets.NotificationsSmtpAddr == "" {
+			return
+		}
+		Mailer = &mailer.SMTPMailer{
+			Addr:     secrets.NotificationsSmtpAddr,
+			Username: secrets.NotificationsSmtpUsername,
+			Password: secrets.NotificationsSmtpPassword,
+			From:     secrets.NotificationsFromEmail,
+		}
+	})
+	if Mailer == nil {
+		return nil, errNoMailer
+	}
+	return Mailer, nil
+}
+
+// ------------------------------------------------------
+// Setup Subscriptions
+
+// notificationRetryPolicy retries failed sends with backoff, for about a day.
+var notificationRetryPolicy = &pubsub.RetryPolicy{
+	MinBackoff: 30 * time.Second,
+	MaxBackoff: 2 * time.Hour,
+	MaxRetries: 20,
+}
+
+// Sends an order placed email for every order placed.
+var _ = pubsub.NewSubscription(orders.OrderCreatedTopic, "notify-order-placed", pubsub.SubscriptionConfig[*ordersmodels.OrderCreatedEvent]{
+	Handler:     NotifyOrderPlaced,
+	RetryPolicy: notificationRetryPolicy,
+})
+
+// Sends an email when an order ships, is delivered or is cancelled.
+var _ = pubsub.NewSubscription(orders.OrderStatusChangedTopic, "notify-order-status-changed", pubsub.SubscriptionConfig[*ordersmodels.OrderStatusChangedEvent]{
+	Handler:     NotifyOrderStatusChanged,
+	RetryPolicy: notificationRetryPolicy,
+})
+
+// Sends a reminder email for every abandoned cart.
+var _ = pubsub.NewSubscription(cart.CartAbandonedTopic, "notify-cart-abandoned", pubsub.SubscriptionConfig[*cartmodels.CartAbandonedEvent]{
+	Handler:     NotifyCartAbandoned,
+	RetryPolicy: notificationRetryPolicy,
+})
+
+// NotifyOrderPlaced emails the customer that their order has been placed.
+func NotifyOrderPlaced(ctx context.Context, event *ordersmodels.OrderCreatedEvent) error {
+	itemCount := 0
+	for _, item := range event.Items {
+		itemCount += item.Quantity
+	}
+	return notifyOrder(ctx, models.NotificationOrderPlaced, event.Order, itemCount)
+}
+
+// NotifyOrderStatusChanged emails the customer when their order ships, is delivered
+// or is cancelled. Other status changes are ignored.
+func NotifyOrderStatusChanged(ctx context.Context, event *ordersmodels.OrderStatusChangedEvent) error {
+	kind, ok := utils.NotificationKindForStatus(event.ToStatus)
+	if !ok {
+		return nil
+	}
+	return notifyOrder(ctx, kind, event.Order, 0)
+}
+
+// notifyOrder renders and sends the notification email of the given kind to the customer
+// who placed the order, recording the attempt and its delivery state. An order gets at
+// most one email of each kind; a failed send returns an error so the event is redelivered.
+func notifyOrder(ctx context.Context, kind string, order *ordersmodels.Order, itemCount int) error {
+	// Retrieve the customer.
+	user, err := UsersTable.GetUser(ctx, order.UserID)
+	if errors.Is(err, sqldb.ErrNoRows) || (err == nil && user.Email == "") {
+		// log error
+		rlog.Warn("No email address to notify", "user_id", order.UserID, "order_id", order.ID, "kind", kind)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Render the email.
+	subject, body, err := utils.RenderEmail(kind, &models.OrderEmailData{
+		FirstName: user.FirstName,
+		OrderID:   order.ID,
+		Status:    order.Status,
+		Total:     utils.FormatMoney(order.Total),
+		ItemCount: itemCount,
+	})
+	if err != nil {
+		return err
+	}
+	// Record the attempt, unless the email has already been sent.
+	n := &models.Notification{UserID: order.UserID, OrderID: order.ID, Kind: kind, Recipient: user.Email, Subject: subject, Body: body}
+	id, err := NotificationsTable.Claim(ctx, n)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return send(ctx, id, n)
+}
+
+// NotifyCartAbandoned emails the customer a reminder about the items left in their cart.
+// A cart gets at most one reminder each time it is abandoned; a failed send returns an
+// error so the event is redelivered.
+func NotifyCartAbandoned(ctx context.Context, event *cartmodels.CartAbandonedEvent) error {
+	itemCount := 0
+	for _, item := range event.Items {
+		itemCount += item.Quantity
+	}
+	if itemCount == 0 {
+		return nil
+	}
+	// Retrieve the customer.
+	user, err := UsersTable.GetUser(ctx, event.UserID)
+	if errors.Is(err, sqldb.ErrNoRows) || (err == nil && user.Email == "") {
+		// log error
+		rlog.Warn("No email address to notify", "user_id", event.UserID, "abandoned_cart_id", event.AbandonedCartID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Render the email.
+	kind := models.NotificationCartAbandoned
+	subject, body, err := utils.RenderEmail(kind, &models.CartEmailData{FirstName: user.FirstName, ItemCount: itemCount})
+	if err != nil {
+		return err
+	}
+	// Record the attempt, unless the email has already been sent.
+	n := &models.Notification{UserID: event.UserID, AbandonedCartID: event.AbandonedCartID, Kind: kind, Recipient: user.Email, Subject: subject, Body: body}
+	id, err := NotificationsTable.ClaimCart(ctx, n)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return send(ctx, id, n)
+}
+
+// send sends the claimed notification with the given ID and records its delivery state.
+func send(ctx context.Context, id int, n *models.Notification) error {
+	m, err := getMailer()
+	if err == nil {
+		err = m.Send(ctx, &mailer.Message{To: n.Recipient, Subject: n.Subject, Body: n.Body})
+	}
+	if err != nil {
+		// log error
+		rlog.Error("Error sending notification email", "id", id, "kind", n.Kind, "err", err)
+		if err := NotificationsTable.MarkFailed(ctx, id, err.Error()); err != nil {
+			rlog.Error("Error recording failed notification", "id", id, "err", err)
+		}
+		return err
+	}
+	return NotificationsTable.MarkSent(ctx, id, time.Now())
+}
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /notifications/user/:user_id
+// Retrieves the notifications sent to a user, newest first.
+//encore:api auth method=GET path=/notifications/user/:user_id
+func GetUserNotifications(ctx context.Context, user_id string) (*models.Notifications, error) {
+	// Confirm the caller is the user.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	return NotificationsTable.GetByUser(ctx, user_id)
+}
@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/notifications/models"
+	"encore.dev/storage/sqldb"
+)
+
+type NotificationsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_CLAIM_NOTIFICATION = `
+				INSERT INTO notifications (user_id, order_id, kind, recipient, subject, body, attempts, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
+				ON CONFLICT (order_id, kind)
+				DO UPDATE SET recipient = EXCLUDED.recipient, subject = EXCLUDED.subject, body = EXCLUDED.body,
+					status = 'pending', attempts = notifications.attempts + 1
+				WHERE notifications.status <> 'sent'
+				RETURNING id
+		`
+		SQL_CLAIM_CART_NOTIFICATION = `
+				INSERT INTO notifications (user_id, abandoned_cart_id, kind, recipient, subject, body, attempts, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
+				ON CONFLICT (abandoned_cart_id, kind)
+				DO UPDATE SET recipient = EXCLUDED.recipient, subject = EXCLUDED.subject, body = EXCLUDED.body,
+					status = 'pending', attempts = notifications.attempts + 1
+				WHERE notifications.status <> 'sent'
+				RETURNING id
+		`
+		SQL_MARK_NOTIFICATION_SENT = `
+				UPDATE notifications SET status = 'sent', sent_at = $1, last_error = ''
+				WHERE id = $2
+		`
+		SQL_MARK_NOTIFICATION_FAILED = `
+				UPDATE notifications SET status = 'failed', last_error = $1
+				WHERE id = $2
+		`
+		SQL_GET_NOTIFICATIONS_BY_USER = `
+				SELECT id, user_id, COALESCE(order_id, 0), COALESCE(abandoned_cart_id, 0), kind, recipient, subject, body, status, attempts, last_error, created_at, sent_at FROM notifications
+				WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC
+		`
+)
+
+// Records an attempt to send a notification, creating it if the order has no
+// notification of its kind yet. Returns the ID of the notification, or
+// sqldb.ErrNoRows if the notification has already been sent.
+func (tb *NotificationsTable) Claim(ctx context.Context, n *models.Notification) (int, error) {
+	var id int
+	err := tb.DB.QueryRow(ctx, SQL_CLAIM_NOTIFICATION, n.UserID, n.OrderID, n.Kind, n.Recipient, n.Subject, n.Body, time.Now()).Scan(&id)
+	return id, err
+}
+
+// Records an attempt to send a notification about an abandoned cart, creating it if the
+// cart has no notification of its kind yet. Returns the ID of the notification, or
+// sqldb.ErrNoRows if the notification has already been sent.
+func (tb *NotificationsTable) ClaimCart(ctx context.Context, n *models.Notification) (int, error) {
+	var id int
+	err := tb.DB.QueryRow(ctx, SQL_CLAIM_CART_NOTIFICATION, n.UserID, n.AbandonedCartID, n.Kind, n.Recipient, n.Subject, n.Body, time.Now()).Scan(&id)
+	return id, err
+}
+
+// Marks a notification as sent.
+func (tb *NotificationsTable) MarkSent(ctx context.Context, id int, now time.Time) error {
+	_, err := tb.DB.Exec(ctx, SQL_MARK_NOTIFICATION_SENT, now, id)
+	return err
+}
+
+// Marks a notification as failed, recording the error of the attempt.
+func (tb *NotificationsTable) MarkFailed(ctx context.Context, id int, lastError string) error {
+	_, err := tb.DB.Exec(ctx, SQL_MARK_NOTIFICATION_FAILED, lastError, id)
+	return err
+}
+
+// Retrieves all notifications sent to a user, newest first.
+func (tb *NotificationsTable) GetByUser(ctx context.Context, userId string) (*models.Notifications, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_NOTIFICATIONS_BY_USER, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		n := &models.Notification{}
+		if err := rows.Scan(&n.ID, &n.UserID, &n.OrderID, &n.AbandonedCartID, &n.Kind, &n.Recipient, &n.Subject, &n.Body, &n.Status, &n.Attempts, &n.LastError, &n.CreatedAt, &n.SentAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return &models.Notifications{Data: notifications}, rows.Err()
+}
@@ -0,0 +1,57 @@
+package notifications
+
+import "time"
+
+// Kinds of notification emails sent to customers about their orders and carts.
+const (
+	NotificationOrderPlaced    = "order_placed"    // the order was placed
+	NotificationOrderShipped   = "order_shipped"   // the order was handed over to the carrier
+	NotificationOrderDelivered = "order_delivered" // the order was delivered
+	NotificationOrderCancelled = "order_cancelled" // the order was cancelled
+	NotificationCartAbandoned  = "cart_abandoned"  // the cart was left idle past the abandoned cart threshold
+)
+
+// Delivery states of a notification.
+const (
+	NotificationStatusPending = "pending" // being sent
+	NotificationStatusSent    = "sent"    // accepted by the mail server
+	NotificationStatusFailed  = "failed"  // the last attempt to send it failed; it is retried on redelivery
+)
+
+// Notification represents an email sent, or being sent, to a customer about an order
+// or an abandoned cart.
+type Notification struct {
+	ID              int        `json:"id"`
+	UserID          string     `json:"userId"`
+	OrderID         int        `json:"orderId"`         // 0 if the notification is not about an order
+	AbandonedCartID int        `json:"abandonedCartId"` // 0 if the notification is not about an abandoned cart
+	Kind      string     `json:"kind"`      // one of the notification kinds
+	Recipient string     `json:"recipient"` // email address the notification is sent to
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Status    string     `json:"status"`    // one of the notification delivery states
+	Attempts  int        `json:"attempts"`  // number of attempts to send the notification
+	LastError string     `json:"lastError"` // error of the last failed attempt, if any
+	CreatedAt time.Time  `json:"createdAt"`
+	SentAt    *time.Time `json:"sentAt"`    // when the notification was sent; nil until then
+}
+
+// Notifications represents a collection of notifications.
+type Notifications struct {
+	Data []*Notification `json:"data"`
+}
+
+// OrderEmailData represents the data an order notification email is rendered from.
+type OrderEmailData struct {
+	FirstName string // first name of the customer
+	OrderID   int
+	Status    string // status of the order
+	Total     string // formatted order total, e.g. "12.50 USD"
+	ItemCount int    // number of units ordered; 0 if unknown
+}
+
+// CartEmailData represents the data an abandoned cart reminder email is rendered from.
+type CartEmailData struct {
+	FirstName string // first name of the customer
+	ItemCount int    // number of units left in the cart
+}
@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	models "encore.app/notifications/models"
+	ordersmodels "encore.app/orders/models"
+)
+
+// emailTemplate holds the subject and body templates of a kind of notification email.
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// emailTemplates maps each notification kind to its templates.
+var emailTemplates = map[string]emailTemplate{
+	models.NotificationOrderPlaced: newEmailTemplate(
+		"Your Plamatio order #{{.OrderID}} has been placed",
+		`Hi {{.FirstName}},
+
+Thank you for your order! We have received order #{{.OrderID}}{{if .ItemCount}} for {{.ItemCount}} item(s){{end}}, totalling {{.Total}}.
+
+We will let you know as soon as it ships.
+
+The Plamatio Team
+`),
+	models.NotificationOrderShipped: newEmailTemplate(
+		"Your Plamatio order #{{.OrderID}} has shipped",
+		`Hi {{.FirstName}},
+
+Good news: order #{{.OrderID}} is on its way.
+
+The Plamatio Team
+`),
+	models.NotificationOrderDelivered: newEmailTemplate(
+		"Your Plamatio order #{{.OrderID}} has been delivered",
+		`Hi {{.FirstName}},
+
+Order #{{.OrderID}} has been delivered. We hope you enjoy it!
+
+The Plamatio Team
+`),
+	models.NotificationOrderCancelled: newEmailTemplate(
+		"Your Plamatio order #{{.OrderID}} has been cancelled",
+		`Hi {{.FirstName}},
+
+Order #{{.OrderID}}, totalling {{.Total}}, has been cancelled. If you have already paid, you will be refunded.
+
+The Plamatio Team
+`),
+	models.NotificationCartAbandoned: newEmailTemplate(
+		"You left something in your Plamatio cart",
+		`Hi {{.FirstName}},
+
+You still have {{.ItemCount}} item(s) waiting in your cart. Come back and complete your order before they sell out!
+
+The Plamatio Team
+`),
+}
+
+// newEmailTemplate parses the subject and body templates of a kind of notification email.
+func newEmailTemplate(subject string, body string) emailTemplate {
+	return emailTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// RenderEmail renders the subject and body of the notification email of the given kind
+// from its data: an OrderEmailData for order emails, a CartEmailData for cart emails.
+func RenderEmail(kind string, data any) (string, string, error) {
+	t, ok := emailTemplates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification kind %q", kind)
+	}
+	var subject, body strings.Builder
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+// NotificationKindForStatus returns the kind of notification email sent when an order
+// moves to the given status, and false if no email is sent for it.
+func NotificationKindForStatus(status string) (string, bool) {
+	switch status {
+	case ordersmodels.OrderStatusShipped:
+		return models.NotificationOrderShipped, true
+	case ordersmodels.OrderStatusDelivered:
+		return models.NotificationOrderDelivered, true
+	case ordersmodels.OrderStatusCancelled:
+		return models.NotificationOrderCancelled, true
+	}
+	return "", false
+}
+
+// FormatMoney formats an amount in minor units with its currency code, e.g. "12.50 USD".
+func FormatMoney(m coremodels.Money) string {
+	exponent := coreutils.CurrencyExponent(m.Currency)
+	if exponent == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	unit := 1
+	for i := 0; i < exponent; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, exponent, amount%unit, m.Currency)
+}
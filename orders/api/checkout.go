@@ -0,0 +1,101 @@
+package orders
+
+import (
+	"context"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	cart "encore.app/cart/api"
+	cartdb "encore.app/cart/db"
+	models "encore.app/orders/models"
+	utils "encore.app/orders/utils"
+	productsdb "encore.app/products/db"
+	"encore.dev/beta/errs"
+	rlog "encore.dev/rlog"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// CartItemsTable instance, used to read and clear the user's cart during checkout.
+var CartItemsTable = &cartdb.CartItemsTable{DB: PlamatioDB}
+
+// ProductsTB instance, used to price order items.
+var ProductsTB = &productsdb.ProductsTB{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+// POST: /orders/checkout
+// Converts the user's cart into an order. The cart is read, every line is priced
+// from the products table, the order and its items are inserted and the cart is
+// emptied in a single database transaction.
+//encore:api auth method=POST path=/orders/checkout
+func Checkout(ctx context.Context, params *models.CheckoutRequestParams) (*models.DetailedOrder, error) {
+	// validate request data
+	if err := utils.ValidateCheckoutData(params); err != nil {
+		return nil, err
+	}
+	// Confirm the caller owns the cart.
+	if err := coreutils.RequireUser(params.UserID); err != nil {
+		return nil, err
+	}
+	// Start the transaction. Nothing is committed unless every step succeeds.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the user's cart items.
+	cartItems, err := CartItemsTable.GetCartItemsByUserTx(ctx, tx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cartItems.Data) == 0 {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "cart is empty",
+		}
+	}
+	// Convert cart items to order items.
+	var items []*models.DetailedOrderItemRequestParams
+	for _, ci := range cartItems.Data {
+		items = append(items, &models.DetailedOrderItemRequestParams{ProductID: ci.ProductID, Quantity: ci.Quantity})
+	}
+	// Insert the order and its items.
+	order := &models.OrderRequestParams{UserID: params.UserID, AddressID: params.AddressID, Total: coremodels.Money{Currency: params.Currency}, Status: models.OrderStatusPending}
+	detailedOrder, err := insertDetailedOrderTx(ctx, tx, order, items, params.CouponCode)
+	if err != nil {
+		return nil, err
+	}
+	// Empty the user's cart.
+	if err := CartItemsTable.DeleteCartItemsByUserTx(ctx, tx, params.UserID); err != nil {
+		return nil, err
+	}
+	// Record the emptied cart in the outbox.
+	if err := enqueueCartClearedTx(ctx, tx, params.UserID, cartItems.Data); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fire a go routine to invalidate the cache for the user's orders and cart items.
+	go func() {
+		// Invalidate the cache for the user's orders.
+		if _, err := UserOrdersCacheKeyspace.Delete(ctx, params.UserID); err != nil {
+			// log error
+			rlog.Error("Error deleting user orders cache", err)
+		}
+		// Invalidate the cache for the user's cart items.
+		if err := cart.InvalidateCartCache(ctx, params.UserID); err != nil {
+			// log error
+			rlog.Error("Error deleting user cart items cache", err)
+		}
+	}()
+
+	// Return the detailed order.
+	return detailedOrder, nil
+}
@@ -0,0 +1,48 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	models "encore.app/orders/models"
+	productsdb "encore.app/products/db"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// ExchangeRatesTB instance, used to display order totals in other currencies.
+var ExchangeRatesTB = &productsdb.ExchangeRatesTB{DB: PlamatioDB}
+
+// displayOrderTotal converts the order total into the given currency at the current exchange
+// rate. Returns nil if no currency is given.
+func displayOrderTotal(ctx context.Context, currency string, order *models.Order) (*coremodels.ConvertedMoney, error) {
+	if currency == "" {
+		return nil, nil
+	}
+	if err := coreutils.ValidateCurrency(currency); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	if currency == order.Total.Currency {
+		return &coremodels.ConvertedMoney{Amount: order.Total.Amount, Currency: currency, Rate: 1}, nil
+	}
+	rate, err := ExchangeRatesTB.GetRate(ctx, order.Total.Currency, currency)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("no exchange rate from %s to %s", order.Total.Currency, currency),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &coremodels.ConvertedMoney{Amount: coreutils.ConvertAmount(order.Total.Amount, order.Total.Currency, currency, rate), Currency: currency, Rate: rate}, nil
+}
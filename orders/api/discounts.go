@@ -0,0 +1,32 @@
+package orders
+
+import (
+	"context"
+
+	db "encore.app/orders/db"
+	models "encore.app/orders/models"
+	promotionsdb "encore.app/promotions/db"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// OrderDiscountsTable instance.
+var OrderDiscountsTable = &db.OrderDiscountsTable{DB: PlamatioDB}
+
+// PromotionsTable instance, used to redeem coupons when an order is placed.
+var PromotionsTable = &promotionsdb.PromotionsTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /orders/discounts/:id
+// Retrieves the discounts applied to the order with the given ID.
+//encore:api auth method=GET path=/orders/discounts/:id
+func GetOrderDiscounts(ctx context.Context, id int) (*models.OrderDiscounts, error) {
+	// Confirm the caller owns the order.
+	if err := requireOrderOwner(ctx, id); err != nil {
+		return nil, err
+	}
+	return OrderDiscountsTable.GetOrderDiscounts(ctx, id)
+}
@@ -0,0 +1,61 @@
+package orders
+
+import (
+	"context"
+	"time"
+
+	cartmodels "encore.app/cart/models"
+	models "encore.app/orders/models"
+	outboxdb "encore.app/outbox/db"
+	"encore.dev/pubsub"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Events
+
+// OutboxTable instance, used to publish order and cart events.
+var OutboxTable = &outboxdb.OutboxTable{DB: PlamatioDB}
+
+// OrderCreatedTopic carries an event for every order placed.
+var OrderCreatedTopic = pubsub.NewTopic[*models.OrderCreatedEvent]("order-created", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// OrderStatusChangedTopic carries an event for every order status change.
+var OrderStatusChangedTopic = pubsub.NewTopic[*models.OrderStatusChangedEvent]("order-status-changed", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// OrderChangedTopic carries an event for every order updated or deleted.
+var OrderChangedTopic = pubsub.NewTopic[*models.OrderChangedEvent]("order-changed", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// The functions below add events to the outbox as part of the transaction making the
+// change. The outbox relay publishes them once the transaction is committed.
+
+// enqueueOrderCreatedTx adds an order-created event for the given order and its items to the outbox.
+func enqueueOrderCreatedTx(ctx context.Context, tx *sqldb.Tx, order *models.Order, items []*models.OrderItem) error {
+	event := &models.OrderCreatedEvent{Order: order, Items: items, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "order-created", event)
+}
+
+// enqueueOrderStatusChangedTx adds an order-status-changed event for the given order to the outbox.
+func enqueueOrderStatusChangedTx(ctx context.Context, tx *sqldb.Tx, order *models.Order, from string, actor string) error {
+	event := &models.OrderStatusChangedEvent{Order: order, FromStatus: from, ToStatus: order.Status, Actor: actor, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "order-status-changed", event)
+}
+
+// enqueueOrderChangedTx adds an order-changed event for the given order to the outbox.
+func enqueueOrderChangedTx(ctx context.Context, tx *sqldb.Tx, action string, order *models.Order) error {
+	event := &models.OrderChangedEvent{Action: action, Order: order, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "order-changed", event)
+}
+
+// enqueueCartClearedTx adds a cart-changed event for the cart items removed when the
+// user's cart is checked out to the outbox.
+func enqueueCartClearedTx(ctx context.Context, tx *sqldb.Tx, userID string, items []*cartmodels.CartItem) error {
+	event := &cartmodels.CartChangedEvent{Action: cartmodels.CartCleared, UserID: userID, Items: items, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "cart-changed", event)
+}
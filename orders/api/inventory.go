@@ -0,0 +1,58 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coreutils "encore.app/core/utils"
+	models "encore.app/orders/models"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// reserveStockTx reserves quantity units of the product's stock as part of the given transaction.
+func reserveStockTx(ctx context.Context, tx *sqldb.Tx, productID int, quantity int) error {
+	_, err := ProductsTB.ReserveStockTx(ctx, tx, productID, quantity)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("insufficient stock for product %d", productID),
+		}
+	}
+	return err
+}
+
+// releaseOrderStockTx releases the stock reserved by every item of the order as part of the given transaction.
+func releaseOrderStockTx(ctx context.Context, tx *sqldb.Tx, orderID int) error {
+	items, err := OrderItemsTable.GetOrderItemsByOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items.Data {
+		if err := ProductsTB.ReleaseStockTx(ctx, tx, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockOrderForItemChangeTx locks the order and confirms the caller owns it and its items
+// may still be changed, i.e. the order is pending. Once an order is paid, its items are
+// what was charged and what is being packed, so they are no longer changed.
+func lockOrderForItemChangeTx(ctx context.Context, tx *sqldb.Tx, orderID int) (*models.Order, error) {
+	order, err := lockOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(order.UserID); err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("items of a %s order cannot be changed", order.Status),
+		}
+	}
+	return order, nil
+}
@@ -6,6 +6,8 @@ import (
 
 	db "encore.app/orders/db"
 	models "encore.app/orders/models"
+	utils "encore.app/orders/utils"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
 )
@@ -50,8 +52,11 @@ Primary endpoints for orders:
 func GetOrderItem(ctx context.Context, id int) (*models.OrderItem, error) {
 	// First, try retrieving the order item from cache if it exists.
 	c, err := OrderItemCacheKeyspace.Get(ctx, id)
-	// if order item is found (i.e., no error), return it
+	// if order item is found (i.e., no error), return it if the caller owns its order
 	if err == nil {
+		if err := requireOrderOwner(ctx, c.OrderID); err != nil {
+			return nil, err
+		}
 		return &c, nil
 	}
 	// If the order item is not found in cache, retrieve it from the database.
@@ -59,6 +64,10 @@ func GetOrderItem(ctx context.Context, id int) (*models.OrderItem, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Confirm the caller owns the order.
+	if err := requireOrderOwner(ctx, r.OrderID); err != nil {
+		return nil, err
+	}
 	// Fire go routine to cache the order item.
 	go func() {
 		// Cache the order item.
@@ -75,6 +84,10 @@ func GetOrderItem(ctx context.Context, id int) (*models.OrderItem, error) {
 // Retrieves all order items for an order from the database.
 //encore:api auth method=GET path=/orders/items/all/:order_id
 func GetOrderItems(ctx context.Context, order_id int) (*models.OrderItems, error) {
+	// Confirm the caller owns the order.
+	if err := requireOrderOwner(ctx, order_id); err != nil {
+		return nil, err
+	}
 	// First, try retrieving all order items for an order from cache if they exist.
 	c, err := OrderItemsCacheKeyspace.Get(ctx, order_id)
 	// if order items are found (i.e., no error), return them
@@ -99,15 +112,51 @@ func GetOrderItems(ctx context.Context, order_id int) (*models.OrderItems, error
 }
 
 // POST: /orders/items/add
-// Inserts an order item into the database.
+// Inserts an order item, priced at the current product price, into the database, reserves
+// its stock and recomputes the order total.
 //encore:api auth method=POST path=/orders/items/add
 func AddOrderItem(ctx context.Context, oi *models.OrderItemRequestParams) (*models.OrderItem, error) {
-	// Insert the order item into the database.
-	noi, err := OrderItemsTable.InsertOrderItem(ctx, oi)
+	// validate data
+	if err := utils.ValidateNewOrderItemData(oi); err != nil {
+		return nil, err
+	}
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Confirm the order's items can still be changed.
+	order, err := lockOrderForItemChangeTx(ctx, tx, oi.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	// Reserve stock and insert the order item into the database.
+	if err := reserveStockTx(ctx, tx, oi.ProductID, oi.Quantity); err != nil {
+		return nil, err
+	}
+	unitPrice, err := priceOrderItemTx(ctx, tx, order, oi.ProductID)
 	if err != nil {
 		return nil, err
 	}
-	// Fire go routine to invalidate the cache for the order's order items.
+	noi, err := OrderItemsTable.InsertOrderItemTx(ctx, tx, oi, unitPrice)
+	if err != nil {
+		return nil, err
+	}
+	// Recompute the order total.
+	if err := repriceOrderTx(ctx, tx, order); err != nil {
+		return nil, err
+	}
+	// Record the order change in the outbox.
+	if err := enqueueOrderChangedTx(ctx, tx, models.OrderUpdated, order); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the cache for the order and the order's order items.
 	go func() {
 		// Invalidate the cache for the order's order items.
 		_, err = OrderItemsCacheKeyspace.Delete(ctx, oi.OrderID)
@@ -115,57 +164,151 @@ func AddOrderItem(ctx context.Context, oi *models.OrderItemRequestParams) (*mode
 			// log error
 			rlog.Error("Error deleting order items cache", err)
 		}
+		invalidateOrderCache(ctx, order)
 	}()
 
-	// TODO: Publish a message to a message broker to notify other services of the change.
-
-	return noi, err
+	return noi, nil
 }
 
 // PUT: /orders/items/update
-// Updates an order item in the database.
+// Updates an order item in the database, adjusting reserved stock for the new quantity,
+// and recomputes the order total.
 //encore:api auth method=PUT path=/orders/items/update
 func UpdateOrderItem(ctx context.Context, oi *models.OrderItem) (*models.OrderItemChangeRequestReturn, error) {
-	// Update the order item in the database.
-	err := OrderItemsTable.UpdateOrderItem(ctx, oi)
+	// validate data
+	if err := utils.ValidateUpdateOrderItemData(oi); err != nil {
+		return nil, err
+	}
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	// Fire go routine to invalidate the cache for the order's order items.
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the current order item.
+	current, err := OrderItemsTable.GetOrderItemForUpdateTx(ctx, tx, oi.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.OrderID != oi.OrderID {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "order item cannot be moved to another order",
+		}
+	}
+	// Confirm the order's items can still be changed.
+	order, err := lockOrderForItemChangeTx(ctx, tx, oi.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	// Release the stock reserved for the current item and reserve it for the updated item.
+	if err := ProductsTB.ReleaseStockTx(ctx, tx, current.ProductID, current.Quantity); err != nil {
+		return nil, err
+	}
+	if err := reserveStockTx(ctx, tx, oi.ProductID, oi.Quantity); err != nil {
+		return nil, err
+	}
+	// Keep the unit price the item was ordered at, unless the product changes.
+	oi.UnitPrice = current.UnitPrice
+	if oi.ProductID != current.ProductID || oi.UnitPrice == 0 {
+		if oi.UnitPrice, err = priceOrderItemTx(ctx, tx, order, oi.ProductID); err != nil {
+			return nil, err
+		}
+	}
+	// Update the order item in the database.
+	if err := OrderItemsTable.UpdateOrderItemTx(ctx, tx, oi); err != nil {
+		return nil, err
+	}
+	// Recompute the order total.
+	if err := repriceOrderTx(ctx, tx, order); err != nil {
+		return nil, err
+	}
+	// Record the order change in the outbox.
+	if err := enqueueOrderChangedTx(ctx, tx, models.OrderUpdated, order); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the cache for the order item and the order's order items.
 	go func() {
+		// Invalidate the cache for the order item.
+		_, err = OrderItemCacheKeyspace.Delete(ctx, oi.ID)
+		if err != nil {
+			// log error
+			rlog.Error("Error deleting order item cache", err)
+		}
 		// Invalidate the cache for the order's order items.
 		_, err = OrderItemsCacheKeyspace.Delete(ctx, oi.OrderID)
 		if err != nil {
 			// log error
 			rlog.Error("Error deleting order items cache", err)
 		}
+		invalidateOrderCache(ctx, order)
 	}()
 
-	// TODO: Publish a message to a message broker to notify other services of the change.
-
 	return &models.OrderItemChangeRequestReturn{OrderItemID: oi.ID}, nil
 }
 
 // DELETE: /orders/items/delete/:id
-// Deletes an order item from the database.
+// Deletes an order item from the database, releases its reserved stock and recomputes
+// the order total.
 //encore:api auth method=DELETE path=/orders/items/delete/:id
 func DeleteOrderItem(ctx context.Context, id int) (*models.OrderItemChangeRequestReturn, error) {
-	// Delete the order item from the database.
-	err := OrderItemsTable.DeleteOrderItem(ctx, id)
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the order item.
+	current, err := OrderItemsTable.GetOrderItemForUpdateTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the order's items can still be changed.
+	order, err := lockOrderForItemChangeTx(ctx, tx, current.OrderID)
 	if err != nil {
 		return nil, err
 	}
-	// Fire go routine to invalidate the cache for the order's order items.
+	// Release the reserved stock and delete the order item from the database.
+	if err := ProductsTB.ReleaseStockTx(ctx, tx, current.ProductID, current.Quantity); err != nil {
+		return nil, err
+	}
+	if err := OrderItemsTable.DeleteOrderItemTx(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	// Recompute the order total.
+	if err := repriceOrderTx(ctx, tx, order); err != nil {
+		return nil, err
+	}
+	// Record the order change in the outbox.
+	if err := enqueueOrderChangedTx(ctx, tx, models.OrderUpdated, order); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the cache for the order item and the order's order items.
 	go func() {
+		// Invalidate the cache for the order item.
+		_, err = OrderItemCacheKeyspace.Delete(ctx, id)
+		if err != nil {
+			// log error
+			rlog.Error("Error deleting order item cache", err)
+		}
 		// Invalidate the cache for the order's order items.
-		_, err = OrderItemsCacheKeyspace.Delete(ctx, id)
+		_, err = OrderItemsCacheKeyspace.Delete(ctx, current.OrderID)
 		if err != nil {
 			// log error
 			rlog.Error("Error deleting order items cache", err)
 		}
+		invalidateOrderCache(ctx, order)
 	}()
 
-	// TODO: Publish a message to a message broker to notify other services of the change.
-	
 	return &models.OrderItemChangeRequestReturn{OrderItemID: id}, nil
 }
\ No newline at end of file
@@ -0,0 +1,187 @@
+package orders
+
+import (
+	"context"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/orders/db"
+	models "encore.app/orders/models"
+	utils "encore.app/orders/utils"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// OrderStatusHistoryTable instance.
+var OrderStatusHistoryTable = &db.OrderStatusHistoryTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints to move an order through its lifecycle:
+
+- POST: /orders/status/pay/:id       (pending -> paid)
+- POST: /orders/status/payment/:id   (pending -> paid, private)
+- POST: /orders/status/fulfill/:id   (paid -> fulfilled)
+- POST: /orders/status/ship/:id      (fulfilled -> shipped)
+- POST: /orders/status/deliver/:id   (shipped -> delivered)
+- POST: /orders/status/cancel/:id    (pending, paid, fulfilled -> cancelled)
+- POST: /orders/status/refund/:id    (paid, delivered, cancelled -> refunded)
+- GET: /orders/status/history/:id
+
+Customers may only cancel their own orders while they are pending. Marking an
+order as paid requires the support role, or a call from another service once the
+payment is confirmed; every other change requires the support role.
+*/
+
+// POST: /orders/status/pay/:id
+// Marks the order with the given ID as paid, e.g. after support confirms a manual payment.
+//encore:api auth method=POST path=/orders/status/pay/:id
+func PayOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusPaid)
+}
+
+// POST: /orders/status/payment/:id
+// Marks the order with the given ID as paid. Called by the service that confirms the
+// payment of the order with the payment provider.
+//encore:api private method=POST path=/orders/status/payment/:id
+func ConfirmOrderPayment(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, true, models.OrderStatusPaid)
+}
+
+// POST: /orders/status/fulfill/:id
+// Marks the order with the given ID as fulfilled.
+//encore:api auth method=POST path=/orders/status/fulfill/:id
+func FulfillOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusFulfilled)
+}
+
+// POST: /orders/status/ship/:id
+// Marks the order with the given ID as shipped.
+//encore:api auth method=POST path=/orders/status/ship/:id
+func ShipOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusShipped)
+}
+
+// POST: /orders/status/deliver/:id
+// Marks the order with the given ID as delivered.
+//encore:api auth method=POST path=/orders/status/deliver/:id
+func DeliverOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusDelivered)
+}
+
+// POST: /orders/status/cancel/:id
+// Cancels the order with the given ID.
+//encore:api auth method=POST path=/orders/status/cancel/:id
+func CancelOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusCancelled)
+}
+
+// POST: /orders/status/refund/:id
+// Marks the order with the given ID as refunded.
+//encore:api auth method=POST path=/orders/status/refund/:id
+func RefundOrder(ctx context.Context, id int) (*models.Order, error) {
+	return changeOrderStatus(ctx, id, false, models.OrderStatusRefunded)
+}
+
+// GET: /orders/status/history/:id
+// Retrieves the status history of the order with the given ID, oldest first.
+//encore:api auth method=GET path=/orders/status/history/:id
+func GetOrderStatusHistory(ctx context.Context, id int) (*models.OrderStatusHistory, error) {
+	// Confirm the caller owns the order.
+	if err := requireOrderOwner(ctx, id); err != nil {
+		return nil, err
+	}
+	return OrderStatusHistoryTable.GetOrderStatusHistory(ctx, id)
+}
+
+// changeOrderStatus moves the order to the given status if the transition is allowed.
+// Internal changes, made by other services, are not authorized against the caller.
+func changeOrderStatus(ctx context.Context, id int, internal bool, status string) (*models.Order, error) {
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the order.
+	order, err := lockOrderTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the caller may make the change.
+	if !internal {
+		if err := authorizeOrderStatusChange(order, status); err != nil {
+			return nil, err
+		}
+	}
+	if err := changeOrderStatusTx(ctx, tx, order, status); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fire a go routine to invalidate the cache for the order and the user's orders.
+	go invalidateOrderCache(ctx, order)
+
+	return order, nil
+}
+
+// changeOrderStatusTx moves the locked order to the given status as part of the given
+// transaction if the transition is allowed, releases reserved stock when the order is
+// cancelled or refunded before it ships, and records the change in the order status
+// history and the outbox.
+func changeOrderStatusTx(ctx context.Context, tx *sqldb.Tx, order *models.Order, status string) error {
+	// Confirm the transition is allowed.
+	if err := utils.ValidateOrderStatusTransition(order.Status, status); err != nil {
+		return &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: err.Error(),
+		}
+	}
+	// Release reserved stock if the order is cancelled or refunded before it ships.
+	if utils.HoldsReservedStock(order.Status) && (status == models.OrderStatusCancelled || status == models.OrderStatusRefunded) {
+		if err := releaseOrderStockTx(ctx, tx, order.ID); err != nil {
+			return err
+		}
+	}
+	// Update the status and record the change.
+	if err := OrdersTable.UpdateOrderStatusTx(ctx, tx, order.ID, status); err != nil {
+		return err
+	}
+	actor := currentActor()
+	if _, err := OrderStatusHistoryTable.InsertOrderStatusChangeTx(ctx, tx, order.ID, order.Status, status, actor); err != nil {
+		return err
+	}
+	// Record the status change in the outbox.
+	from := order.Status
+	order.Status = status
+	return enqueueOrderStatusChangedTx(ctx, tx, order, from, actor)
+}
+
+// authorizeOrderStatusChange confirms the caller may move the order to the given status.
+// Customers may only cancel their own pending orders; nothing verifies a payment made by
+// a customer, so only support may mark an order as paid.
+func authorizeOrderStatusChange(order *models.Order, status string) error {
+	if status == models.OrderStatusCancelled && order.Status == models.OrderStatusPending {
+		return coreutils.RequireUser(order.UserID)
+	}
+	return coreutils.RequireRole(coremodels.RoleSupport)
+}
+
+// currentActor returns the ID of the authenticated caller, used to attribute
+// order status changes.
+func currentActor() string {
+	if uid, ok := auth.UserID(); ok {
+		return string(uid)
+	}
+	return "system"
+}
@@ -2,10 +2,16 @@ package orders
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	coreutils "encore.app/core/utils"
 	db "encore.app/orders/db"
 	models "encore.app/orders/models"
+	usersdb "encore.app/users/db"
+	usersmodels "encore.app/users/models"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
 	"encore.dev/storage/sqldb"
@@ -20,6 +26,9 @@ var PlamatioDB = sqldb.Named("plamatio_db")
 // OrdersTable instance.
 var OrdersTable = &db.OrdersTable{DB: PlamatioDB}
 
+// AddressesTable instance, used to confirm orders ship to an address of the user placing them.
+var AddressesTable = &usersdb.AddressesTable{DB: PlamatioDB}
+
 // ------------------------------------------------------
 // Setup Caching
 
@@ -62,15 +71,25 @@ Primary endpoints for orders:
 func GetOrder(ctx context.Context, id int) (*models.Order, error) {
 	// First, try retrieving the order from cache if it exists.
 	c, err := OrderCacheKeyspace.Get(ctx, id)
-	// if order is found (i.e., no error), return it
+	// if order is found (i.e., no error), return it if the caller owns it
 	if err == nil {
+		if err := coreutils.RequireUser(c.UserID); err != nil {
+			return nil, err
+		}
 		return &c, nil
 	}
 	// If the order is not found in cache, retrieve it from the database.
 	r, err := OrdersTable.GetOrder(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, orderNotFound(id)
+	}
 	if err != nil {
 		return nil, err
 	}
+	// Confirm the caller owns the order.
+	if err := coreutils.RequireUser(r.UserID); err != nil {
+		return nil, err
+	}
 	// Fire a go routine to cache the order.
 	go func() {
 		// Cache the order.
@@ -87,6 +106,10 @@ func GetOrder(ctx context.Context, id int) (*models.Order, error) {
 // Retrieves all orders for a user from the database.
 //encore:api auth method=GET path=/orders/all/:user_id
 func GetOrders(ctx context.Context, user_id string) (*models.Orders, error) {
+	// Confirm the caller owns the orders.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
 	// First, try retrieving all orders for a user from cache if they exist.
 	c, err := UserOrdersCacheKeyspace.Get(ctx, user_id)
 	// if orders are found (i.e., no error), return them
@@ -111,40 +134,80 @@ func GetOrders(ctx context.Context, user_id string) (*models.Orders, error) {
 }
 
 // POST: /orders/add
-// Inserts an order into the database.
+// Retired: orders are priced, taxed and checked against stock from their items, so an
+// order can no longer be added without them. Use /orders/detailed/add or /orders/checkout.
 //encore:api auth method=POST path=/orders/add
 func AddOrder(ctx context.Context, o *models.OrderRequestParams) (*models.Order, error) {
-	// Insert the order into the database.
-	or, err := OrdersTable.InsertOrder(ctx, o)
-	if err != nil {
-		return nil, err
+	return nil, &errs.Error{
+		Code:    errs.Unimplemented,
+		Message: "orders must be added with their items through /orders/detailed/add or /orders/checkout",
 	}
-	// Fire a go routine to invalidate the cache for the user's orders.
-	go func() {
-		// Invalidate the cache for the user's orders.
-		_, err = UserOrdersCacheKeyspace.Delete(ctx, o.UserID)
-		if err != nil {
-			// log error
-			rlog.Error("Error deleting user orders cache", err)
-		}
-	}()
-
-	// TODO: Publish a message to a message broker to notify other services of the change.
-
-	return or, err
 }
 
 // PUT: /orders/update
-// Updates an order in the database.
+// Updates an order in the database. The order status cannot be changed here;
+// use the order status endpoints instead. The order total is computed by the server
+// and is recomputed if the shipping address changes.
 //encore:api auth method=PUT path=/orders/update
 func UpdateOrder(ctx context.Context, o *models.Order) (*models.OrderChangeRequestReturn, error) {
+	// Start the transaction, so the change is never made without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the order, to confirm the status is not being changed.
+	current, err := lockOrderTx(ctx, tx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the caller owns the order, both before and after the update.
+	if err := coreutils.RequireUser(current.UserID); err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(o.UserID); err != nil {
+		return nil, err
+	}
+	if o.Status != current.Status {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "order status can only be changed through the order status endpoints",
+		}
+	}
+	// Keep the stored pricing; the total is only changed by repricing the order.
+	o.Total, o.BaseCurrency, o.ExchangeRate = current.Total, current.BaseCurrency, current.ExchangeRate
 	// Update the order in the database.
-	err := OrdersTable.UpdateOrder(ctx, o)
+	err = OrdersTable.UpdateOrderTx(ctx, tx, o)
+	if err != nil {
+		return nil, err
+	}
+	// Recompute the tax and total if the order ships to another address.
+	if o.AddressID != current.AddressID {
+		if err := repriceOrderTx(ctx, tx, o); err != nil {
+			return nil, err
+		}
+	}
+	// Record the updated order, as stored, in the outbox.
+	updated, err := OrdersTable.GetOrderForUpdateTx(ctx, tx, o.ID)
 	if err != nil {
 		return nil, err
 	}
-	// Fire a go routine to invalidate the cache for the user's orders.
+	if err := enqueueOrderChangedTx(ctx, tx, models.OrderUpdated, updated); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire a go routine to invalidate the cache for the order and the user's orders.
 	go func() {
+		// Invalidate the cache for the order.
+		_, err = OrderCacheKeyspace.Delete(ctx, o.ID)
+		if err != nil {
+			// log error
+			rlog.Error("Error deleting order cache", err)
+		}
 		// Invalidate the cache for the user's orders.
 		_, err = UserOrdersCacheKeyspace.Delete(ctx, o.UserID)
 		if err != nil {
@@ -153,32 +216,111 @@ func UpdateOrder(ctx context.Context, o *models.Order) (*models.OrderChangeReque
 		}
 	}()
 
-	// TODO: Publish a message to a message broker to notify other services of the change.
-
 	return &models.OrderChangeRequestReturn{OrderID: o.ID}, nil
 }
 
 // DELETE: /orders/delete/:id/user/:user_id
-// Deletes an order from the database.
+// Deletes a pending order by cancelling it, releasing its reserved stock. The order and
+// its status history are kept, so the deletion stays on record.
 //encore:api auth method=DELETE path=/orders/delete/:id/user/:user_id
 func DeleteOrder(ctx context.Context, id int, user_id string) (*models.OrderChangeRequestReturn, error) {
-	// Delete the order from the database.
-	err := OrdersTable.DeleteOrder(ctx, id)
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Fire a go routine to invalidate the cache for the user's orders.
-	go func() {
-		// Invalidate the cache for the user's orders.
-		_, err = UserOrdersCacheKeyspace.Delete(ctx, user_id)
-		if err != nil {
-			// log error
-			rlog.Error("Error deleting user orders cache", err)
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the order.
+	order, err := lockOrderTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the caller owns the order.
+	if err := coreutils.RequireUser(order.UserID); err != nil {
+		return nil, err
+	}
+	if order.UserID != user_id {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "order does not belong to user_id",
 		}
-	}()
+	}
+	// Only pending orders can be deleted; later changes go through the order status endpoints.
+	if order.Status != models.OrderStatusPending {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("a %s order cannot be deleted", order.Status),
+		}
+	}
+	// Cancel the order.
+	if err := changeOrderStatusTx(ctx, tx, order, models.OrderStatusCancelled); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fire a go routine to invalidate the cache for the order and the user's orders.
+	go invalidateOrderCache(ctx, order)
 
-	// TODO: Publish a message to a message broker to notify other services of the change.
-	
 	return &models.OrderChangeRequestReturn{OrderID: id}, nil
-}
\ No newline at end of file
+}
+
+// lockOrderTx retrieves the order with the given ID as part of the given transaction,
+// locking it until the transaction completes.
+func lockOrderTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.Order, error) {
+	order, err := OrdersTable.GetOrderForUpdateTx(ctx, tx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, orderNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// orderNotFound returns the error reported when the order with the given ID does not exist.
+func orderNotFound(id int) error {
+	return &errs.Error{
+		Code:    errs.NotFound,
+		Message: fmt.Sprintf("order %d not found", id),
+	}
+}
+
+// requireOrderOwner confirms the authenticated caller owns the order with the given ID.
+func requireOrderOwner(ctx context.Context, id int) error {
+	_, err := GetOrder(ctx, id)
+	return err
+}
+
+// requireOrderAddressTx retrieves the address with the given ID as part of the given
+// transaction, confirming it belongs to the user placing the order.
+func requireOrderAddressTx(ctx context.Context, tx *sqldb.Tx, userID string, addressID int) (*usersmodels.Address, error) {
+	address, err := AddressesTable.GetAddressTx(ctx, tx, addressID)
+	if errors.Is(err, sqldb.ErrNoRows) || (err == nil && address.UserID != userID) {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("address %d not found for user %s", addressID, userID),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// invalidateOrderCache invalidates the cached order and the cached orders of its user.
+func invalidateOrderCache(ctx context.Context, order *models.Order) {
+	// Invalidate the cache for the order.
+	if _, err := OrderCacheKeyspace.Delete(ctx, order.ID); err != nil {
+		// log error
+		rlog.Error("Error deleting order cache", err)
+	}
+	// Invalidate the cache for the user's orders.
+	if _, err := UserOrdersCacheKeyspace.Delete(ctx, order.UserID); err != nil {
+		// log error
+		rlog.Error("Error deleting user orders cache", err)
+	}
+}
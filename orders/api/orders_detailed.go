@@ -2,9 +2,19 @@ package orders
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	models "encore.app/orders/models"
+	utils "encore.app/orders/utils"
+	promotionsmodels "encore.app/promotions/models"
+	promotionsutils "encore.app/promotions/utils"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
+	"encore.dev/storage/sqldb"
 )
 
 // ------------------------------------------------------
@@ -18,9 +28,10 @@ Primary endpoints to get orders with order items:
 */
 
 // GET: /orders/detailed/get/:order_id
-// Retrieves the order with order items from the database with the given ID.
+// Retrieves the order with order items from the database with the given ID, with its
+// total in the requested currency if one is given.
 //encore:api auth method=GET path=/orders/detailed/get/:order_id
-func GetDetailedOrder(ctx context.Context, order_id int) (*models.DetailedOrder, error) {
+func GetDetailedOrder(ctx context.Context, order_id int, params *models.OrderCurrencyParams) (*models.DetailedOrder, error) {
 	// Retrieve the order from the database.
 	order, err := GetOrder(ctx, order_id)
 	if order == nil && err != nil {
@@ -39,14 +50,25 @@ func GetDetailedOrder(ctx context.Context, order_id int) (*models.DetailedOrder,
 		// log error
 		rlog.Error("error retrieving order items data for detailed order request, but received order items data. Likely issue with cache.", err)
 	}
+	// Retrieve the tax breakdown of the order.
+	tax, err := OrderTaxesTable.GetOrderTax(ctx, order_id)
+	if err != nil {
+		return nil, err
+	}
+	// Convert the order total into the requested currency.
+	display, err := displayOrderTotal(ctx, params.Currency, order)
+	if err != nil {
+		return nil, err
+	}
 	// Return the detailed order.
-	return &models.DetailedOrder{Order: order, Items: orderItems.Data}, nil
+	return &models.DetailedOrder{Order: order, Items: orderItems.Data, Tax: tax, Display: display}, nil
 }
 
 // GET: /orders/detailed/all/:user_id
-// Retrieves all orders with order items for a user from the database.
+// Retrieves all orders with order items for a user from the database, with their
+// totals in the requested currency if one is given.
 //encore:api auth method=GET path=/orders/detailed/all/:user_id
-func GetDetailedOrders(ctx context.Context, user_id string) (*models.DetailedOrders, error) {
+func GetDetailedOrders(ctx context.Context, user_id string, params *models.OrderCurrencyParams) (*models.DetailedOrders, error) {
 	// Retrieve all orders for a user from the database.
 	orders, err := GetOrders(ctx, user_id)
 	if orders == nil && err != nil {
@@ -68,45 +90,279 @@ func GetDetailedOrders(ctx context.Context, user_id string) (*models.DetailedOrd
 			// log error
 			rlog.Error("error retrieving order items data for detailed orders request, but received order items data. Likely issue with cache.", err)
 		}
+		// Convert the order total into the requested currency.
+		display, err := displayOrderTotal(ctx, params.Currency, order)
+		if err != nil {
+			return nil, err
+		}
 		// Append the detailed order to the DetailedOrders struct.
-		detailedOrders.Data = append(detailedOrders.Data, &models.DetailedOrder{Order: order, Items: orderItems.Data})
+		detailedOrders.Data = append(detailedOrders.Data, &models.DetailedOrder{Order: order, Items: orderItems.Data, Display: display})
 	}
 	// Return the detailed orders.
 	return detailedOrders, nil
 }
 
 // POST: /orders/detailed/add
-// Adds a new order with order items to the database.
+// Adds a new order with order items to the database. The order total is computed
+// from current product prices and must match the total the client expects to pay;
+// the order is written in a single transaction.
 //encore:api auth method=POST path=/orders/detailed/add
 func AddDetailedOrder(ctx context.Context, params *models.DetailedOrderRequestParams) (*models.DetailedOrder, error) {
-	// Add the order to the database.
-	order, err := AddOrder(ctx, params.Order)
-	if order == nil && err != nil {
+	// validate request data
+	if err := utils.ValidateNewDetailedOrderData(params); err != nil {
 		return nil, err
-	} else {
-		// if we have an error but we also have orders data
-		// log error
-		rlog.Error("error adding order data for detailed order request, but received order data. Likely issue with cache.", err)
-	}
-	// Loop through each order item and add it to the database.
-	for _, item := range params.Items {
-		// Create a new OrderItemRequestParams struct.
-		itemToAdd := &models.OrderItemRequestParams{OrderID: order.ID, ProductID: item.ProductID, Quantity: item.Quantity}
-		// Add the order item to the database.
-		_, err := AddOrderItem(ctx, itemToAdd)
+	}
+	// Confirm the caller owns the order.
+	if err := coreutils.RequireUser(params.Order.UserID); err != nil {
+		return nil, err
+	}
+	// Start the transaction. Nothing is committed unless every step succeeds.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Insert the order and its items.
+	expected := params.Order.Total
+	detailedOrder, err := insertDetailedOrderTx(ctx, tx, params.Order, params.Items, params.CouponCode)
+	if err != nil {
+		return nil, err
+	}
+	// Reject the order if the client-computed total disagrees with current prices and tax.
+	total := detailedOrder.Order.Total
+	if expected.Amount != total.Amount || (expected.Currency != "" && expected.Currency != total.Currency) {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("total %d %s does not match computed total %d %s", expected.Amount, expected.Currency, total.Amount, total.Currency),
+		}
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fire a go routine to invalidate the cache for the user's orders.
+	go func() {
+		// Invalidate the cache for the user's orders.
+		if _, err := UserOrdersCacheKeyspace.Delete(ctx, params.Order.UserID); err != nil {
+			// log error
+			rlog.Error("Error deleting user orders cache", err)
+		}
+	}()
+
+	// Return the detailed order.
+	return detailedOrder, nil
+}
+
+// insertDetailedOrderTx prices each item from the products table in the order currency, reserves its stock,
+// applies the coupon if one is given, taxes the items by the shipping address, sets the
+// order total and inserts the order with its items, discount, tax and order-created event
+// as part of the given transaction. Any total carried by the order is replaced by the
+// server total.
+func insertDetailedOrderTx(ctx context.Context, tx *sqldb.Tx, o *models.OrderRequestParams, items []*models.DetailedOrderItemRequestParams, couponCode string) (*models.DetailedOrder, error) {
+	if len(items) == 0 {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "order must contain at least one item",
+		}
+	}
+	// Confirm the order ships to one of the user's addresses.
+	if _, err := requireOrderAddressTx(ctx, tx, o.UserID, o.AddressID); err != nil {
+		return nil, err
+	}
+	// Price each item from current product prices, in the order currency if one is given.
+	var lines []*promotionsmodels.PricedLine
+	var currencies []string
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: "quantity is required",
+			}
+		}
+		p, err := ProductsTB.GetPricingTx(ctx, tx, item.ProductID, o.Total.Currency)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: fmt.Sprintf("product %d not found", item.ProductID),
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
+		// Reserve stock for the item.
+		if err := reserveStockTx(ctx, tx, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &promotionsmodels.PricedLine{ProductID: p.ID, CategoryId: p.CategoryId, SubCategoryId: p.SubCategoryId, Quantity: item.Quantity, UnitPrice: p.Display.Price, Currency: p.Display.Currency, BaseCurrency: p.Currency, Rate: p.Display.Rate})
+		currencies = append(currencies, p.Currency)
 	}
-	// Retrieve the order items for the order from the database.
-	orderItems, err := GetOrderItems(ctx, order.ID)
-	if orderItems == nil && err != nil {
+	// Reject orders mixing products priced in different currencies.
+	if _, err := coreutils.CommonCurrency(currencies...); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Apply the coupon, if any, and claim one of its uses.
+	breakdown := promotionsutils.PriceLines(lines, nil)
+	if code := promotionsutils.NormalizeCouponCode(couponCode); code != "" {
+		promotion, err := PromotionsTable.GetByCodeTx(ctx, tx, code)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return nil, promotionsutils.CouponNotFound(code)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if breakdown, err = promotionsutils.ApplyCoupon(promotion, lines, time.Now()); err != nil {
+			return nil, err
+		}
+		err = PromotionsTable.ClaimUsageTx(ctx, tx, promotion.ID)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return nil, &errs.Error{
+				Code:    errs.FailedPrecondition,
+				Message: "coupon usage limit reached",
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Compute the tax from the shipping address.
+	tax, err := computeOrderTaxTx(ctx, tx, o.UserID, o.AddressID, lines, breakdown)
+	if err != nil {
 		return nil, err
-	} else {
-		// if we have an error but we also have order items data
-		// log error
-		rlog.Error("error retrieving order items data for detailed order request, but received order items data. Likely issue with cache.", err)
 	}
-	// Return the detailed order.
-	return &models.DetailedOrder{Order: order, Items: orderItems.Data}, nil
-}
\ No newline at end of file
+	o.Total = coremodels.Money{Amount: breakdown.Total + tax.Total, Currency: breakdown.Currency}
+	// Record the currency the products are priced in and the rate used to convert them.
+	o.BaseCurrency, o.ExchangeRate = breakdown.BaseCurrency, breakdown.ExchangeRate
+	// Insert the order.
+	order, err := OrdersTable.InsertOrderTx(ctx, tx, o)
+	if err != nil {
+		return nil, err
+	}
+	// Record the initial order status.
+	if _, err := OrderStatusHistoryTable.InsertOrderStatusChangeTx(ctx, tx, order.ID, "", order.Status, currentActor()); err != nil {
+		return nil, err
+	}
+	// Insert the order items.
+	var orderItems []*models.OrderItem
+	for i, item := range items {
+		oi, err := OrderItemsTable.InsertOrderItemTx(ctx, tx, &models.OrderItemRequestParams{OrderID: order.ID, ProductID: item.ProductID, Quantity: item.Quantity}, lines[i].UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		orderItems = append(orderItems, oi)
+	}
+	// Record the discount.
+	if breakdown.Discount > 0 {
+		if _, err := OrderDiscountsTable.InsertOrderDiscountTx(ctx, tx, order.ID, breakdown.PromotionID, breakdown.CouponCode, breakdown.Discount); err != nil {
+			return nil, err
+		}
+	}
+	// Record the tax.
+	if err := OrderTaxesTable.InsertOrderTaxTx(ctx, tx, order.ID, tax); err != nil {
+		return nil, err
+	}
+	order.TaxAmount = tax.Total
+	// Record the new order in the outbox.
+	if err := enqueueOrderCreatedTx(ctx, tx, order, orderItems); err != nil {
+		return nil, err
+	}
+	return &models.DetailedOrder{Order: order, Items: orderItems, Breakdown: breakdown, Tax: tax}, nil
+}
+
+// repriceOrderTx recomputes the total of an order from its current items as part of the
+// given transaction: each item keeps the unit price it was ordered at, the promotion the
+// order was placed with is applied again while it is still in effect, and the items are
+// taxed by the shipping address. The discount, tax and total of the order are updated.
+func repriceOrderTx(ctx context.Context, tx *sqldb.Tx, order *models.Order) error {
+	items, err := OrderItemsTable.GetOrderItemsByOrderTx(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+	// Price each item at its recorded unit price, in the order currency.
+	var lines []*promotionsmodels.PricedLine
+	for _, item := range items.Data {
+		p, err := ProductsTB.GetTx(ctx, tx, item.ProductID)
+		if err != nil {
+			return err
+		}
+		// Record the unit price of items ordered before unit prices were recorded.
+		if item.UnitPrice == 0 {
+			if item.UnitPrice, err = priceOrderItemTx(ctx, tx, order, item.ProductID); err != nil {
+				return err
+			}
+			if err := OrderItemsTable.UpdateOrderItemTx(ctx, tx, item); err != nil {
+				return err
+			}
+		}
+		lines = append(lines, &promotionsmodels.PricedLine{ProductID: p.ID, CategoryId: p.CategoryId, SubCategoryId: p.SubCategoryId, Quantity: item.Quantity, UnitPrice: item.UnitPrice, Currency: order.Total.Currency, BaseCurrency: order.BaseCurrency, Rate: order.ExchangeRate})
+	}
+	// Apply the promotion the order was placed with, if it is still in effect, and update
+	// its discount. A promotion that has ended since the order was placed is not applied.
+	discounts, err := OrderDiscountsTable.GetOrderDiscountsTx(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+	breakdown := promotionsutils.PriceLines(lines, nil)
+	if len(discounts.Data) > 0 {
+		d := discounts.Data[0]
+		promotion, err := PromotionsTable.GetTx(ctx, tx, d.PromotionID)
+		if err != nil {
+			return err
+		}
+		if promotionsutils.IsInEffect(promotion, time.Now()) {
+			breakdown = promotionsutils.PriceLines(lines, promotion)
+		}
+		if err := OrderDiscountsTable.SetOrderDiscountAmountTx(ctx, tx, d.ID, breakdown.Discount); err != nil {
+			return err
+		}
+	}
+	// Tax the items by the shipping address.
+	tax, err := computeOrderTaxTx(ctx, tx, order.UserID, order.AddressID, lines, breakdown)
+	if err != nil {
+		return err
+	}
+	if err := OrderTaxesTable.ReplaceOrderTaxTx(ctx, tx, order.ID, tax); err != nil {
+		return err
+	}
+	// Update the order total.
+	order.Total.Amount, order.TaxAmount = breakdown.Total+tax.Total, tax.Total
+	return OrdersTable.SetOrderTotalTx(ctx, tx, order.ID, order.Total.Amount)
+}
+
+// priceOrderItemTx returns the unit price of the product, in the order currency, for an item
+// added to or changed in the order as part of the given transaction. The product is priced
+// at its current price, converted at the exchange rate recorded with the order so all the
+// items of the order are converted alike, unless its price is set for the order currency.
+// Orders priced entirely from such prices have no exchange rate to convert others with.
+func priceOrderItemTx(ctx context.Context, tx *sqldb.Tx, order *models.Order, productID int) (int, error) {
+	p, err := ProductsTB.GetPricingTx(ctx, tx, productID, order.Total.Currency)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return 0, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("product %d not found", productID),
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if p.Currency != order.BaseCurrency {
+		return 0, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("product %d is priced in %s, not in %s like the order", productID, p.Currency, order.BaseCurrency),
+		}
+	}
+	if p.Display.Override {
+		return p.Display.Price, nil
+	}
+	if p.Currency != order.Total.Currency && !(order.ExchangeRate > 0) {
+		return 0, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("product %d has no price set in %s, and the order has no exchange rate from %s", productID, order.Total.Currency, p.Currency),
+		}
+	}
+	return coreutils.ConvertAmount(p.Price, p.Currency, order.Total.Currency, order.ExchangeRate), nil
+}
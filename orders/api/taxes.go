@@ -0,0 +1,52 @@
+package orders
+
+import (
+	"context"
+
+	db "encore.app/orders/db"
+	promotionsmodels "encore.app/promotions/models"
+	taxesdb "encore.app/taxes/db"
+	taxesmodels "encore.app/taxes/models"
+	taxesutils "encore.app/taxes/utils"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// OrderTaxesTable instance.
+var OrderTaxesTable = &db.OrderTaxesTable{DB: PlamatioDB}
+
+// TaxRatesTable instance, used to tax orders.
+var TaxRatesTable = &taxesdb.TaxRatesTable{DB: PlamatioDB}
+
+// TaxExemptionsTable instance, used to tax orders.
+var TaxExemptionsTable = &taxesdb.TaxExemptionsTable{DB: PlamatioDB}
+
+// computeOrderTaxTx computes the tax on the priced lines of an order from the country and
+// state of its shipping address, as part of the given transaction. The address must
+// belong to the user placing the order.
+func computeOrderTaxTx(ctx context.Context, tx *sqldb.Tx, userID string, addressID int, lines []*promotionsmodels.PricedLine, breakdown *promotionsmodels.PriceBreakdown) (*taxesmodels.TaxBreakdown, error) {
+	// Retrieve the shipping address.
+	address, err := requireOrderAddressTx(ctx, tx, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+	// Retrieve the tax rate and exempt categories where the order ships to.
+	country, state := taxesutils.NormalizeRegion(address.Country), taxesutils.NormalizeRegion(address.State)
+	rate, err := TaxRatesTable.GetJurisdictionRateTx(ctx, tx, country, state)
+	if err != nil {
+		return nil, err
+	}
+	exempt, err := TaxExemptionsTable.GetJurisdictionExemptionsTx(ctx, tx, country, state)
+	if err != nil {
+		return nil, err
+	}
+	// Tax each line on its price after discount.
+	var taxable []*taxesmodels.TaxableLine
+	for i, l := range lines {
+		taxable = append(taxable, &taxesmodels.TaxableLine{ProductID: l.ProductID, CategoryId: l.CategoryId, Quantity: l.Quantity, Amount: breakdown.Lines[i].Total})
+	}
+	j := &taxesmodels.TaxJurisdiction{Country: country, State: state, Rate: rate, ExemptCategoryIds: exempt}
+	return taxesutils.ComputeTax(j, taxable), nil
+}
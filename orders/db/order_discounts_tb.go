@@ -0,0 +1,76 @@
+package orders
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/orders/models"
+	"encore.dev/storage/sqldb"
+)
+
+type OrderDiscountsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ORDER_DISCOUNTS = `
+				SELECT id, order_id, promotion_id, code, amount, created_at FROM order_discounts
+				WHERE order_id = $1
+				ORDER BY id
+		`
+		SQL_SET_ORDER_DISCOUNT_AMOUNT = `
+				UPDATE order_discounts SET amount = $1 WHERE id = $2
+		`
+		SQL_INSERT_ORDER_DISCOUNT = `
+				INSERT INTO order_discounts (order_id, promotion_id, code, amount, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id
+		`
+)
+
+// Retrieves the discounts applied to an order from the database.
+func (tb *OrderDiscountsTable) GetOrderDiscounts(ctx context.Context, orderId int) (*models.OrderDiscounts, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ORDER_DISCOUNTS, orderId)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrderDiscounts(rows)
+}
+
+// Retrieves the discounts applied to an order as part of the given transaction.
+func (tb *OrderDiscountsTable) GetOrderDiscountsTx(ctx context.Context, tx *sqldb.Tx, orderId int) (*models.OrderDiscounts, error) {
+	rows, err := tx.Query(ctx, SQL_GET_ORDER_DISCOUNTS, orderId)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrderDiscounts(rows)
+}
+
+// Sets the amount of a discount applied to an order as part of the given transaction.
+func (tb *OrderDiscountsTable) SetOrderDiscountAmountTx(ctx context.Context, tx *sqldb.Tx, id int, amount int) error {
+	_, err := tx.Exec(ctx, SQL_SET_ORDER_DISCOUNT_AMOUNT, amount, id)
+	return err
+}
+
+// scanOrderDiscounts scans rows of SQL_GET_ORDER_DISCOUNTS into order discounts.
+func scanOrderDiscounts(rows *sqldb.Rows) (*models.OrderDiscounts, error) {
+	defer rows.Close()
+
+	discounts := &models.OrderDiscounts{}
+	for rows.Next() {
+		d := &models.OrderDiscount{}
+		if err := rows.Scan(&d.ID, &d.OrderID, &d.PromotionID, &d.Code, &d.Amount, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		discounts.Data = append(discounts.Data, d)
+	}
+	return discounts, nil
+}
+
+// Records a discount applied to an order as part of the given transaction.
+func (tb *OrderDiscountsTable) InsertOrderDiscountTx(ctx context.Context, tx *sqldb.Tx, orderId int, promotionId int, code string, amount int) (*models.OrderDiscount, error) {
+	d := &models.OrderDiscount{OrderID: orderId, PromotionID: promotionId, Code: code, Amount: amount, CreatedAt: time.Now()}
+	err := tx.QueryRow(ctx, SQL_INSERT_ORDER_DISCOUNT, orderId, promotionId, code, amount, d.CreatedAt.Format(time.RFC3339)).Scan(&d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
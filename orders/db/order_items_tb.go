@@ -14,21 +14,29 @@ type OrderItemsTable struct {
 
 const (
 		SQL_GET_ORDER_ITEM = `
-				SELECT product_id, quantity FROM order_items
+				SELECT order_id, product_id, quantity, COALESCE(unit_price, 0) FROM order_items
 				WHERE id = $1
 		`
+		SQL_GET_ORDER_ITEM_FOR_UPDATE = `
+				SELECT order_id, product_id, quantity, COALESCE(unit_price, 0) FROM order_items
+				WHERE id = $1
+				FOR UPDATE
+		`
+		SQL_DELETE_ORDER_ITEMS_BY_ORDER = `
+				DELETE FROM order_items WHERE order_id = $1
+		`
 		SQL_GET_ALL_ORDER_ITEMS = `
-				SELECT id, product_id, quantity FROM order_items
+				SELECT id, product_id, quantity, COALESCE(unit_price, 0) FROM order_items
 		`
 		SQL_GET_ORDER_ITEMS_BY_ORDER = `
-				SELECT id, product_id, quantity FROM order_items
+				SELECT id, product_id, quantity, COALESCE(unit_price, 0) FROM order_items
 				WHERE order_id = $1
 		`
 		SQL_INSERT_ORDER_ITEM = `
-				INSERT INTO order_items (order_id, product_id, quantity) VALUES ($1, $2, $3) RETURNING id
+				INSERT INTO order_items (order_id, product_id, quantity, unit_price) VALUES ($1, $2, $3, $4) RETURNING id
 		`
 		SQL_UPDATE_ORDER_ITEM = `
-				UPDATE order_items SET order_id = $1, product_id = $2, quantity = $3 WHERE id = $4
+				UPDATE order_items SET order_id = $1, product_id = $2, quantity = $3, unit_price = $4 WHERE id = $5
 		`
 		SQL_DELETE_ORDER_ITEM = `
 				DELETE FROM order_items WHERE id = $1
@@ -38,7 +46,7 @@ const (
 // Retrieves an order item from the database.
 func (tb *OrderItemsTable) GetOrderItem(ctx context.Context, id int) (*models.OrderItem, error) {
 	oi := &models.OrderItem{ID: id}
-	err := tb.DB.QueryRow(ctx, SQL_GET_ORDER_ITEM, id).Scan(&oi.ProductID, &oi.Quantity)
+	err := tb.DB.QueryRow(ctx, SQL_GET_ORDER_ITEM, id).Scan(&oi.OrderID, &oi.ProductID, &oi.Quantity, &oi.UnitPrice)
 	return oi, err
 }
 
@@ -53,7 +61,7 @@ func (tb *OrderItemsTable) GetAllOrderItems(ctx context.Context) (*models.OrderI
 	orderItems := &models.OrderItems{}
 	for rows.Next() {
 		oi := &models.OrderItem{}
-		if err := rows.Scan(&oi.ID, &oi.ProductID, &oi.Quantity); err != nil {
+		if err := rows.Scan(&oi.ID, &oi.ProductID, &oi.Quantity, &oi.UnitPrice); err != nil {
 			return nil, err
 		}
 		orderItems.Data = append(orderItems.Data, oi)
@@ -71,8 +79,8 @@ func (tb *OrderItemsTable) GetOrderItemsByOrder(ctx context.Context, orderId int
 
 	orderItems := &models.OrderItems{}
 	for rows.Next() {
-		oi := &models.OrderItem{}
-		if err := rows.Scan(&oi.ID, &oi.ProductID, &oi.Quantity); err != nil {
+		oi := &models.OrderItem{OrderID: orderId}
+		if err := rows.Scan(&oi.ID, &oi.ProductID, &oi.Quantity, &oi.UnitPrice); err != nil {
 			return nil, err
 		}
 		orderItems.Data = append(orderItems.Data, oi)
@@ -80,18 +88,32 @@ func (tb *OrderItemsTable) GetOrderItemsByOrder(ctx context.Context, orderId int
 	return orderItems, nil
 }
 
-// Inserts an order item into the database.
-func (tb *OrderItemsTable) InsertOrderItem(ctx context.Context, oi *models.OrderItemRequestParams) (*models.OrderItem, error) {
+// Inserts an order item, ordered at the given unit price, into the database.
+func (tb *OrderItemsTable) InsertOrderItem(ctx context.Context, oi *models.OrderItemRequestParams, unitPrice int) (*models.OrderItem, error) {
 	// validate data
 	if err := utils.ValidateNewOrderItemData(oi); err != nil {
 		return nil, err
 	}
 	var oiID int
-	err := tb.DB.QueryRow(ctx, SQL_INSERT_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity).Scan(&oiID)
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity, unitPrice).Scan(&oiID)
 	if err != nil {
 		return nil, err
 	}
-	return &models.OrderItem{ID: oiID, OrderID: oi.OrderID, ProductID: oi.ProductID, Quantity: oi.Quantity}, nil
+	return &models.OrderItem{ID: oiID, OrderID: oi.OrderID, ProductID: oi.ProductID, Quantity: oi.Quantity, UnitPrice: unitPrice}, nil
+}
+
+// Inserts an order item into the database as part of the given transaction.
+func (tb *OrderItemsTable) InsertOrderItemTx(ctx context.Context, tx *sqldb.Tx, oi *models.OrderItemRequestParams, unitPrice int) (*models.OrderItem, error) {
+	// validate data
+	if err := utils.ValidateNewOrderItemData(oi); err != nil {
+		return nil, err
+	}
+	var oiID int
+	err := tx.QueryRow(ctx, SQL_INSERT_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity, unitPrice).Scan(&oiID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrderItem{ID: oiID, OrderID: oi.OrderID, ProductID: oi.ProductID, Quantity: oi.Quantity, UnitPrice: unitPrice}, nil
 }
 
 // Updates an order item in the database.
@@ -100,7 +122,7 @@ func (tb *OrderItemsTable) UpdateOrderItem(ctx context.Context, oi *models.Order
 	if err := utils.ValidateUpdateOrderItemData(oi); err != nil {
 		return err
 	}
-	_, err := tb.DB.Exec(ctx, SQL_UPDATE_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity, oi.ID)
+	_, err := tb.DB.Exec(ctx, SQL_UPDATE_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity, oi.UnitPrice, oi.ID)
 	return err
 }
 
@@ -108,4 +130,52 @@ func (tb *OrderItemsTable) UpdateOrderItem(ctx context.Context, oi *models.Order
 func (tb *OrderItemsTable) DeleteOrderItem(ctx context.Context, id int) error {
 	_, err := tb.DB.Exec(ctx, SQL_DELETE_ORDER_ITEM, id)
 	return err
-}
\ No newline at end of file
+}
+
+// Retrieves all order items for an order as part of the given transaction.
+func (tb *OrderItemsTable) GetOrderItemsByOrderTx(ctx context.Context, tx *sqldb.Tx, orderId int) (*models.OrderItems, error) {
+	rows, err := tx.Query(ctx, SQL_GET_ORDER_ITEMS_BY_ORDER, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orderItems := &models.OrderItems{}
+	for rows.Next() {
+		oi := &models.OrderItem{OrderID: orderId}
+		if err := rows.Scan(&oi.ID, &oi.ProductID, &oi.Quantity, &oi.UnitPrice); err != nil {
+			return nil, err
+		}
+		orderItems.Data = append(orderItems.Data, oi)
+	}
+	return orderItems, nil
+}
+
+// Retrieves an order item as part of the given transaction, locking it until the transaction completes.
+func (tb *OrderItemsTable) GetOrderItemForUpdateTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.OrderItem, error) {
+	oi := &models.OrderItem{ID: id}
+	err := tx.QueryRow(ctx, SQL_GET_ORDER_ITEM_FOR_UPDATE, id).Scan(&oi.OrderID, &oi.ProductID, &oi.Quantity, &oi.UnitPrice)
+	return oi, err
+}
+
+// Updates an order item as part of the given transaction.
+func (tb *OrderItemsTable) UpdateOrderItemTx(ctx context.Context, tx *sqldb.Tx, oi *models.OrderItem) error {
+	// validate data
+	if err := utils.ValidateUpdateOrderItemData(oi); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, SQL_UPDATE_ORDER_ITEM, oi.OrderID, oi.ProductID, oi.Quantity, oi.UnitPrice, oi.ID)
+	return err
+}
+
+// Deletes an order item as part of the given transaction.
+func (tb *OrderItemsTable) DeleteOrderItemTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_ORDER_ITEM, id)
+	return err
+}
+
+// Deletes all order items for an order as part of the given transaction.
+func (tb *OrderItemsTable) DeleteOrderItemsByOrderTx(ctx context.Context, tx *sqldb.Tx, orderId int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_ORDER_ITEMS_BY_ORDER, orderId)
+	return err
+}
@@ -0,0 +1,58 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "encore.app/orders/models"
+	"encore.dev/storage/sqldb"
+)
+
+type OrderStatusHistoryTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ORDER_STATUS_HISTORY = `
+				SELECT id, order_id, from_status, to_status, actor, changed_at FROM order_status_history
+				WHERE order_id = $1
+				ORDER BY changed_at, id
+		`
+		SQL_INSERT_ORDER_STATUS_HISTORY = `
+				INSERT INTO order_status_history (order_id, from_status, to_status, actor, changed_at) VALUES ($1, $2, $3, $4, $5) RETURNING id
+		`
+)
+
+// Retrieves the status history for an order from the database, oldest first.
+func (tb *OrderStatusHistoryTable) GetOrderStatusHistory(ctx context.Context, orderId int) (*models.OrderStatusHistory, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ORDER_STATUS_HISTORY, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.OrderStatusHistory{}
+	for rows.Next() {
+		h := &models.OrderStatusChange{}
+		var fromStatus sql.NullString
+		if err := rows.Scan(&h.ID, &h.OrderID, &fromStatus, &h.ToStatus, &h.Actor, &h.ChangedAt); err != nil {
+			return nil, err
+		}
+		h.FromStatus = fromStatus.String
+		history.Data = append(history.Data, h)
+	}
+	return history, nil
+}
+
+// Records an order status change as part of the given transaction.
+// An empty fromStatus records the initial status of a newly created order.
+func (tb *OrderStatusHistoryTable) InsertOrderStatusChangeTx(ctx context.Context, tx *sqldb.Tx, orderId int, fromStatus string, toStatus string, actor string) (*models.OrderStatusChange, error) {
+	h := &models.OrderStatusChange{OrderID: orderId, FromStatus: fromStatus, ToStatus: toStatus, Actor: actor, ChangedAt: time.Now()}
+	from := sql.NullString{String: fromStatus, Valid: fromStatus != ""}
+	err := tx.QueryRow(ctx, SQL_INSERT_ORDER_STATUS_HISTORY, orderId, from, toStatus, actor, h.ChangedAt.Format(time.RFC3339)).Scan(&h.ID)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
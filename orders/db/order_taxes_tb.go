@@ -0,0 +1,77 @@
+package orders
+
+import (
+	"context"
+
+	taxesmodels "encore.app/taxes/models"
+	"encore.dev/storage/sqldb"
+)
+
+type OrderTaxesTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ORDER_TAX = `
+				SELECT tax_country, tax_state, tax_amount FROM orders
+				WHERE id = $1
+		`
+		SQL_GET_ORDER_TAX_LINES = `
+				SELECT product_id, quantity, taxable_amount, rate, tax_amount, exempt FROM order_tax_lines
+				WHERE order_id = $1
+				ORDER BY id
+		`
+		SQL_SET_ORDER_TAX = `
+				UPDATE orders SET tax_country = $1, tax_state = $2, tax_amount = $3 WHERE id = $4
+		`
+		SQL_DELETE_ORDER_TAX_LINES = `
+				DELETE FROM order_tax_lines WHERE order_id = $1
+		`
+		SQL_INSERT_ORDER_TAX_LINE = `
+				INSERT INTO order_tax_lines (order_id, product_id, quantity, taxable_amount, rate, tax_amount, exempt)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`
+)
+
+// Retrieves the tax breakdown of an order from the database.
+func (tb *OrderTaxesTable) GetOrderTax(ctx context.Context, orderId int) (*taxesmodels.TaxBreakdown, error) {
+	b := &taxesmodels.TaxBreakdown{}
+	if err := tb.DB.QueryRow(ctx, SQL_GET_ORDER_TAX, orderId).Scan(&b.Country, &b.State, &b.Total); err != nil {
+		return nil, err
+	}
+	rows, err := tb.DB.Query(ctx, SQL_GET_ORDER_TAX_LINES, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := &taxesmodels.TaxLine{}
+		if err := rows.Scan(&l.ProductID, &l.Quantity, &l.TaxableAmount, &l.Rate, &l.Tax, &l.Exempt); err != nil {
+			return nil, err
+		}
+		b.Lines = append(b.Lines, l)
+	}
+	return b, nil
+}
+
+// Stores the tax breakdown of an order as part of the given transaction.
+func (tb *OrderTaxesTable) InsertOrderTaxTx(ctx context.Context, tx *sqldb.Tx, orderId int, b *taxesmodels.TaxBreakdown) error {
+	if _, err := tx.Exec(ctx, SQL_SET_ORDER_TAX, b.Country, b.State, b.Total, orderId); err != nil {
+		return err
+	}
+	for _, l := range b.Lines {
+		if _, err := tx.Exec(ctx, SQL_INSERT_ORDER_TAX_LINE, orderId, l.ProductID, l.Quantity, l.TaxableAmount, l.Rate, l.Tax, l.Exempt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the tax breakdown of an order as part of the given transaction.
+func (tb *OrderTaxesTable) ReplaceOrderTaxTx(ctx context.Context, tx *sqldb.Tx, orderId int, b *taxesmodels.TaxBreakdown) error {
+	if _, err := tx.Exec(ctx, SQL_DELETE_ORDER_TAX_LINES, orderId); err != nil {
+		return err
+	}
+	return tb.InsertOrderTaxTx(ctx, tx, orderId, b)
+}
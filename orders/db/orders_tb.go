@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	coremodels "encore.app/core/models"
 	models "encore.app/orders/models"
 	utils "encore.app/orders/utils"
 	"encore.dev/storage/sqldb"
@@ -15,21 +16,32 @@ type OrdersTable struct {
 
 const (
 		SQL_GET_ORDER = `
-				SELECT user_id, address_id, total_price, created_at, status FROM orders
+				SELECT user_id, address_id, total_price, currency, base_currency, COALESCE(exchange_rate, 0), tax_amount, created_at, status FROM orders
 				WHERE id = $1
 		`
 		SQL_GET_ALL_ORDERS = `
-				SELECT id, user_id, address_id, total_price, created_at, status FROM orders
+				SELECT id, user_id, address_id, total_price, currency, base_currency, COALESCE(exchange_rate, 0), tax_amount, created_at, status FROM orders
 		`
 		SQL_GET_ORDERS_BY_USER = `
-				SELECT id, user_id, address_id, total_price, created_at, status FROM orders
+				SELECT id, user_id, address_id, total_price, currency, base_currency, COALESCE(exchange_rate, 0), tax_amount, created_at, status FROM orders
 				WHERE user_id = $1
 		`
 		SQL_INSERT_ORDER = `
-				INSERT INTO orders (user_id, address_id, total_price, created_at, status) VALUES ($1, $2, $3, $4, $5) RETURNING id
+				INSERT INTO orders (user_id, address_id, total_price, currency, base_currency, exchange_rate, created_at, status) VALUES ($1, $2, $3, $4, $5, NULLIF($6::DOUBLE PRECISION, 0), $7, $8) RETURNING id
 		`
 		SQL_UPDATE_ORDER = `
-				UPDATE orders SET user_id = $1, address_id = $2, total_price = $3, created_at = $4, status = $5 WHERE id = $6
+				UPDATE orders SET user_id = $1, address_id = $2, total_price = $3, currency = $4, base_currency = $5, exchange_rate = NULLIF($6::DOUBLE PRECISION, 0), created_at = $7 WHERE id = $8
+		`
+		SQL_GET_ORDER_FOR_UPDATE = `
+				SELECT user_id, address_id, total_price, currency, base_currency, COALESCE(exchange_rate, 0), tax_amount, created_at, status FROM orders
+				WHERE id = $1
+				FOR UPDATE
+		`
+		SQL_SET_ORDER_TOTAL = `
+				UPDATE orders SET total_price = $1 WHERE id = $2
+		`
+		SQL_UPDATE_ORDER_STATUS = `
+				UPDATE orders SET status = $1 WHERE id = $2
 		`
 		SQL_DELETE_ORDER = `
 				DELETE FROM orders WHERE id = $1
@@ -39,7 +51,7 @@ const (
 // Retrieves an order from the database.
 func (tb *OrdersTable) GetOrder(ctx context.Context, id int) (*models.Order, error) {
 	o := &models.Order{ID: id}
-	err := tb.DB.QueryRow(ctx, SQL_GET_ORDER, id).Scan(&o.UserID, &o.AddressID, &o.TotalPrice, &o.CreatedAt, &o.Status)
+	err := tb.DB.QueryRow(ctx, SQL_GET_ORDER, id).Scan(&o.UserID, &o.AddressID, &o.Total.Amount, &o.Total.Currency, &o.BaseCurrency, &o.ExchangeRate, &o.TaxAmount, &o.CreatedAt, &o.Status)
 	return o, err
 }
 
@@ -54,7 +66,7 @@ func (tb *OrdersTable) GetAllOrders(ctx context.Context) (*models.Orders, error)
 	orders := &models.Orders{}
 	for rows.Next() {
 		o := &models.Order{}
-		if err := rows.Scan(&o.ID, &o.UserID, &o.AddressID, &o.TotalPrice, &o.CreatedAt, &o.Status); err != nil {
+		if err := rows.Scan(&o.ID, &o.UserID, &o.AddressID, &o.Total.Amount, &o.Total.Currency, &o.BaseCurrency, &o.ExchangeRate, &o.TaxAmount, &o.CreatedAt, &o.Status); err != nil {
 			return nil, err
 		}
 		orders.Data = append(orders.Data, o)
@@ -73,7 +85,7 @@ func (tb *OrdersTable) GetOrdersByUser(ctx context.Context, userId string) (*mod
 	orders := &models.Orders{}
 	for rows.Next() {
 		o := &models.Order{}
-		if err := rows.Scan(&o.ID, &o.UserID, &o.AddressID, &o.TotalPrice, &o.CreatedAt, &o.Status); err != nil {
+		if err := rows.Scan(&o.ID, &o.UserID, &o.AddressID, &o.Total.Amount, &o.Total.Currency, &o.BaseCurrency, &o.ExchangeRate, &o.TaxAmount, &o.CreatedAt, &o.Status); err != nil {
 			return nil, err
 		}
 		orders.Data = append(orders.Data, o)
@@ -83,6 +95,18 @@ func (tb *OrdersTable) GetOrdersByUser(ctx context.Context, userId string) (*mod
 
 // Inserts an order into the database.
 func (tb *OrdersTable) InsertOrder(ctx context.Context, o *models.OrderRequestParams) (*models.Order, error) {
+	// new orders start in the pending status unless specified
+	if o.Status == "" {
+		o.Status = models.OrderStatusPending
+	}
+	// new orders are in the default currency unless specified
+	if o.Total.Currency == "" {
+		o.Total.Currency = coremodels.DefaultCurrency
+	}
+	// orders are priced in their own currency unless specified
+	if o.BaseCurrency == "" {
+		o.BaseCurrency, o.ExchangeRate = o.Total.Currency, 1
+	}
 	// validate data
 	if err := utils.ValidateNewOrderData(o); err != nil {
 		return nil, err
@@ -92,11 +116,41 @@ func (tb *OrdersTable) InsertOrder(ctx context.Context, o *models.OrderRequestPa
 	createdAtRFC3339 := createdAt.Format(time.RFC3339)
 	// insert order
 	var id int
-	err := tb.DB.QueryRow(ctx, SQL_INSERT_ORDER, o.UserID, o.AddressID, o.TotalPrice, createdAtRFC3339, o.Status).Scan(&id)
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_ORDER, o.UserID, o.AddressID, o.Total.Amount, o.Total.Currency, o.BaseCurrency, o.ExchangeRate, createdAtRFC3339, o.Status).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
-	return &models.Order{ID: id, UserID: o.UserID, AddressID: o.AddressID, TotalPrice: o.TotalPrice, CreatedAt: createdAt, Status: o.Status}, nil
+	return &models.Order{ID: id, UserID: o.UserID, AddressID: o.AddressID, Total: o.Total, BaseCurrency: o.BaseCurrency, ExchangeRate: o.ExchangeRate, CreatedAt: createdAt, Status: o.Status}, nil
+}
+
+// Inserts an order into the database as part of the given transaction.
+func (tb *OrdersTable) InsertOrderTx(ctx context.Context, tx *sqldb.Tx, o *models.OrderRequestParams) (*models.Order, error) {
+	// new orders start in the pending status unless specified
+	if o.Status == "" {
+		o.Status = models.OrderStatusPending
+	}
+	// new orders are in the default currency unless specified
+	if o.Total.Currency == "" {
+		o.Total.Currency = coremodels.DefaultCurrency
+	}
+	// orders are priced in their own currency unless specified
+	if o.BaseCurrency == "" {
+		o.BaseCurrency, o.ExchangeRate = o.Total.Currency, 1
+	}
+	// validate data
+	if err := utils.ValidateNewOrderData(o); err != nil {
+		return nil, err
+	}
+	// get current time in RFC3339 format
+	createdAt := time.Now()
+	createdAtRFC3339 := createdAt.Format(time.RFC3339)
+	// insert order
+	var id int
+	err := tx.QueryRow(ctx, SQL_INSERT_ORDER, o.UserID, o.AddressID, o.Total.Amount, o.Total.Currency, o.BaseCurrency, o.ExchangeRate, createdAtRFC3339, o.Status).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Order{ID: id, UserID: o.UserID, AddressID: o.AddressID, Total: o.Total, BaseCurrency: o.BaseCurrency, ExchangeRate: o.ExchangeRate, CreatedAt: createdAt, Status: o.Status}, nil
 }
 
 // Updates an order in the database.
@@ -105,7 +159,36 @@ func (tb *OrdersTable) UpdateOrder(ctx context.Context, o *models.Order) error {
 	if err := utils.ValidateUpdateOrderData(o); err != nil {
 		return err
 	}
-	_, err := tb.DB.Exec(ctx, SQL_UPDATE_ORDER, o.UserID, o.AddressID, o.TotalPrice, o.CreatedAt, o.Status, o.ID)
+	_, err := tb.DB.Exec(ctx, SQL_UPDATE_ORDER, o.UserID, o.AddressID, o.Total.Amount, o.Total.Currency, o.BaseCurrency, o.ExchangeRate, o.CreatedAt, o.ID)
+	return err
+}
+
+// Updates an order as part of the given transaction.
+func (tb *OrdersTable) UpdateOrderTx(ctx context.Context, tx *sqldb.Tx, o *models.Order) error {
+	// validate data
+	if err := utils.ValidateUpdateOrderData(o); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, SQL_UPDATE_ORDER, o.UserID, o.AddressID, o.Total.Amount, o.Total.Currency, o.BaseCurrency, o.ExchangeRate, o.CreatedAt, o.ID)
+	return err
+}
+
+// Retrieves an order as part of the given transaction, locking it until the transaction completes.
+func (tb *OrdersTable) GetOrderForUpdateTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.Order, error) {
+	o := &models.Order{ID: id}
+	err := tx.QueryRow(ctx, SQL_GET_ORDER_FOR_UPDATE, id).Scan(&o.UserID, &o.AddressID, &o.Total.Amount, &o.Total.Currency, &o.BaseCurrency, &o.ExchangeRate, &o.TaxAmount, &o.CreatedAt, &o.Status)
+	return o, err
+}
+
+// Sets the total of an order, in minor units of its currency, as part of the given transaction.
+func (tb *OrdersTable) SetOrderTotalTx(ctx context.Context, tx *sqldb.Tx, id int, total int) error {
+	_, err := tx.Exec(ctx, SQL_SET_ORDER_TOTAL, total, id)
+	return err
+}
+
+// Updates the status of an order as part of the given transaction.
+func (tb *OrdersTable) UpdateOrderStatusTx(ctx context.Context, tx *sqldb.Tx, id int, status string) error {
+	_, err := tx.Exec(ctx, SQL_UPDATE_ORDER_STATUS, status, id)
 	return err
 }
 
@@ -113,4 +196,10 @@ func (tb *OrdersTable) UpdateOrder(ctx context.Context, o *models.Order) error {
 func (tb *OrdersTable) DeleteOrder(ctx context.Context, id int) error {
 	_, err := tb.DB.Exec(ctx, SQL_DELETE_ORDER, id)
 	return err
-}
\ No newline at end of file
+}
+
+// Deletes an order as part of the given transaction.
+func (tb *OrdersTable) DeleteOrderTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_ORDER, id)
+	return err
+}
@@ -1,16 +1,63 @@
 // Package orders provides models for handling orders and order-related data.
 package orders
 
-import "time"
+import (
+	"time"
+
+	coremodels "encore.app/core/models"
+	promotionsmodels "encore.app/promotions/models"
+	taxesmodels "encore.app/taxes/models"
+)
+
+// Order statuses. An order is created as pending and moves through the lifecycle
+// using the transitions defined in OrderStatusTransitions.
+const (
+	OrderStatusPending   = "pending"   // Order placed, awaiting payment.
+	OrderStatusPaid      = "paid"      // Payment received.
+	OrderStatusFulfilled = "fulfilled" // Items picked and packed.
+	OrderStatusShipped   = "shipped"   // Handed over to the carrier.
+	OrderStatusDelivered = "delivered" // Delivered to the customer.
+	OrderStatusCancelled = "cancelled" // Cancelled before delivery.
+	OrderStatusRefunded  = "refunded"  // Payment returned to the customer.
+)
+
+// OrderStatusTransitions maps each order status to the statuses it may move to.
+var OrderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusFulfilled: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {OrderStatusRefunded},
+	OrderStatusCancelled: {OrderStatusRefunded},
+	OrderStatusRefunded:  {},
+}
 
 // Order represents an order entity.
 type Order struct {
 	ID        int    `json:"id"`          // Unique identifier for the order.
 	UserID    string    `json:"user_id"`     // ID of the user who placed the order.
 	AddressID int    `json:"address_id"`  // ID of the address associated with the order.
-	TotalPrice float64  `json:"total_price"`  // Total price of the order.
+	Total     coremodels.Money `json:"total"` // Total price of the order, in minor units of its currency.
+	BaseCurrency string  `json:"base_currency"` // Currency the ordered products are priced in.
+	ExchangeRate float64 `json:"exchange_rate"` // Exchange rate from the base currency into the order currency when the order was placed; 0 if the order is priced entirely from price overrides, without a rate.
+	TaxAmount int `json:"tax_amount"` // Tax on the order, in minor units of its currency; included in the total.
 	CreatedAt time.Time `json:"created_at"`  // Timestamp indicating when the order was created.
-	Status    string `json:"status"`      // Current status of the order.
+	Status    string `json:"status"`      // Current status of the order. Changed only through the order status endpoints.
+}
+
+// OrderStatusChange represents a single recorded change of an order's status.
+type OrderStatusChange struct {
+	ID         int       `json:"id"`          // Unique identifier for the status change.
+	OrderID    int       `json:"order_id"`    // ID of the order whose status changed.
+	FromStatus string    `json:"from_status"` // Previous status; empty when the order was created.
+	ToStatus   string    `json:"to_status"`   // New status.
+	Actor      string    `json:"actor"`       // ID of the caller who made the change.
+	ChangedAt  time.Time `json:"changed_at"`  // Timestamp indicating when the status changed.
+}
+
+// OrderStatusHistory represents the status changes of an order, oldest first.
+type OrderStatusHistory struct {
+	Data []*OrderStatusChange `json:"data"`    // List of status changes.
 }
 
 // OrderItem represents an item within an order.
@@ -19,6 +66,7 @@ type OrderItem struct {
 	OrderID   int `json:"order_id"`       // ID of the order to which the item belongs.
 	ProductID int `json:"product_id"`     // ID of the product associated with the item.
 	Quantity  int `json:"quantity"`       // Quantity of the item.
+	UnitPrice int `json:"unit_price"`     // Price of one unit, in minor units of the order currency, set by the server when the item is ordered. 0 if it was ordered before unit prices were recorded.
 }
 
 // Orders represents a collection of orders.
@@ -35,6 +83,9 @@ type OrderItems struct {
 type DetailedOrder struct {
 	Order *Order         `json:"order"`    // The order entity.
 	Items []*OrderItem   `json:"items"`    // List of order item entities.
+	Breakdown *promotionsmodels.PriceBreakdown `json:"breakdown,omitempty"` // Price breakdown of the order; only set when the order is created.
+	Tax *taxesmodels.TaxBreakdown `json:"tax,omitempty"` // Tax breakdown of the order.
+	Display *coremodels.ConvertedMoney `json:"display,omitempty"` // Order total in the requested currency; only set when a currency is requested.
 }
 
 // DetailedOrders represents a collection of detailed orders.
@@ -46,7 +97,9 @@ type DetailedOrders struct {
 type OrderRequestParams struct {
 	UserID    string    `json:"user_id"`      // ID of the user placing the order.
 	AddressID int    `json:"address_id"`   // ID of the address associated with the order.
-	TotalPrice float64  `json:"total_price"`   // Total price of the order.
+	Total     coremodels.Money `json:"total"` // Total price of the order, in minor units of its currency. For detailed orders, the currency to place the order in, and the amount the client expects to pay, which must match the server-computed total.
+	BaseCurrency string  `json:"base_currency"` // Currency the ordered products are priced in; set by the server for detailed orders.
+	ExchangeRate float64 `json:"exchange_rate"` // Exchange rate from the base currency into the order currency; set by the server for detailed orders.
 	Status    string `json:"status"`       // Current status of the order.
 }
 
@@ -60,6 +113,7 @@ type DetailedOrderItemRequestParams struct {
 type DetailedOrderRequestParams struct {
 	Order *OrderRequestParams `json:"order"` // The order entity.
 	Items []*DetailedOrderItemRequestParams `json:"items"` // List of order item entities.
+	CouponCode string `json:"coupon_code"` // Coupon code to apply to the order, if any.
 }
 
 // OrderItemRequestParams represents the parameters for creating or updating an order item.
@@ -69,6 +123,34 @@ type OrderItemRequestParams struct {
 	Quantity  int `json:"quantity"`        // Quantity of the item.
 }
 
+// CheckoutRequestParams represents the parameters for converting a user's cart into an order.
+type CheckoutRequestParams struct {
+	UserID    string `json:"user_id"`      // ID of the user checking out.
+	AddressID int    `json:"address_id"`   // ID of the address the order ships to.
+	CouponCode string `json:"coupon_code"` // Coupon code to apply to the order, if any.
+	Currency  string `json:"currency"`     // Currency to place the order in; defaults to the currency of the products.
+}
+
+// OrderDiscount represents a discount applied to an order by a promotion.
+type OrderDiscount struct {
+	ID          int       `json:"id"`           // Unique identifier for the discount.
+	OrderID     int       `json:"order_id"`     // ID of the discounted order.
+	PromotionID int       `json:"promotion_id"` // ID of the promotion that was applied.
+	Code        string    `json:"code"`         // Coupon code that was applied.
+	Amount      int       `json:"amount"`       // Discount amount in cents.
+	CreatedAt   time.Time `json:"created_at"`   // Timestamp indicating when the discount was applied.
+}
+
+// OrderDiscounts represents the discounts applied to an order.
+type OrderDiscounts struct {
+	Data []*OrderDiscount `json:"data"`   // List of discounts.
+}
+
+// OrderCurrencyParams represents the query parameters for displaying orders in a currency.
+type OrderCurrencyParams struct {
+	Currency string `query:"currency"` // Currency to display order totals in.
+}
+
 // Order mutation request return type.
 type OrderChangeRequestReturn struct {
 	OrderID int `json:"id"`                // ID of the order.
@@ -77,4 +159,32 @@ type OrderChangeRequestReturn struct {
 // OrderItem mutation request return type.
 type OrderItemChangeRequestReturn struct {
 	OrderItemID int `json:"id"`            // ID of the order item.
-}
\ No newline at end of file
+}
+// Order change actions carried by order-changed events. Deleted orders are cancelled,
+// so their deletion is carried by an order-status-changed event.
+const (
+	OrderUpdated = "updated" // The order details were updated.
+)
+
+// OrderCreatedEvent is published on the order-created topic after an order is placed.
+type OrderCreatedEvent struct {
+	Order      *Order       `json:"order"`       // The order that was placed.
+	Items      []*OrderItem `json:"items"`       // Items of the order.
+	OccurredAt time.Time    `json:"occurred_at"` // Timestamp indicating when the order was placed.
+}
+
+// OrderStatusChangedEvent is published on the order-status-changed topic after an order moves to a new status.
+type OrderStatusChangedEvent struct {
+	Order      *Order    `json:"order"`       // The order, with its new status.
+	FromStatus string    `json:"from_status"` // Status before the change.
+	ToStatus   string    `json:"to_status"`   // Status after the change.
+	Actor      string    `json:"actor"`       // ID of the user who made the change, or "system".
+	OccurredAt time.Time `json:"occurred_at"` // Timestamp indicating when the status changed.
+}
+
+// OrderChangedEvent is published on the order-changed topic after an order is updated or deleted.
+type OrderChangedEvent struct {
+	Action     string    `json:"action"`      // Kind of change, one of the order change actions.
+	Order      *Order    `json:"order"`       // The order after the update, or as it was before deletion.
+	OccurredAt time.Time `json:"occurred_at"` // Timestamp indicating when the change was made.
+}
@@ -2,7 +2,11 @@ package orders
 
 import (
 	"errors"
+	"fmt"
+	"math"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	models "encore.app/orders/models"
 )
 
@@ -13,25 +17,126 @@ func ValidateNewOrderData(data *models.OrderRequestParams) error {
 	if data.AddressID <= 0 {
 		return errors.New("address_id is required")
 	}
-	if data.TotalPrice <= 0.0 {
-		return errors.New("total_price is required")
+	if err := ValidateOrderTotal(data.Total); err != nil {
+		return err
 	}
-	if data.Status == "" {
-		return errors.New("status is required")
+	if err := coreutils.ValidateCurrency(data.BaseCurrency); err != nil {
+		return err
+	}
+	if err := ValidateExchangeRate(data.ExchangeRate, data.BaseCurrency, data.Total.Currency); err != nil {
+		return err
+	}
+	if data.Status != models.OrderStatusPending {
+		return errors.New("new orders must have status pending")
+	}
+	return nil
+}
+
+// ValidateOrderTotal checks that an order total is an amount that is not negative, in a valid currency.
+func ValidateOrderTotal(total coremodels.Money) error {
+	if total.Amount < 0 {
+		return errors.New("total amount cannot be negative")
+	}
+	return coreutils.ValidateCurrency(total.Currency)
+}
+
+// ValidateExchangeRate checks the exchange rate of an order from its base currency into its
+// currency. The rate is 0 only if the order is in another currency than its base currency
+// and is priced entirely from price overrides set for it.
+func ValidateExchangeRate(rate float64, base string, currency string) error {
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) || (rate == 0 && base == currency) {
+		return errors.New("exchange_rate must be greater than 0")
 	}
 	return nil
 }
 
+// IsValidOrderStatus reports whether status is one of the defined order statuses.
+func IsValidOrderStatus(status string) bool {
+	_, ok := models.OrderStatusTransitions[status]
+	return ok
+}
+
+// ValidateOrderStatusTransition checks that an order may move from one status to another.
+func ValidateOrderStatusTransition(from string, to string) error {
+	if !IsValidOrderStatus(to) {
+		return errors.New("invalid status")
+	}
+	for _, next := range models.OrderStatusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change order status from %q to %q", from, to)
+}
+
+// HoldsReservedStock reports whether an order in the given status holds reserved
+// product stock, i.e. it has been placed but its items have not yet shipped.
+func HoldsReservedStock(status string) bool {
+	return status == models.OrderStatusPending || status == models.OrderStatusPaid || status == models.OrderStatusFulfilled
+}
+
 func ValidateUpdateOrderData(data *models.Order) error {
 	if data.ID <= 0 {
 		return errors.New("id is required")
 	}
-	return ValidateNewOrderData(&models.OrderRequestParams{
-		UserID: data.UserID,
-		AddressID: data.AddressID,
-		TotalPrice: data.TotalPrice,
-		Status: data.Status,
-	})
+	if data.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if data.AddressID <= 0 {
+		return errors.New("address_id is required")
+	}
+	// An order whose items have all been removed has a zero total.
+	if data.Total.Amount < 0 {
+		return errors.New("total amount cannot be negative")
+	}
+	if err := coreutils.ValidateCurrency(data.Total.Currency); err != nil {
+		return err
+	}
+	if err := coreutils.ValidateCurrency(data.BaseCurrency); err != nil {
+		return err
+	}
+	if err := ValidateExchangeRate(data.ExchangeRate, data.BaseCurrency, data.Total.Currency); err != nil {
+		return err
+	}
+	if !IsValidOrderStatus(data.Status) {
+		return errors.New("invalid status")
+	}
+	return nil
+}
+
+func ValidateNewDetailedOrderData(data *models.DetailedOrderRequestParams) error {
+	if data == nil || data.Order == nil {
+		return errors.New("order is required")
+	}
+	if data.Order.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if data.Order.AddressID <= 0 {
+		return errors.New("address_id is required")
+	}
+	if data.Order.Status != "" && data.Order.Status != models.OrderStatusPending {
+		return errors.New("new orders must have status pending")
+	}
+	if data.Order.Total.Amount < 0 {
+		return errors.New("total amount cannot be negative")
+	}
+	if data.Order.Total.Currency != "" {
+		if err := coreutils.ValidateCurrency(data.Order.Total.Currency); err != nil {
+			return err
+		}
+	}
+	if len(data.Items) == 0 {
+		return errors.New("items are required")
+	}
+	for _, item := range data.Items {
+		if item == nil || item.ProductID <= 0 {
+			return errors.New("product_id is required")
+		}
+		if item.Quantity <= 0 {
+			return errors.New("quantity is required")
+		}
+	}
+	return nil
 }
 
 func ValidateNewOrderItemData(data *models.OrderItemRequestParams) error {
@@ -56,4 +161,22 @@ func ValidateUpdateOrderItemData(data *models.OrderItem) error {
 		ProductID: data.ProductID,
 		Quantity: data.Quantity,
 	})
+}
+
+func ValidateCheckoutData(data *models.CheckoutRequestParams) error {
+	if data == nil {
+		return errors.New("empty checkout request")
+	}
+	if data.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if data.AddressID <= 0 {
+		return errors.New("address_id is required")
+	}
+	if data.Currency != "" {
+		if err := coreutils.ValidateCurrency(data.Currency); err != nil {
+			return err
+		}
+	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cart "encore.app/cart/api"
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	orders "encore.app/orders/api"
+	db "encore.app/outbox/db"
+	models "encore.app/outbox/models"
+	utils "encore.app/outbox/utils"
+	products "encore.app/products/api"
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"encore.dev/pubsub"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// OutboxTable instance.
+var OutboxTable = &db.OutboxTable{DB: PlamatioDB}
+
+// Maximum number of messages published per run of the outbox relay.
+const relayBatchSize = 100
+
+// ------------------------------------------------------
+// Setup Publishers
+
+// publishers maps each topic name to the function publishing a JSON-encoded event to it.
+var publishers = map[string]func(ctx context.Context, payload []byte) error{
+	"cart-changed":         publisher(cart.CartChangedTopic),
+	"cart-abandoned":       publisher(cart.CartAbandonedTopic),
+	"order-created":        publisher(orders.OrderCreatedTopic),
+	"order-status-changed": publisher(orders.OrderStatusChangedTopic),
+	"order-changed":        publisher(orders.OrderChangedTopic),
+	"product-changed":      publisher(products.ProductChangedTopic),
+}
+
+// publisher returns a function that decodes a JSON-encoded event and publishes it to the given topic.
+func publisher[T any](topic *pubsub.Topic[T]) func(ctx context.Context, payload []byte) error {
+	return func(ctx context.Context, payload []byte) error {
+		var event T
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return err
+		}
+		_, err := topic.Publish(ctx, event)
+		return err
+	}
+}
+
+// ------------------------------------------------------
+// Setup Cron Jobs
+
+// Publishes pending outbox messages.
+var _ = cron.NewJob("relay-outbox", cron.JobConfig{
+	Title:    "Publish pending domain events from the outbox",
+	Every:    1 * cron.Minute,
+	Endpoint: RelayOutbox,
+})
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints for the outbox of domain events:
+
+- POST: /outbox/relay            (private, called by the relay-outbox cron job)
+- GET: /outbox/failed            (super-admin)
+- PUT: /outbox/retry/:id         (super-admin)
+
+Services write domain events to the outbox in the same transaction as the change they
+describe, so events are never lost, nor published for changes that were rolled back.
+The relay publishes them to their topics; a message that cannot be published is retried
+with exponential backoff, and marked as failed after models.MaxAttempts attempts.
+*/
+
+// POST: /outbox/relay
+// Publishes the outbox messages that are due, marking each as delivered, or scheduling
+// its next attempt if publishing fails.
+//encore:api private method=POST path=/outbox/relay
+func RelayOutbox(ctx context.Context) error {
+	now := time.Now()
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the due messages.
+	messages, err := OutboxTable.GetPendingTx(ctx, tx, now, relayBatchSize)
+	if err != nil {
+		return err
+	}
+	// Publish each message, oldest first.
+	for _, m := range messages {
+		if err := publishMessage(ctx, m); err != nil {
+			// log error
+			rlog.Error("Error publishing outbox message", "id", m.ID, "topic", m.Topic, "attempts", m.Attempts+1, "err", err)
+			attempts := m.Attempts + 1
+			if err := OutboxTable.MarkAttemptFailedTx(ctx, tx, m.ID, err.Error(), now.Add(utils.RetryDelay(attempts)), attempts >= models.MaxAttempts); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := OutboxTable.MarkDeliveredTx(ctx, tx, m.ID, now); err != nil {
+			return err
+		}
+	}
+	// Commit the transaction.
+	return tx.Commit()
+}
+
+// GET: /outbox/failed
+// Retrieves the outbox messages that could not be published.
+//encore:api auth method=GET path=/outbox/failed
+func GetFailedMessages(ctx context.Context) (*models.OutboxMessages, error) {
+	// Confirm the caller is a super admin.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	return OutboxTable.GetFailed(ctx)
+}
+
+// PUT: /outbox/retry/:id
+// Moves the failed outbox message with the given ID back to pending, to be published
+// on the next run of the relay.
+//encore:api auth method=PUT path=/outbox/retry/:id
+func RetryMessage(ctx context.Context, id int) error {
+	// Confirm the caller is a super admin.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return err
+	}
+	err := OutboxTable.Retry(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("failed outbox message %d not found", id),
+		}
+	}
+	return err
+}
+
+// publishMessage publishes the event of an outbox message to its topic.
+func publishMessage(ctx context.Context, m *models.OutboxMessage) error {
+	publish, ok := publishers[m.Topic]
+	if !ok {
+		return fmt.Errorf("unknown topic %q", m.Topic)
+	}
+	return publish(ctx, m.Payload)
+}
@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	models "encore.app/outbox/models"
+	"encore.dev/storage/sqldb"
+)
+
+type OutboxTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_INSERT_OUTBOX_MESSAGE = `
+				INSERT INTO outbox (topic, payload, created_at, next_attempt_at)
+				VALUES ($1, $2::jsonb, $3, $3)
+		`
+		SQL_GET_PENDING_OUTBOX_MESSAGES_FOR_UPDATE = `
+				SELECT id, topic, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at FROM outbox
+				WHERE status = 'pending' AND next_attempt_at <= $1
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+		`
+		SQL_MARK_OUTBOX_MESSAGE_DELIVERED = `
+				UPDATE outbox SET status = 'delivered', delivered_at = $1, last_error = ''
+				WHERE id = $2
+		`
+		SQL_MARK_OUTBOX_MESSAGE_ATTEMPT_FAILED = `
+				UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2, status = $3
+				WHERE id = $4
+		`
+		SQL_GET_FAILED_OUTBOX_MESSAGES = `
+				SELECT id, topic, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at FROM outbox
+				WHERE status = 'failed'
+				ORDER BY id
+		`
+		SQL_RETRY_OUTBOX_MESSAGE = `
+				UPDATE outbox SET status = 'pending', attempts = 0, next_attempt_at = $1
+				WHERE id = $2 AND status = 'failed'
+				RETURNING id
+		`
+)
+
+// Adds an event to the outbox as part of the given transaction, to be published to the
+// given topic once the transaction is committed.
+func (tb *OutboxTable) InsertTx(ctx context.Context, tx *sqldb.Tx, topic string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, SQL_INSERT_OUTBOX_MESSAGE, topic, string(payload), time.Now())
+	return err
+}
+
+// Retrieves up to limit messages that are due to be published, oldest first, as part of
+// the given transaction. Rows are locked until the transaction completes; rows locked by
+// another transaction are skipped.
+func (tb *OutboxTable) GetPendingTx(ctx context.Context, tx *sqldb.Tx, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	rows, err := tx.Query(ctx, SQL_GET_PENDING_OUTBOX_MESSAGES_FOR_UPDATE, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// Marks a message as delivered as part of the given transaction.
+func (tb *OutboxTable) MarkDeliveredTx(ctx context.Context, tx *sqldb.Tx, id int, now time.Time) error {
+	_, err := tx.Exec(ctx, SQL_MARK_OUTBOX_MESSAGE_DELIVERED, now, id)
+	return err
+}
+
+// Records a failed attempt to publish a message as part of the given transaction. The
+// message is retried at nextAttemptAt, or marked as failed if failed is true.
+func (tb *OutboxTable) MarkAttemptFailedTx(ctx context.Context, tx *sqldb.Tx, id int, lastError string, nextAttemptAt time.Time, failed bool) error {
+	status := models.OutboxStatusPending
+	if failed {
+		status = models.OutboxStatusFailed
+	}
+	_, err := tx.Exec(ctx, SQL_MARK_OUTBOX_MESSAGE_ATTEMPT_FAILED, lastError, nextAttemptAt, status, id)
+	return err
+}
+
+// Retrieves all messages that could not be published, oldest first.
+func (tb *OutboxTable) GetFailed(ctx context.Context) (*models.OutboxMessages, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_FAILED_OUTBOX_MESSAGES)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return &models.OutboxMessages{Data: messages}, rows.Err()
+}
+
+// Moves a failed message back to pending, to be published on the next run of the relay.
+// Returns sqldb.ErrNoRows if there is no failed message with the given ID.
+func (tb *OutboxTable) Retry(ctx context.Context, id int) error {
+	var retried int
+	return tb.DB.QueryRow(ctx, SQL_RETRY_OUTBOX_MESSAGE, time.Now(), id).Scan(&retried)
+}
+
+// scanOutboxMessage scans an outbox message from the current row.
+func scanOutboxMessage(rows *sqldb.Rows) (*models.OutboxMessage, error) {
+	m := &models.OutboxMessage{}
+	var payload []byte
+	if err := rows.Scan(&m.ID, &m.Topic, &payload, &m.Status, &m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt, &m.DeliveredAt); err != nil {
+		return nil, err
+	}
+	m.Payload = payload
+	return m, nil
+}
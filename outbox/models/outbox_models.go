@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of an outbox message. A message is pending until it is published, and
+// failed once MaxAttempts attempts to publish it have failed.
+const (
+	OutboxStatusPending   = "pending"   // waiting to be published
+	OutboxStatusDelivered = "delivered" // published to its topic
+	OutboxStatusFailed    = "failed"    // gave up after MaxAttempts attempts
+)
+
+// MaxAttempts is the number of attempts made to publish a message before it is marked as failed.
+const MaxAttempts = 10
+
+// OutboxMessage represents a domain event waiting to be published, or already published, to a topic.
+type OutboxMessage struct {
+	ID            int             `json:"id"`
+	Topic         string          `json:"topic"`         // name of the topic the event is published to
+	Payload       json.RawMessage `json:"payload"`       // JSON-encoded event
+	Status        string          `json:"status"`        // one of the outbox statuses
+	Attempts      int             `json:"attempts"`      // number of failed attempts to publish the event
+	LastError     string          `json:"lastError"`     // error of the last failed attempt, if any
+	NextAttemptAt time.Time       `json:"nextAttemptAt"` // earliest time of the next attempt
+	CreatedAt     time.Time       `json:"createdAt"`
+	DeliveredAt   *time.Time      `json:"deliveredAt"`   // when the event was published; nil until then
+}
+
+// OutboxMessages represents a collection of outbox messages.
+type OutboxMessages struct {
+	Data []*OutboxMessage `json:"data"`
+}
@@ -0,0 +1,22 @@
+package outbox
+
+import "time"
+
+// Bounds of the delay between attempts to publish an outbox message.
+const (
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// RetryDelay returns how long to wait before the next attempt to publish a message
+// that has failed the given number of times. The delay doubles with each attempt.
+func RetryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,90 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	models "encore.app/products/models"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/cache"
+)
+
+// Product Cache Version Keyspace to store the version of the product query caches at key "version".
+// Search, list and suggestion cache keys include the version, so bumping it retires
+// every cached query result at once without enumerating their keys.
+var ProductCacheVersionKeyspace = cache.NewIntKeyspace[string](ProductsCluster, cache.KeyspaceConfig{
+	KeyPattern: "product-cache-version/:key",
+})
+
+// DELETE: /products/cache/:id
+// Invalidates the cached product and every cached listing it appears in. Used by other
+// services that change product data directly, e.g. review ratings.
+//encore:api private method=DELETE path=/products/cache/:id
+func InvalidateProductCache(ctx context.Context, id int) error {
+	p, err := getProductForChange(ctx, id)
+	if err != nil {
+		return err
+	}
+	invalidateProductCache(ctx, id, p)
+	return nil
+}
+
+// DELETE: /products/query-cache
+// Retires every cached product search, list and suggestion result. Used by other services
+// that change data these results carry, e.g. category names and whether they are offered.
+//encore:api private method=DELETE path=/products/query-cache
+func RetireProductQueryCache(ctx context.Context) error {
+	_, err := ProductCacheVersionKeyspace.Increment(ctx, "version", 1)
+	return err
+}
+
+// versionedCacheKey prefixes key with the current product cache version.
+func versionedCacheKey(ctx context.Context, key string) string {
+	// A missing version is treated as version 0.
+	v, err := ProductCacheVersionKeyspace.Get(ctx, "version")
+	if err != nil {
+		v = 0
+	}
+	return fmt.Sprintf("v%d/%s", v, key)
+}
+
+// invalidateProductCache removes the product and every product listing it may appear in
+// from cache, for each of the given versions of the product (e.g. before and after an update),
+// and retires all cached search, list and suggestion results.
+func invalidateProductCache(ctx context.Context, id int, products ...*models.Product) {
+	// Invalidate the cache for the product.
+	if _, err := ProductCacheKeyspace.Delete(ctx, id); err != nil {
+		// log error
+		rlog.Error("Error deleting product cache", err)
+	}
+	// Invalidate the cache for all products and all hero products.
+	if _, err := ProductsCacheKeyspace.Delete(ctx, "all"); err != nil {
+		// log error
+		rlog.Error("Error deleting products cache", err)
+	}
+	if _, err := HeroProductsCacheKeyspace.Delete(ctx, "all"); err != nil {
+		// log error
+		rlog.Error("Error deleting hero products cache", err)
+	}
+	// Invalidate the cache for the categories and sub-categories of the product.
+	for _, p := range products {
+		if _, err := ProductCategoryCacheKeyspace.Delete(ctx, p.CategoryId); err != nil {
+			// log error
+			rlog.Error("Error deleting product category cache", err)
+		}
+		if _, err := ProductSubCategoryCacheKeyspace.Delete(ctx, p.SubCategoryId); err != nil {
+			// log error
+			rlog.Error("Error deleting product sub-category cache", err)
+		}
+		if _, err := HeroProductsCacheKeyspace.Delete(ctx, strconv.Itoa(p.CategoryId)); err != nil {
+			// log error
+			rlog.Error("Error deleting category hero products cache", err)
+		}
+	}
+	// Retire the cached search, list and suggestion results.
+	if _, err := ProductCacheVersionKeyspace.Increment(ctx, "version", 1); err != nil {
+		// log error
+		rlog.Error("Error incrementing product cache version", err)
+	}
+}
@@ -0,0 +1,216 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/products/db"
+	models "encore.app/products/models"
+	utils "encore.app/products/utils"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// ExchangeRatesTB is the exchange rates table instance.
+var ExchangeRatesTB = &db.ExchangeRatesTB{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /products/rates/all
+// Retrieves all exchange rates.
+//encore:api auth method=GET path=/products/rates/all
+func GetExchangeRates(ctx context.Context) (*models.ExchangeRates, error) {
+	return ExchangeRatesTB.GetAll(ctx)
+}
+
+// PUT: /products/rates/:base/:quote
+// Sets the rate at which prices in the base currency convert into the quote currency.
+//encore:api auth method=PUT path=/products/rates/:base/:quote
+func SetExchangeRate(ctx context.Context, base string, quote string, p *models.ExchangeRateRequestParams) (*models.ExchangeRate, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the exchange rate.
+	if err := utils.ValidateExchangeRate(base, quote, p); err != nil {
+		return nil, err
+	}
+	return ExchangeRatesTB.Upsert(ctx, base, quote, p.Rate, currentActor())
+}
+
+// DELETE: /products/rates/:base/:quote
+// Deletes the rate from the base currency into the quote currency. Prices in the base
+// currency can no longer be displayed or ordered in the quote currency.
+//encore:api auth method=DELETE path=/products/rates/:base/:quote
+func DeleteExchangeRate(ctx context.Context, base string, quote string) error {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return err
+	}
+	err := ExchangeRatesTB.Delete(ctx, base, quote)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("no exchange rate from %s to %s", base, quote),
+		}
+	}
+	return err
+}
+
+// GET: /products/prices/overrides/:id
+// Retrieves the prices set explicitly for the product with the given ID, by currency.
+//encore:api auth method=GET path=/products/prices/overrides/:id
+func GetPriceOverrides(ctx context.Context, id int) (*models.PriceOverrides, error) {
+	return ProductPricesTB.GetOverrides(ctx, id)
+}
+
+// PUT: /products/prices/overrides/:id/:currency
+// Sets the price of the product with the given ID in a currency other than its own,
+// used instead of the converted price.
+//encore:api auth method=PUT path=/products/prices/overrides/:id/:currency
+func SetPriceOverride(ctx context.Context, id int, currency string, p *models.PriceOverrideRequestParams) (*models.PriceOverride, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the price override.
+	if err := coreutils.ValidateCurrency(currency); err != nil {
+		return nil, utils.InvalidField("currency", err.Error())
+	}
+	if p.Price <= 0 {
+		return nil, utils.InvalidField("price", models.ErrPriceInvalid)
+	}
+	product, err := getProductForChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if product.Currency == currency {
+		return nil, utils.InvalidField("currency", fmt.Sprintf("product %d is already priced in %s", id, currency))
+	}
+	// Set the price override.
+	o, err := ProductPricesTB.UpsertOverride(ctx, id, currency, p.Price)
+	if sqldb.ErrCode(err) == sqlerr.ForeignKeyViolation {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("product %d not found", id),
+		}
+	}
+	return o, err
+}
+
+// DELETE: /products/prices/overrides/:id/:currency
+// Deletes the price of the product with the given ID in a currency, so that its
+// converted price is used instead.
+//encore:api auth method=DELETE path=/products/prices/overrides/:id/:currency
+func DeletePriceOverride(ctx context.Context, id int, currency string) error {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return err
+	}
+	err := ProductPricesTB.DeleteOverride(ctx, id, currency)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("no %s price set for product %d", currency, id),
+		}
+	}
+	return err
+}
+
+// displayProducts returns copies of the products with their prices in the given currency
+// set as Display. The products are returned unchanged if no currency is given.
+func displayProducts(ctx context.Context, currency string, products []*models.Product) ([]*models.Product, error) {
+	if currency == "" || len(products) == 0 {
+		return products, nil
+	}
+	if err := coreutils.ValidateCurrency(currency); err != nil {
+		return nil, utils.InvalidField("currency", err.Error())
+	}
+	// Retrieve the rates into the currency and the prices set for it.
+	rates, err := ExchangeRatesTB.GetRatesTo(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	overrides, err := ProductPricesTB.GetOverridesIn(ctx, ids, currency)
+	if err != nil {
+		return nil, err
+	}
+	// Convert the prices of each product.
+	r := make([]*models.Product, len(products))
+	for i, p := range products {
+		var override *int
+		if v, ok := overrides[p.ID]; ok {
+			override = &v
+		}
+		var rate *float64
+		if v, ok := rates[p.Currency]; ok {
+			rate = &v
+		}
+		display, err := utils.ConvertProductPrice(p, currency, override, rate)
+		if err != nil {
+			return nil, err
+		}
+		c := *p
+		c.Display = display
+		r[i] = &c
+	}
+	return r, nil
+}
+
+// displayProduct returns a copy of the product with its prices in the given currency set as Display.
+func displayProduct(ctx context.Context, currency string, p *models.Product) (*models.Product, error) {
+	r, err := displayProducts(ctx, currency, []*models.Product{p})
+	if err != nil {
+		return nil, err
+	}
+	return r[0], nil
+}
+
+// displayProductList returns a copy of the products with their prices in the given currency.
+func displayProductList(ctx context.Context, currency string, products *models.Products) (*models.Products, error) {
+	data, err := displayProducts(ctx, currency, products.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Products{Data: data}, nil
+}
+
+// displayProductPage returns a copy of the page with the prices of its products in the given currency.
+func displayProductPage(ctx context.Context, currency string, page *models.ProductPage) (*models.ProductPage, error) {
+	products, err := displayProducts(ctx, currency, page.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ProductPage{Data: products, NextCursor: page.NextCursor}, nil
+}
+
+// displaySearchResults returns a copy of the search results with the prices of their products in the given currency.
+func displaySearchResults(ctx context.Context, currency string, results *models.ProductSearchResults) (*models.ProductSearchResults, error) {
+	products := make([]*models.Product, len(results.Data))
+	for i, r := range results.Data {
+		products[i] = r.Product
+	}
+	products, err := displayProducts(ctx, currency, products)
+	if err != nil {
+		return nil, err
+	}
+	r := &models.ProductSearchResults{NextOffset: results.NextOffset}
+	for i, result := range results.Data {
+		c := *result
+		c.Product = products[i]
+		r.Data = append(r.Data, &c)
+	}
+	return r, nil
+}
@@ -0,0 +1,29 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	outboxdb "encore.app/outbox/db"
+	models "encore.app/products/models"
+	"encore.dev/pubsub"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Events
+
+// OutboxTable instance, used to publish product events.
+var OutboxTable = &outboxdb.OutboxTable{DB: PlamatioDB}
+
+// ProductChangedTopic carries an event for every change to a product in the catalog.
+var ProductChangedTopic = pubsub.NewTopic[*models.ProductChangedEvent]("product-changed", pubsub.TopicConfig{
+	DeliveryGuarantee: pubsub.AtLeastOnce,
+})
+
+// enqueueProductChangedTx adds a product-changed event for the given product to the outbox as
+// part of the given transaction. The outbox relay publishes it once the transaction is committed.
+func enqueueProductChangedTx(ctx context.Context, tx *sqldb.Tx, action string, p *models.Product) error {
+	event := &models.ProductChangedEvent{Action: action, Product: p, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "product-changed", event)
+}
@@ -0,0 +1,174 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/products/db"
+	models "encore.app/products/models"
+	utils "encore.app/products/utils"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// ProductPricesTB is the product price history and scheduled prices table instance.
+var ProductPricesTB = &db.ProductPricesTB{DB: PlamatioDB}
+
+// Maximum number of scheduled prices applied per run of the scheduled prices job.
+const scheduledPriceBatchSize = 100
+
+// ------------------------------------------------------
+// Setup Cron Jobs
+
+// Applies scheduled prices once they are due.
+var _ = cron.NewJob("apply-scheduled-prices", cron.JobConfig{
+	Title:    "Apply scheduled product prices",
+	Every:    5 * cron.Minute,
+	Endpoint: ApplyScheduledPrices,
+})
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /products/prices/history/:id
+// Retrieves the price history of the product with the given ID, oldest first.
+//encore:api auth method=GET path=/products/prices/history/:id
+func GetPriceHistory(ctx context.Context, id int) (*models.ProductPriceHistory, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Confirm the product exists.
+	if _, err := getProductForChange(ctx, id); err != nil {
+		return nil, err
+	}
+	return ProductPricesTB.GetHistory(ctx, id)
+}
+
+// GET: /products/prices/scheduled/:id
+// Retrieves the scheduled prices of the product with the given ID, earliest first.
+//encore:api auth method=GET path=/products/prices/scheduled/:id
+func GetScheduledPrices(ctx context.Context, id int) (*models.ScheduledPrices, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	return ProductPricesTB.GetScheduled(ctx, id)
+}
+
+// POST: /products/prices/schedule/:id
+// Schedules a new price for the product with the given ID. The price is applied
+// by the scheduled prices job once its effective time has passed.
+//encore:api auth method=POST path=/products/prices/schedule/:id
+func SchedulePrice(ctx context.Context, id int, p *models.ScheduledPriceRequestParams) (*models.ScheduledPrice, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the scheduled price.
+	if err := utils.ValidateScheduledPriceRequestParams(p, time.Now()); err != nil {
+		return nil, err
+	}
+	// Confirm the product exists.
+	if _, err := getProductForChange(ctx, id); err != nil {
+		return nil, err
+	}
+	// Schedule the price.
+	return ProductPricesTB.InsertScheduled(ctx, id, p, currentActor())
+}
+
+// PUT: /products/prices/scheduled/cancel/:id/:schedule_id
+// Cancels a pending scheduled price of the product with the given ID.
+//encore:api auth method=PUT path=/products/prices/scheduled/cancel/:id/:schedule_id
+func CancelScheduledPrice(ctx context.Context, id int, schedule_id int) (*models.ScheduledPrice, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Cancel the scheduled price.
+	sp, err := ProductPricesTB.CancelScheduled(ctx, schedule_id, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("no pending scheduled price %d for product %d", schedule_id, id),
+		}
+	}
+	return sp, err
+}
+
+// POST: /products/prices/scheduled/apply
+// Applies the scheduled prices that are due: each product's current price becomes its
+// previous price, the change is recorded in the price history and the product caches
+// are invalidated. A product-changed event is recorded in the outbox for each changed
+// product. Called by the scheduled prices cron job.
+//encore:api private method=POST path=/products/prices/scheduled/apply
+func ApplyScheduledPrices(ctx context.Context) error {
+	now := time.Now()
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the due scheduled prices.
+	due, err := ProductPricesTB.GetDueScheduledTx(ctx, tx, now, scheduledPriceBatchSize)
+	if err != nil {
+		return err
+	}
+	// Apply each price, in order of effective time.
+	changed := map[int]bool{}
+	for _, sp := range due {
+		previous, err := ProductsTB.SetPriceTx(ctx, tx, sp.ProductID, sp.Price)
+		if err != nil {
+			return err
+		}
+		if _, err := ProductPricesTB.InsertChangeTx(ctx, tx, sp.ProductID, sp.Price, previous, sp.Actor); err != nil {
+			return err
+		}
+		if err := ProductPricesTB.MarkAppliedTx(ctx, tx, sp.ID, now); err != nil {
+			return err
+		}
+		changed[sp.ProductID] = true
+	}
+	// Record each changed product, with its new price, in the outbox.
+	var products []*models.Product
+	for id := range changed {
+		p, err := ProductsTB.GetTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := enqueueProductChangedTx(ctx, tx, models.ProductPriceApplied, p); err != nil {
+			return err
+		}
+		products = append(products, p)
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Invalidate the cached listings of the changed products.
+	for _, p := range products {
+		invalidateProductCache(ctx, p.ID, p)
+	}
+	return nil
+}
+
+// currentActor returns the ID of the authenticated caller, or "system" when the
+// change is not made on behalf of a caller.
+func currentActor() string {
+	if uid, ok := auth.UserID(); ok {
+		return string(uid)
+	}
+	return "system"
+}
@@ -2,11 +2,18 @@ package products
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	categoriesdb "encore.app/categories/db"
 	db "encore.app/products/db"
 	models "encore.app/products/models"
+	utils "encore.app/products/utils"
+	"encore.dev/beta/errs"
 	rlog "encore.dev/rlog"
 	"encore.dev/storage/cache"
 	"encore.dev/storage/sqldb"
@@ -21,6 +28,12 @@ var PlamatioDB = sqldb.Named("plamatio_db")
 // ProductsTB is the products table instance.
 var ProductsTB = &db.ProductsTB{DB: PlamatioDB}
 
+// CategoriesTable instance, used to validate and suggest categories.
+var CategoriesTable = &categoriesdb.CategoriesTable{DB: PlamatioDB}
+
+// SubCategoriesTable instance, used to validate and suggest sub-categories.
+var SubCategoriesTable = &categoriesdb.SubCategoriesTable{DB: PlamatioDB}
+
 // ------------------------------------------------------
 // Setup Caching
 
@@ -49,9 +62,9 @@ var ProductSubCategoryCacheKeyspace = cache.NewStructKeyspace[int, models.Produc
 })
 
 
-// Product Search Cache Keyspace to store product search results by query.
-var ProductSearchCacheKeyspace = cache.NewStructKeyspace[string, models.Products](ProductsCluster, cache.KeyspaceConfig{
-	KeyPattern:    "product-search-cache/:key",
+// Product Search Cache Keyspace to store product search results by cache version, normalized query and parameters.
+var ProductSearchCacheKeyspace = cache.NewStructKeyspace[string, models.ProductSearchResults](ProductsCluster, cache.KeyspaceConfig{
+	KeyPattern:    "product-search-results-cache/:key",
 	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
 })
 
@@ -61,6 +74,12 @@ var ProductsCacheKeyspace = cache.NewStructKeyspace[string, models.Products](Pro
 	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
 })
 
+// Product List Cache Keyspace to store pages of products by cache version and normalized list query.
+var ProductListCacheKeyspace = cache.NewStructKeyspace[string, models.ProductPage](ProductsCluster, cache.KeyspaceConfig{
+	KeyPattern:    "product-list-cache/:key",
+	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
+})
+
 // Hero Products Cache Keyspace to store hero products.
 var HeroProductsCacheKeyspace = cache.NewStructKeyspace[string, models.Products](ProductsCluster, cache.KeyspaceConfig{
 	KeyPattern:    "hero-products-cache/:key",
@@ -71,14 +90,15 @@ var HeroProductsCacheKeyspace = cache.NewStructKeyspace[string, models.Products]
 // Setup API
 
 // GET: /products/get/:id
-// Retrieves the product from the database with the given ID.
+// Retrieves the product from the database with the given ID, with its prices in the
+// requested currency if one is given.
 //encore:api auth method=GET path=/products/get/:id
-func Get(ctx context.Context, id int) (*models.Product, error) {
+func Get(ctx context.Context, id int, p *models.ProductCurrencyParams) (*models.Product, error) {
 	// First, try retrieving the product from cache if it exists.
 	c, err := ProductCacheKeyspace.Get(ctx, id)
 	// if product is found (i.e., no error), return it
 	if err == nil {
-		return &c, nil
+		return displayProduct(ctx, p.Currency, &c)
 	}
 	// If the category is not found in cache, retrieve it from the database.
 	// Retrieve the product from the database.
@@ -91,73 +111,186 @@ func Get(ctx context.Context, id int) (*models.Product, error) {
 		return nil, err
 	}
 	// Return the product.
-	return r, err
+	return displayProduct(ctx, p.Currency, r)
 }
 
 // POST: /products
 // Inserts a product into the database.
-//encore:api private method=POST path=/products/add
+//encore:api auth method=POST path=/products/add
 func Insert(ctx context.Context, p *models.ProductRequestParams) (*models.Product, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the product data.
+	if err := validateProduct(ctx, p); err != nil {
+		return nil, err
+	}
+	// Start the transaction, so the product is never stored without its price history.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 	// Insert the product into the database.
-	if id, err := ProductsTB.Insert(ctx, p); err != nil {
+	id, err := ProductsTB.InsertTx(ctx, tx, p)
+	if err != nil {
+		return nil, err
+	}
+	// Record the initial price.
+	if _, err := ProductPricesTB.InsertChangeTx(ctx, tx, id, p.Price, p.PreviousPrice, currentActor()); err != nil {
+		return nil, err
+	}
+	product := &models.Product{
+		ID: id, 
+		Name: p.Name, 
+		Description: p.Description, 
+		CategoryId: p.CategoryId, 
+		SubCategoryId: p.SubCategoryId, 
+		ImageURL: p.ImageURL, 
+		Price: p.Price, 
+		PreviousPrice: p.PreviousPrice, 
+		Offered: p.Offered,
+		Currency: p.Currency}
+	// Record the new product in the outbox.
+	if err := enqueueProductChangedTx(ctx, tx, models.ProductCreated, product); err != nil {
 		return nil, err
-	} else {
-		// Return the product.
-		return &models.Product{
-			ID: id, 
-			Name: p.Name, 
-			Description: p.Description, 
-			CategoryId: p.CategoryId, 
-			SubCategoryId: p.SubCategoryId, 
-			ImageURL: p.ImageURL, 
-			Price: p.Price, 
-			PreviousPrice: p.PreviousPrice, 
-			Offered: p.Offered}, nil
 	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire a go routine to invalidate the cached listings the product now appears in.
+	go invalidateProductCache(ctx, id, product)
+	// Return the product.
+	return product, nil
 }
 
 // DELETE: /products/delete/:id
 // Deletes the product from the database with the given ID.
-//encore:api private method=DELETE path=/products/delete/:id
+//encore:api auth method=DELETE path=/products/delete/:id
 func Delete(ctx context.Context, id int) error {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return err
+	}
+	// Retrieve the product, to know which cached listings it appears in.
+	old, err := getProductForChange(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Start the transaction, so the product is never deleted without its event.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 	// Delete the product from the database.
-	if err := ProductsTB.Delete(ctx, id); err != nil {
+	if err := ProductsTB.DeleteTx(ctx, tx, id); err != nil {
 		return err
 	}
+	// Record the deleted product in the outbox.
+	if err := enqueueProductChangedTx(ctx, tx, models.ProductDeleted, old); err != nil {
+		return err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// Fire a go routine to invalidate the product cache.
+	go invalidateProductCache(ctx, id, old)
 	// Return nil if successful.
 	return nil
 }
 
 // PUT: /products/update/:id
 // Updates the product in the database with the given ID.
-//encore:api private method=PUT path=/products/update/:id
+//encore:api auth method=PUT path=/products/update/:id
 func Update(ctx context.Context, id int, p *models.ProductRequestParams) (*models.Product, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the product data.
+	if err := validateProduct(ctx, p); err != nil {
+		return nil, err
+	}
+	// Retrieve the current product, to know which cached listings it appears in.
+	old, err := getProductForChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Start the transaction, so the price is never changed without being recorded.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Retrieve (and lock) the current price.
+	price, err := ProductsTB.GetPriceForUpdateTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
 	// Update the product in the database.
-	if err := ProductsTB.Update(ctx, id, p); err != nil {
+	if err := ProductsTB.UpdateTx(ctx, tx, id, p); err != nil {
+		return nil, err
+	}
+	// Record the price change, if any.
+	if p.Price != price {
+		if _, err := ProductPricesTB.InsertChangeTx(ctx, tx, id, p.Price, price, currentActor()); err != nil {
+			return nil, err
+		}
+	}
+	product := &models.Product{
+		ID: id, 
+		Name: p.Name, 
+		Description: p.Description, 
+		CategoryId: p.CategoryId, 
+		SubCategoryId: p.SubCategoryId, 
+		ImageURL: p.ImageURL, 
+		Price: p.Price, 
+		PreviousPrice: p.PreviousPrice, 
+		Offered: p.Offered,
+		AverageRating: old.AverageRating,
+		ReviewCount: old.ReviewCount,
+		Currency: p.Currency}
+	// Record the updated product in the outbox.
+	if err := enqueueProductChangedTx(ctx, tx, models.ProductUpdated, product); err != nil {
 		return nil, err
 	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Fire a go routine to invalidate the cached listings of both the old and the updated product.
+	go invalidateProductCache(ctx, id, old, product)
 	// Return the updated product.
-	return &models.Product{
-			ID: id, 
-			Name: p.Name, 
-			Description: p.Description, 
-			CategoryId: p.CategoryId, 
-			SubCategoryId: p.SubCategoryId, 
-			ImageURL: p.ImageURL, 
-			Price: p.Price, 
-			PreviousPrice: p.PreviousPrice, 
-			Offered: p.Offered}, nil
+	return product, nil
+}
+
+// getProductForChange retrieves the product with the given ID from the database,
+// bypassing the cache, before it is updated or deleted.
+func getProductForChange(ctx context.Context, id int) (*models.Product, error) {
+	p, err := ProductsTB.Get(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("product %d not found", id),
+		}
+	}
+	return p, err
 }
 
 // GET: /products/all
-// Retrieves all products from the database.
+// Retrieves all products from the database, with their prices in the requested
+// currency if one is given.
 //encore:api auth method=GET path=/products/all
-func GetAll(ctx context.Context) (*models.Products, error) {
+func GetAll(ctx context.Context, p *models.ProductCurrencyParams) (*models.Products, error) {
 	// First, try retrieving all products from cache if they exist.
 	c, err := ProductsCacheKeyspace.Get(ctx, "all")
 	// if products are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displayProductList(ctx, p.Currency, &c)
 	}
 	// If the products are not found in cache, retrieve them from the database.
 	r, err := ProductsTB.GetAll(ctx)
@@ -173,18 +306,76 @@ func GetAll(ctx context.Context) (*models.Products, error) {
 		}
 	}()
 	// Return the products.
-	return r, err
+	return displayProductList(ctx, p.Currency, r)
+}
+
+// GET: /products/list
+// Retrieves a page of products, filtered and sorted by the given query parameters.
+// Pass the returned nextCursor as cursor to retrieve the following page.
+//encore:api auth method=GET path=/products/list
+func List(ctx context.Context, p *models.ProductListParams) (*models.ProductPage, error) {
+	// Validate the query parameters.
+	utils.ApplyProductListDefaults(p)
+	if err := utils.ValidateProductListParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	var after *models.ProductCursor
+	if p.Cursor != "" {
+		c, err := utils.DecodeProductCursor(p.Cursor, p.Sort)
+		if err != nil {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: err.Error(),
+			}
+		}
+		after = c
+	}
+	// First, try retrieving the page from cache if it exists.
+	key := versionedCacheKey(ctx, utils.ProductListCacheKey(p))
+	c, err := ProductListCacheKeyspace.Get(ctx, key)
+	// if page is found (i.e., no error), return it
+	if err == nil {
+		return displayProductPage(ctx, p.Currency, &c)
+	}
+	// If the page is not found in cache, retrieve it from the database.
+	// One extra product is requested to know whether there is a next page.
+	q := *p
+	q.Limit = p.Limit + 1
+	products, err := ProductsTB.List(ctx, &q, after)
+	if err != nil {
+		return nil, err
+	}
+	r := &models.ProductPage{Data: products}
+	if len(products) > p.Limit {
+		r.Data = products[:p.Limit]
+		last := r.Data[len(r.Data)-1]
+		r.NextCursor = utils.EncodeProductCursor(&models.ProductCursor{Sort: p.Sort, ID: last.ID, Price: last.Price, Name: last.Name})
+	}
+	// Fire a go routine to cache the page.
+	go func() {
+		// Cache the page.
+		if err := ProductListCacheKeyspace.Set(ctx, key, *r); err != nil {
+			// Log the error
+			rlog.Error("error caching product list data", err)
+		}
+	}()
+	// Return the page.
+	return displayProductPage(ctx, p.Currency, r)
 }
 
 // GET: /products/category/:id
-// Retrieves all products from the database by category.
+// Retrieves all products from the database by category, with their prices in the
+// requested currency if one is given.
 //encore:api auth method=GET path=/products/category/:id
-func GetByCategory(ctx context.Context, id int) (*models.Products, error) {
+func GetByCategory(ctx context.Context, id int, p *models.ProductCurrencyParams) (*models.Products, error) {
 	// First, try retrieving all products from cache if they exist.
 	c, err := ProductCategoryCacheKeyspace.Get(ctx, id)
 	// if products are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displayProductList(ctx, p.Currency, &c)
 	}
 	// If the products are not found in cache, retrieve them from the database.
 	r, err := ProductsTB.GetByCategory(ctx, id)
@@ -200,18 +391,19 @@ func GetByCategory(ctx context.Context, id int) (*models.Products, error) {
 		}
 	}()
 	// Return the products.
-	return r, err
+	return displayProductList(ctx, p.Currency, r)
 }
 
 // GET: /products/subcategory/:id
-// Retrieves all products from the database by sub-category.
+// Retrieves all products from the database by sub-category, with their prices in the
+// requested currency if one is given.
 //encore:api auth method=GET path=/products/subcategory/:id
-func GetBySubCategory(ctx context.Context, id int) (*models.Products, error) {
+func GetBySubCategory(ctx context.Context, id int, p *models.ProductCurrencyParams) (*models.Products, error) {
 	// First, try retrieving all products from cache if they exist.
 	c, err := ProductSubCategoryCacheKeyspace.Get(ctx, id)
 	// if products are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displayProductList(ctx, p.Currency, &c)
 	}
 	// If the products are not found in cache, retrieve them from the database.
 	r, err := ProductsTB.GetBySubCategory(ctx, id)
@@ -227,18 +419,19 @@ func GetBySubCategory(ctx context.Context, id int) (*models.Products, error) {
 		}
 	}()
 	// Return the products.
-	return r, err
+	return displayProductList(ctx, p.Currency, r)
 }
 
 // GET: /products/hero
-// Retrieves all hero products from the database.
+// Retrieves all hero products from the database, with their prices in the requested
+// currency if one is given.
 //encore:api auth method=GET path=/products/hero
-func GetHeroProducts(ctx context.Context) (*models.Products, error) {
+func GetHeroProducts(ctx context.Context, p *models.ProductCurrencyParams) (*models.Products, error) {
 	// First, try retrieving all hero products from cache if they exist.
 	c, err := HeroProductsCacheKeyspace.Get(ctx, "all")
 	// if hero products are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displayProductList(ctx, p.Currency, &c)
 	}
 	// If the hero products are not found in cache, retrieve them from the database.
 	r, err := ProductsTB.GetHeroProducts(ctx)
@@ -254,17 +447,18 @@ func GetHeroProducts(ctx context.Context) (*models.Products, error) {
 		}
 	}()
 	// Return the products.
-	return r, err
+	return displayProductList(ctx, p.Currency, r)
 }
 
 // GET: /products/hero/category/:category
-// Retrieves all hero products from the database by category.
+// Retrieves all hero products from the database by category, with their prices in the
+// requested currency if one is given.
 //encore:api auth method=GET path=/products/hero/category/:category
-func GetHeroProductsByCategory(ctx context.Context, category int) (*models.Products, error) {
+func GetHeroProductsByCategory(ctx context.Context, category int, p *models.ProductCurrencyParams) (*models.Products, error) {
 	c, err := HeroProductsCacheKeyspace.Get(ctx, strconv.Itoa(category))
 	// if hero products are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displayProductList(ctx, p.Currency, &c)
 	}
 	// If the hero products are not found in cache, retrieve them from the database.
 	r, err := ProductsTB.GetCategoryHeroProducts(ctx, category)
@@ -280,32 +474,68 @@ func GetHeroProductsByCategory(ctx context.Context, category int) (*models.Produ
 		}
 	}()
 	// Return the products.
-	return r, err
+	return displayProductList(ctx, p.Currency, r)
 }
 
 // GET: /products/search/:query
-// Retrieves all products from the database by search query.
+// Retrieves products matching a full-text search over product name and description,
+// most relevant first. Use /products/v2/search/:query for ranks, highlighted snippets
+// and paging.
 //encore:api auth method=GET path=/products/search/:query
-func Search(ctx context.Context, query string) (*models.Products, error) {
-	// First, try retrieving all products from cache if they exist.
-	c, err := ProductSearchCacheKeyspace.Get(ctx, query)
-	// if products are found (i.e., no error), return them
+func Search(ctx context.Context, query string, p *models.ProductSearchParams) (*models.Products, error) {
+	results, err := SearchV2(ctx, query, p)
+	if err != nil {
+		return nil, err
+	}
+	products := &models.Products{}
+	for _, r := range results.Data {
+		products.Data = append(products.Data, r.Product)
+	}
+	return products, nil
+}
+
+// GET: /products/v2/search/:query
+// Retrieves products matching a full-text search over product name and description,
+// ranked by relevance, with highlighted snippets.
+//encore:api auth method=GET path=/products/v2/search/:query
+func SearchV2(ctx context.Context, query string, p *models.ProductSearchParams) (*models.ProductSearchResults, error) {
+	// Normalize and validate the search query and parameters.
+	query = utils.NormalizeSearchQuery(query)
+	utils.ApplyProductSearchDefaults(p)
+	if err := utils.ValidateProductSearchParams(query, p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// First, try retrieving the search results from cache if they exist.
+	key := versionedCacheKey(ctx, utils.ProductSearchCacheKey(query, p))
+	c, err := ProductSearchCacheKeyspace.Get(ctx, key)
+	// if search results are found (i.e., no error), return them
 	if err == nil {
-		return &c, nil
+		return displaySearchResults(ctx, p.Currency, &c)
 	}
-	// If the products are not found in cache, retrieve them from the database.
-	r, err := ProductsTB.Search(ctx, query)
+	// If the search results are not found in cache, retrieve them from the database.
+	// One extra result is requested to know whether there is a next page.
+	q := *p
+	q.Limit = p.Limit + 1
+	results, err := ProductsTB.Search(ctx, query, &q)
 	if err != nil {
 		return nil, err
 	}
-	// Fire a go routine to cache the products.
+	r := &models.ProductSearchResults{Data: results}
+	if len(results) > p.Limit {
+		r.Data = results[:p.Limit]
+		r.NextOffset = p.Offset + p.Limit
+	}
+	// Fire a go routine to cache the search results.
 	go func() {
-		// Cache the products.
-		if err := ProductSearchCacheKeyspace.Set(ctx, query, *r); err != nil {
+		// Cache the search results.
+		if err := ProductSearchCacheKeyspace.Set(ctx, key, *r); err != nil {
 			// Log the error
 			rlog.Error("error caching product search data", err)
 		}
 	}()
-	// Return the products.
-	return r, err
+	// Return the search results.
+	return displaySearchResults(ctx, p.Currency, r)
 }
\ No newline at end of file
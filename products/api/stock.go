@@ -0,0 +1,139 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	models "encore.app/products/models"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /products/stock/:id
+// Retrieves the available stock of the product with the given ID.
+// Stock is not cached since it changes with every order.
+//encore:api auth method=GET path=/products/stock/:id
+func GetStock(ctx context.Context, id int) (*models.ProductStock, error) {
+	stock, err := ProductsTB.GetStock(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "product not found",
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.ProductStock{ProductID: id, Stock: stock}, nil
+}
+
+// PUT: /products/stock/:id
+// Adjusts the available stock of the product with the given ID by the given delta.
+//encore:api auth method=PUT path=/products/stock/:id
+func AdjustStock(ctx context.Context, id int, p *models.StockAdjustmentParams) (*models.ProductStock, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Confirm the product exists.
+	product, err := ProductsTB.GetTx(ctx, tx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: "product not found",
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Adjust the stock.
+	stock, err := ProductsTB.AdjustStockTx(ctx, tx, id, p.Delta)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: "stock cannot be negative",
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Record the product change in the outbox.
+	if err := enqueueProductChangedTx(ctx, tx, models.ProductUpdated, product); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &models.ProductStock{ProductID: id, Stock: stock}, nil
+}
+
+// POST: /products/stock/import
+// Sets the available stock of each given product, e.g. from a warehouse stock count.
+// Every product must exist; the stock levels are imported in a single transaction.
+//encore:api auth method=POST path=/products/stock/import
+func ImportStock(ctx context.Context, p *models.StockImportParams) (*models.ProductStocks, error) {
+	// Confirm the caller may manage the catalog.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the stock levels.
+	if len(p.Data) == 0 {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: "stock levels are required",
+		}
+	}
+	for _, s := range p.Data {
+		if s == nil || s.ProductID <= 0 || s.Stock < 0 {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: "each stock level requires a productId and a stock that is not negative",
+			}
+		}
+	}
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Set the stock of each product.
+	for _, s := range p.Data {
+		err := ProductsTB.SetStockTx(ctx, tx, s.ProductID, s.Stock)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return nil, &errs.Error{
+				Code:    errs.NotFound,
+				Message: fmt.Sprintf("product %d not found", s.ProductID),
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Record the product change in the outbox.
+		product, err := ProductsTB.GetTx(ctx, tx, s.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if err := enqueueProductChangedTx(ctx, tx, models.ProductUpdated, product); err != nil {
+			return nil, err
+		}
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &models.ProductStocks{Data: p.Data}, nil
+}
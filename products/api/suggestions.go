@@ -0,0 +1,91 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "encore.app/products/models"
+	utils "encore.app/products/utils"
+	"encore.dev/beta/errs"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/cache"
+)
+
+// ------------------------------------------------------
+// Setup Caching
+
+// Search Suggestions Cache Keyspace to store search suggestions by cache version, normalized prefix and limit.
+var SearchSuggestionsCacheKeyspace = cache.NewStructKeyspace[string, models.SearchSuggestions](ProductsCluster, cache.KeyspaceConfig{
+	KeyPattern:    "search-suggestions-cache/:key",
+	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
+})
+
+// ------------------------------------------------------
+// Setup API
+
+// GET: /products/suggest/:prefix
+// Retrieves product, category and sub-category names matching a search prefix,
+// tolerating small typos. Intended for as-you-type search suggestions.
+//encore:api auth method=GET path=/products/suggest/:prefix
+func Suggest(ctx context.Context, prefix string, p *models.SearchSuggestionParams) (*models.SearchSuggestions, error) {
+	// Normalize and validate the prefix and parameters.
+	prefix = utils.NormalizeSearchQuery(prefix)
+	utils.ApplySearchSuggestionDefaults(p)
+	if err := utils.ValidateSearchSuggestionParams(prefix, p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// First, try retrieving the suggestions from cache if they exist.
+	key := versionedCacheKey(ctx, fmt.Sprintf("q=%s&limit=%d", prefix, p.Limit))
+	c, err := SearchSuggestionsCacheKeyspace.Get(ctx, key)
+	// if suggestions are found (i.e., no error), return them
+	if err == nil {
+		return &c, nil
+	}
+	// If the suggestions are not found in cache, retrieve them from the database, in a
+	// transaction scoping the similarity threshold used by the trigram indexes.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := ProductsTB.SetWordSimilarityThresholdTx(ctx, tx, utils.SuggestionSimilarity); err != nil {
+		return nil, err
+	}
+	pattern := utils.PrefixLikePattern(prefix)
+	products, err := ProductsTB.SuggestTx(ctx, tx, prefix, pattern, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := CategoriesTable.SuggestTx(ctx, tx, prefix, pattern, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+	subCategories, err := SubCategoriesTable.SuggestTx(ctx, tx, prefix, pattern, p.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r := &models.SearchSuggestions{Products: products}
+	for _, c := range categories.Data {
+		r.Categories = append(r.Categories, &models.SearchSuggestion{ID: c.ID, Name: c.Name})
+	}
+	for _, sc := range subCategories.Data {
+		r.SubCategories = append(r.SubCategories, &models.SearchSuggestion{ID: sc.ID, Name: sc.Name, CategoryId: sc.CategoryId})
+	}
+	// Fire a go routine to cache the suggestions.
+	go func() {
+		// Cache the suggestions.
+		if err := SearchSuggestionsCacheKeyspace.Set(ctx, key, *r); err != nil {
+			// Log the error
+			rlog.Error("error caching search suggestions data", err)
+		}
+	}()
+	// Return the suggestions.
+	return r, nil
+}
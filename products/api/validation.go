@@ -0,0 +1,45 @@
+package products
+
+import (
+	"context"
+	"errors"
+
+	models "encore.app/products/models"
+	utils "encore.app/products/utils"
+	"encore.dev/storage/sqldb"
+)
+
+// validateProduct validates the product request parameters, including that the
+// category and sub-category exist and the sub-category belongs to the category.
+// The taxonomy is read from the database rather than the cache, so a deleted or
+// moved sub-category is rejected as soon as the change is made.
+func validateProduct(ctx context.Context, p *models.ProductRequestParams) error {
+	if err := utils.ValidateProductRequestParams(p); err != nil {
+		return err
+	}
+	t, err := loadProductTaxonomy(ctx, p.CategoryId, p.SubCategoryId)
+	if err != nil {
+		return err
+	}
+	return utils.ValidateProductTaxonomy(t, p.CategoryId, p.SubCategoryId)
+}
+
+// loadProductTaxonomy retrieves the given category and sub-category, if they exist, from the database.
+func loadProductTaxonomy(ctx context.Context, categoryId int, subCategoryId int) (*models.ProductTaxonomy, error) {
+	t := &models.ProductTaxonomy{}
+	c, err := CategoriesTable.Get(ctx, categoryId)
+	if err != nil && !errors.Is(err, sqldb.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		t.CategoryIds = append(t.CategoryIds, c.ID)
+	}
+	sc, err := SubCategoriesTable.GetSubCategory(ctx, subCategoryId)
+	if err != nil && !errors.Is(err, sqldb.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		t.SubCategories = append(t.SubCategories, &models.SubCategoryRef{ID: sc.ID, CategoryId: sc.CategoryId})
+	}
+	return t, nil
+}
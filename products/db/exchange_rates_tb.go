@@ -0,0 +1,103 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/products/models"
+	"encore.dev/storage/sqldb"
+)
+
+type ExchangeRatesTB struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ALL_EXCHANGE_RATES = `
+				SELECT base_currency, quote_currency, rate, updated_by, updated_at FROM exchange_rates
+				ORDER BY base_currency, quote_currency
+		`
+		SQL_GET_EXCHANGE_RATE = `
+				SELECT rate FROM exchange_rates
+				WHERE base_currency = $1 AND quote_currency = $2
+		`
+		SQL_GET_EXCHANGE_RATES_TO = `
+				SELECT base_currency, rate FROM exchange_rates
+				WHERE quote_currency = $1
+		`
+		SQL_UPSERT_EXCHANGE_RATE = `
+				INSERT INTO exchange_rates (base_currency, quote_currency, rate, updated_by, updated_at)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (base_currency, quote_currency)
+				DO UPDATE SET rate = EXCLUDED.rate, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		`
+		SQL_DELETE_EXCHANGE_RATE = `
+				DELETE FROM exchange_rates
+				WHERE base_currency = $1 AND quote_currency = $2
+				RETURNING rate
+		`
+)
+
+// Retrieves all exchange rates from the database.
+func (tb *ExchangeRatesTB) GetAll(ctx context.Context) (*models.ExchangeRates, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_EXCHANGE_RATES)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := &models.ExchangeRates{}
+	for rows.Next() {
+		r := &models.ExchangeRate{}
+		if err := rows.Scan(&r.BaseCurrency, &r.QuoteCurrency, &r.Rate, &r.UpdatedBy, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates.Data = append(rates.Data, r)
+	}
+	return rates, nil
+}
+
+// Retrieves the rate from one currency to another.
+// Returns sqldb.ErrNoRows if no rate has been set.
+func (tb *ExchangeRatesTB) GetRate(ctx context.Context, base string, quote string) (float64, error) {
+	var rate float64
+	err := tb.DB.QueryRow(ctx, SQL_GET_EXCHANGE_RATE, base, quote).Scan(&rate)
+	return rate, err
+}
+
+// Retrieves the rates into the given currency, by the currency they convert from.
+func (tb *ExchangeRatesTB) GetRatesTo(ctx context.Context, quote string) (map[string]float64, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_EXCHANGE_RATES_TO, quote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := map[string]float64{}
+	for rows.Next() {
+		var base string
+		var rate float64
+		if err := rows.Scan(&base, &rate); err != nil {
+			return nil, err
+		}
+		rates[base] = rate
+	}
+	return rates, nil
+}
+
+// Sets the rate from one currency to another, replacing any existing rate.
+func (tb *ExchangeRatesTB) Upsert(ctx context.Context, base string, quote string, rate float64, actor string) (*models.ExchangeRate, error) {
+	r := &models.ExchangeRate{BaseCurrency: base, QuoteCurrency: quote, Rate: rate, UpdatedBy: actor, UpdatedAt: time.Now()}
+	_, err := tb.DB.Exec(ctx, SQL_UPSERT_EXCHANGE_RATE, base, quote, rate, actor, r.UpdatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Deletes the rate from one currency to another.
+// Returns sqldb.ErrNoRows if no rate has been set.
+func (tb *ExchangeRatesTB) Delete(ctx context.Context, base string, quote string) error {
+	var rate float64
+	return tb.DB.QueryRow(ctx, SQL_DELETE_EXCHANGE_RATE, base, quote).Scan(&rate)
+}
@@ -0,0 +1,236 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "encore.app/products/models"
+	"encore.dev/storage/sqldb"
+)
+
+type ProductPricesTB struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_PRODUCT_PRICE_HISTORY = `
+				SELECT id, product_id, price, previous_price, actor, changed_at FROM product_prices
+				WHERE product_id = $1
+				ORDER BY changed_at, id
+		`
+		SQL_INSERT_PRODUCT_PRICE_CHANGE = `
+				INSERT INTO product_prices (product_id, price, previous_price, actor, changed_at) VALUES ($1, $2, $3, $4, $5) RETURNING id
+		`
+		SQL_SCHEDULED_PRICE_COLUMNS = `
+				id, product_id, price, effective_at, status, actor, created_at, applied_at
+		`
+		SQL_GET_SCHEDULED_PRICES = `
+				SELECT` + SQL_SCHEDULED_PRICE_COLUMNS + `FROM scheduled_prices
+				WHERE product_id = $1
+				ORDER BY effective_at, id
+		`
+		SQL_INSERT_SCHEDULED_PRICE = `
+				INSERT INTO scheduled_prices (product_id, price, effective_at, actor)
+				VALUES ($1, $2, $3, $4)
+				RETURNING` + SQL_SCHEDULED_PRICE_COLUMNS
+		SQL_CANCEL_SCHEDULED_PRICE = `
+				UPDATE scheduled_prices
+				SET status = 'cancelled'
+				WHERE id = $1 AND product_id = $2 AND status = 'pending'
+				RETURNING` + SQL_SCHEDULED_PRICE_COLUMNS
+		SQL_GET_DUE_SCHEDULED_PRICES = `
+				SELECT` + SQL_SCHEDULED_PRICE_COLUMNS + `FROM scheduled_prices
+				WHERE status = 'pending' AND effective_at <= $1
+				ORDER BY effective_at, id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+		`
+		SQL_GET_PRICE_OVERRIDES = `
+				SELECT product_id, currency, price, updated_at FROM product_price_overrides
+				WHERE product_id = $1
+				ORDER BY currency
+		`
+		SQL_GET_PRICE_OVERRIDES_IN = `
+				SELECT product_id, price FROM product_price_overrides
+				WHERE currency = $1 AND product_id = ANY($2::BIGINT[])
+		`
+		SQL_UPSERT_PRICE_OVERRIDE = `
+				INSERT INTO product_price_overrides (product_id, currency, price, updated_at)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (product_id, currency)
+				DO UPDATE SET price = EXCLUDED.price, updated_at = EXCLUDED.updated_at
+		`
+		SQL_DELETE_PRICE_OVERRIDE = `
+				DELETE FROM product_price_overrides
+				WHERE product_id = $1 AND currency = $2
+				RETURNING price
+		`
+		SQL_MARK_SCHEDULED_PRICE_APPLIED = `
+				UPDATE scheduled_prices
+				SET status = 'applied', applied_at = $1
+				WHERE id = $2
+		`
+)
+
+// Retrieves the price history of a product from the database, oldest first.
+func (tb *ProductPricesTB) GetHistory(ctx context.Context, productId int) (*models.ProductPriceHistory, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_PRODUCT_PRICE_HISTORY, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.ProductPriceHistory{}
+	for rows.Next() {
+		c := &models.ProductPriceChange{}
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.Price, &c.PreviousPrice, &c.Actor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		history.Data = append(history.Data, c)
+	}
+	return history, nil
+}
+
+// Records a price change of a product as part of the given transaction.
+func (tb *ProductPricesTB) InsertChangeTx(ctx context.Context, tx *sqldb.Tx, productId int, price int, previousPrice int, actor string) (*models.ProductPriceChange, error) {
+	c := &models.ProductPriceChange{ProductID: productId, Price: price, PreviousPrice: previousPrice, Actor: actor, ChangedAt: time.Now().UTC()}
+	err := tx.QueryRow(ctx, SQL_INSERT_PRODUCT_PRICE_CHANGE, productId, price, previousPrice, actor, c.ChangedAt.Format(time.RFC3339)).Scan(&c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Retrieves the scheduled prices of a product from the database, earliest first.
+func (tb *ProductPricesTB) GetScheduled(ctx context.Context, productId int) (*models.ScheduledPrices, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_SCHEDULED_PRICES, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := &models.ScheduledPrices{}
+	for rows.Next() {
+		sp, err := scanScheduledPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices.Data = append(prices.Data, sp)
+	}
+	return prices, nil
+}
+
+// Schedules a price for a product.
+func (tb *ProductPricesTB) InsertScheduled(ctx context.Context, productId int, p *models.ScheduledPriceRequestParams, actor string) (*models.ScheduledPrice, error) {
+	return scanScheduledPrice(tb.DB.QueryRow(ctx, SQL_INSERT_SCHEDULED_PRICE, productId, p.Price, p.EffectiveAt.UTC().Format(time.RFC3339), actor))
+}
+
+// Cancels a pending scheduled price of a product.
+// Returns sqldb.ErrNoRows if there is no such pending scheduled price.
+func (tb *ProductPricesTB) CancelScheduled(ctx context.Context, id int, productId int) (*models.ScheduledPrice, error) {
+	return scanScheduledPrice(tb.DB.QueryRow(ctx, SQL_CANCEL_SCHEDULED_PRICE, id, productId))
+}
+
+// Retrieves (and locks) up to limit pending scheduled prices that are due at the given time,
+// as part of the given transaction. Prices locked by another transaction are skipped.
+func (tb *ProductPricesTB) GetDueScheduledTx(ctx context.Context, tx *sqldb.Tx, now time.Time, limit int) ([]*models.ScheduledPrice, error) {
+	rows, err := tx.Query(ctx, SQL_GET_DUE_SCHEDULED_PRICES, now.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []*models.ScheduledPrice
+	for rows.Next() {
+		sp, err := scanScheduledPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, sp)
+	}
+	return prices, nil
+}
+
+// Marks a scheduled price as applied as part of the given transaction.
+func (tb *ProductPricesTB) MarkAppliedTx(ctx context.Context, tx *sqldb.Tx, id int, now time.Time) error {
+	_, err := tx.Exec(ctx, SQL_MARK_SCHEDULED_PRICE_APPLIED, now.UTC().Format(time.RFC3339), id)
+	return err
+}
+
+// Retrieves the price overrides of a product from the database.
+func (tb *ProductPricesTB) GetOverrides(ctx context.Context, productId int) (*models.PriceOverrides, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_PRICE_OVERRIDES, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := &models.PriceOverrides{}
+	for rows.Next() {
+		o := &models.PriceOverride{}
+		if err := rows.Scan(&o.ProductID, &o.Currency, &o.Price, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		overrides.Data = append(overrides.Data, o)
+	}
+	return overrides, nil
+}
+
+// Retrieves the prices of the given products overridden in the given currency, by product ID.
+func (tb *ProductPricesTB) GetOverridesIn(ctx context.Context, productIds []int, currency string) (map[int]int, error) {
+	ids := make([]int64, len(productIds))
+	for i, id := range productIds {
+		ids[i] = int64(id)
+	}
+	rows, err := tb.DB.Query(ctx, SQL_GET_PRICE_OVERRIDES_IN, currency, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := map[int]int{}
+	for rows.Next() {
+		var id, price int
+		if err := rows.Scan(&id, &price); err != nil {
+			return nil, err
+		}
+		overrides[id] = price
+	}
+	return overrides, nil
+}
+
+// Sets the price of a product in a currency, replacing any existing override.
+func (tb *ProductPricesTB) UpsertOverride(ctx context.Context, productId int, currency string, price int) (*models.PriceOverride, error) {
+	o := &models.PriceOverride{ProductID: productId, Currency: currency, Price: price, UpdatedAt: time.Now()}
+	_, err := tb.DB.Exec(ctx, SQL_UPSERT_PRICE_OVERRIDE, productId, currency, price, o.UpdatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Deletes the price override of a product in a currency.
+// Returns sqldb.ErrNoRows if there is no override.
+func (tb *ProductPricesTB) DeleteOverride(ctx context.Context, productId int, currency string) error {
+	var price int
+	return tb.DB.QueryRow(ctx, SQL_DELETE_PRICE_OVERRIDE, productId, currency).Scan(&price)
+}
+
+// scanner is implemented by both a single row and a set of rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanScheduledPrice scans a scheduled price selected with SQL_SCHEDULED_PRICE_COLUMNS.
+func scanScheduledPrice(row scanner) (*models.ScheduledPrice, error) {
+	sp := &models.ScheduledPrice{}
+	var appliedAt sql.NullTime
+	if err := row.Scan(&sp.ID, &sp.ProductID, &sp.Price, &sp.EffectiveAt, &sp.Status, &sp.Actor, &sp.CreatedAt, &appliedAt); err != nil {
+		return nil, err
+	}
+	if appliedAt.Valid {
+		sp.AppliedAt = &appliedAt.Time
+	}
+	return sp, nil
+}
@@ -2,6 +2,10 @@ package products
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 
 	models "encore.app/products/models"
 	utils "encore.app/products/utils"
@@ -14,56 +18,129 @@ type ProductsTB struct {
 
 const (
     SQL_INSERT_PRODUCT = `
-        INSERT INTO products (name, description, category_id, sub_category_id, image_url, price, previous_price, offered)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO products (name, description, category_id, sub_category_id, image_url, price, previous_price, offered, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
         RETURNING id
     `
+		SQL_GET_PRODUCT_PRICE_FOR_UPDATE = `
+				SELECT price FROM products
+				WHERE id = $1
+				FOR UPDATE
+		`
+		SQL_SET_PRODUCT_PRICE = `
+				UPDATE products
+				SET previous_price = price, price = $1
+				WHERE id = $2
+				RETURNING previous_price
+		`
     SQL_DELETE_PRODUCT = `
         DELETE FROM products
         WHERE id = $1
     `
     SQL_UPDATE_PRODUCT = `
         UPDATE products
-        SET name = $1, description = $2, category_id = $3, sub_category_id = $4, image_url = $5, price = $6, previous_price = $7, offered = $8
-        WHERE id = $9
+        SET name = $1, description = $2, category_id = $3, sub_category_id = $4, image_url = $5, price = $6, previous_price = $7, offered = $8, currency = $9
+        WHERE id = $10
     `
 		SQL_GET_PRODUCT = `
-        SELECT name, description, category_id, sub_category_id, image_url, price, previous_price, offered FROM products
+        SELECT name, description, category_id, sub_category_id, image_url, price, previous_price, offered, average_rating, review_count, currency FROM products
         WHERE id = $1
     `
     SQL_GET_ALL_PRODUCTS = `
-        SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered FROM products
+        SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered, average_rating, review_count, currency FROM products
     `
 		SQL_GET_PRODUCTS_BY_CATEGORY = `
-				SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered FROM products
+				SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered, average_rating, review_count, currency FROM products
 				WHERE category_id = $1
 		`
 		SQL_GET_PRODUCTS_BY_SUB_CATEGORY = `
-				SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered FROM products
+				SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered, average_rating, review_count, currency FROM products
 				WHERE sub_category_id = $1
 		`
 		SQL_GET_HERO_PRODUCTS = `
-				SELECT p.id, p.name, p.description, p.category_id, p.sub_category_id, p.image_url, p.price, p.previous_price, p.offered
+				SELECT p.id, p.name, p.description, p.category_id, p.sub_category_id, p.image_url, p.price, p.previous_price, p.offered, p.average_rating, p.review_count, p.currency
 				FROM products p
 				INNER JOIN hero_products hp ON p.id = hp.product_id
 		`
 		SQL_GET_CATEGORY_HERO_PRODUCTS_BY_CATEGORY = `
-				SELECT p.id, p.name, p.description, p.category_id, p.sub_category_id, p.image_url, p.price, p.previous_price, p.offered
+				SELECT p.id, p.name, p.description, p.category_id, p.sub_category_id, p.image_url, p.price, p.previous_price, p.offered, p.average_rating, p.review_count, p.currency
 				FROM products p
 				INNER JOIN category_hero_products chp ON p.id = chp.product_id
 				WHERE chp.category_id = $1
 		`
+		SQL_GET_PRODUCT_PRICING = `
+				SELECT p.price, p.previous_price, p.currency, p.category_id, p.sub_category_id, o.price, r.rate
+				FROM products p
+				LEFT JOIN product_price_overrides o ON o.product_id = p.id AND o.currency = $2
+				LEFT JOIN exchange_rates r ON r.base_currency = p.currency AND r.quote_currency = $2
+				WHERE p.id = $1
+		`
+		SQL_GET_PRODUCT_STOCK = `
+				SELECT stock FROM products
+				WHERE id = $1
+		`
+		SQL_LIST_PRODUCTS = `
+				SELECT id, name, description, category_id, sub_category_id, image_url, price, previous_price, offered, average_rating, review_count, currency FROM products
+		`
+		SQL_SEARCH_PRODUCTS = `
+				SELECT p.id, p.name, p.description, p.category_id, p.sub_category_id, p.image_url, p.price, p.previous_price, p.offered, p.average_rating, p.review_count, p.currency,
+					ts_rank_cd(p.search_vector, q) AS rank,
+					ts_headline('english', p.name, q, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true'),
+					ts_headline('english', p.description, q, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=25, MinWords=8')
+				FROM products p, websearch_to_tsquery('english', $1) q
+				WHERE p.search_vector @@ q
+					AND ($2::BIGINT = 0 OR p.category_id = $2)
+					AND ($3::BIGINT = 0 OR p.sub_category_id = $3)
+				ORDER BY rank DESC, p.id
+				LIMIT $4 OFFSET $5
+		`
+		SQL_SET_WORD_SIMILARITY_THRESHOLD = `
+				SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)
+		`
+		SQL_SUGGEST_PRODUCTS = `
+				SELECT id, name, category_id, sub_category_id FROM products
+				WHERE offered AND (name ILIKE $2 OR $1 <% name)
+				ORDER BY name ILIKE $2 DESC, word_similarity($1, name) DESC, name
+				LIMIT $3
+		`
+		SQL_LOCK_PRODUCT = `
+				SELECT id FROM products
+				WHERE id = $1
+				FOR UPDATE
+		`
+		SQL_REFRESH_PRODUCT_RATING = `
+				UPDATE products SET average_rating = r.average_rating, review_count = r.review_count
+				FROM (SELECT COALESCE(AVG(rating), 0) AS average_rating, COUNT(*) AS review_count FROM reviews WHERE product_id = $1) r
+				WHERE id = $1
+		`
+		SQL_SET_PRODUCT_STOCK = `
+				UPDATE products SET stock = $1
+				WHERE id = $2
+				RETURNING stock
+		`
+		SQL_ADJUST_PRODUCT_STOCK = `
+				UPDATE products SET stock = stock + $1
+				WHERE id = $2 AND stock + $1 >= 0
+				RETURNING stock
+		`
 )
 
 // Inserts a product into the database.
 // Inserts a product into the database and returns the id of the newly added record.
 func (pdb *ProductsTB) Insert(ctx context.Context, p *models.ProductRequestParams) (int, error) {
     var id int
-    err := pdb.DB.QueryRow(ctx, SQL_INSERT_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered).Scan(&id)
+    err := pdb.DB.QueryRow(ctx, SQL_INSERT_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, p.Currency).Scan(&id)
 		
     return id, err
 }
 
+// Inserts a product as part of the given transaction and returns the id of the newly added record.
+func (pdb *ProductsTB) InsertTx(ctx context.Context, tx *sqldb.Tx, p *models.ProductRequestParams) (int, error) {
+	var id int
+	err := tx.QueryRow(ctx, SQL_INSERT_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, p.Currency).Scan(&id)
+	return id, err
+}
+
 // Bulk inserts products into the database.
 func (pdb *ProductsTB) BulkInsert(ctx context.Context, products []*models.ProductRequestParams) error {
 	tx, err := pdb.DB.Begin(ctx)
@@ -84,7 +161,7 @@ func (pdb *ProductsTB) BulkInsert(ctx context.Context, products []*models.Produc
 		if err != nil {
 			return err
 		}
-		if _, err := stmt.Exec(p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered); err != nil {
+		if _, err := stmt.Exec(p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, p.Currency); err != nil {
 			return err
 		}
 	}
@@ -97,16 +174,50 @@ func (pdb *ProductsTB) Delete(ctx context.Context, id int) error {
 	return err
 }
 
+// Deletes a product as part of the given transaction.
+func (pdb *ProductsTB) DeleteTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_PRODUCT, id)
+	return err
+}
+
 // Updates a product in the database.
 func (pdb *ProductsTB) Update(ctx context.Context, id int, p *models.ProductRequestParams) error {
-	_, err := pdb.DB.Exec(ctx, SQL_UPDATE_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, id)
+	_, err := pdb.DB.Exec(ctx, SQL_UPDATE_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, p.Currency, id)
+	return err
+}
+
+// Updates a product as part of the given transaction.
+func (pdb *ProductsTB) UpdateTx(ctx context.Context, tx *sqldb.Tx, id int, p *models.ProductRequestParams) error {
+	_, err := tx.Exec(ctx, SQL_UPDATE_PRODUCT, p.Name, p.Description, p.CategoryId, p.SubCategoryId, p.ImageURL, p.Price, p.PreviousPrice, p.Offered, p.Currency, id)
 	return err
 }
 
+// Retrieves the current price of a product and locks the product until the given transaction ends.
+func (pdb *ProductsTB) GetPriceForUpdateTx(ctx context.Context, tx *sqldb.Tx, id int) (int, error) {
+	var price int
+	err := tx.QueryRow(ctx, SQL_GET_PRODUCT_PRICE_FOR_UPDATE, id).Scan(&price)
+	return price, err
+}
+
+// Sets the price of a product as part of the given transaction, moving the current
+// price to previous_price. Returns the previous price.
+func (pdb *ProductsTB) SetPriceTx(ctx context.Context, tx *sqldb.Tx, id int, price int) (int, error) {
+	var previous int
+	err := tx.QueryRow(ctx, SQL_SET_PRODUCT_PRICE, price, id).Scan(&previous)
+	return previous, err
+}
+
 // Retrieves a product from the database.
 func (pdb *ProductsTB) Get(ctx context.Context, id int) (*models.Product, error) {
 	p := &models.Product{ID: id}
-	err := pdb.DB.QueryRow(ctx, SQL_GET_PRODUCT, id).Scan(&p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered)
+	err := pdb.DB.QueryRow(ctx, SQL_GET_PRODUCT, id).Scan(&p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency)
+	return p, err
+}
+
+// Retrieves a product as part of the given transaction.
+func (pdb *ProductsTB) GetTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.Product, error) {
+	p := &models.Product{ID: id}
+	err := tx.QueryRow(ctx, SQL_GET_PRODUCT, id).Scan(&p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency)
 	return p, err
 }
 
@@ -121,7 +232,7 @@ func (pdb *ProductsTB) GetAll(ctx context.Context) (*models.Products, error) {
 	var products []*models.Product
 	for rows.Next() {
 		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -140,7 +251,7 @@ func (pdb *ProductsTB) GetByCategory(ctx context.Context, id int) (*models.Produ
 	var products []*models.Product
 	for rows.Next() {
 		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -159,7 +270,7 @@ func (pdb *ProductsTB) GetBySubCategory(ctx context.Context, id int) (*models.Pr
 	var products []*models.Product
 	for rows.Next() {
 		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -180,7 +291,7 @@ func (pdb *ProductsTB) GetHeroProducts(ctx context.Context) (*models.Products, e
 	var products []*models.Product
 	for rows.Next() {
 		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -201,7 +312,7 @@ func (pdb *ProductsTB) GetCategoryHeroProducts(ctx context.Context, id int) (*mo
 	var products []*models.Product
 	for rows.Next() {
 		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered, &p.AverageRating, &p.ReviewCount, &p.Currency); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -209,25 +320,228 @@ func (pdb *ProductsTB) GetCategoryHeroProducts(ctx context.Context, id int) (*mo
 	return &models.Products{Data: products}, nil
 }
 
-// Retrieve products based on search query.
-func (pdb *ProductsTB) Search(ctx context.Context, query string) (*models.Products, error) {
+// Retrieve products matching a full-text search query over name and description, most relevant first.
+func (pdb *ProductsTB) Search(ctx context.Context, query string, p *models.ProductSearchParams) ([]*models.ProductSearchResult, error) {
 	// Query the database for products based on search query.
-	rows, err := pdb.DB.Query(ctx, "SELECT id, name, description, category_id, image_url FROM products WHERE name ILIKE $1", "%"+query+"%")
+	rows, err := pdb.DB.Query(ctx, SQL_SEARCH_PRODUCTS, query, p.CategoryId, p.SubCategoryId, p.Limit, p.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Iterate through the rows and create a search result for each row.
+	var results []*models.ProductSearchResult
+	for rows.Next() {
+		r := &models.ProductSearchResult{Product: &models.Product{}}
+		pr := r.Product
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.Description, &pr.CategoryId, &pr.SubCategoryId, &pr.ImageURL, &pr.Price, &pr.PreviousPrice, &pr.Offered, &pr.AverageRating, &pr.ReviewCount, &pr.Currency, &r.Rank, &r.NameHighlight, &r.Snippet); err != nil {
+			return nil, err
+		}
+		// Escape the highlighted text before marking the matching terms.
+		r.NameHighlight, r.Snippet = utils.HighlightHTML(r.NameHighlight), utils.HighlightHTML(r.Snippet)
+		results = append(results, r)
+	}
+
+	return results, nil
+}
 
+// Retrieves the price, currency, category and sub-category of a product, used to price
+// order items, with its prices in the given currency set as Display. As for display, a
+// price override for the currency is used without an exchange rate; Display.Rate is then 0.
+func (pdb *ProductsTB) GetPricing(ctx context.Context, id int, currency string) (*models.Product, error) {
+	return scanProductPricing(pdb.DB.QueryRow(ctx, SQL_GET_PRODUCT_PRICING, id, currency), id, currency)
+}
+
+// Retrieves the pricing of a product, as GetPricing does, as part of the given transaction.
+func (pdb *ProductsTB) GetPricingTx(ctx context.Context, tx *sqldb.Tx, id int, currency string) (*models.Product, error) {
+	return scanProductPricing(tx.QueryRow(ctx, SQL_GET_PRODUCT_PRICING, id, currency), id, currency)
+}
+
+// scanProductPricing scans a row of SQL_GET_PRODUCT_PRICING into a product priced in the given currency.
+func scanProductPricing(row *sqldb.Row, id int, currency string) (*models.Product, error) {
+	p := &models.Product{ID: id}
+	var override sql.NullInt64
+	var rate sql.NullFloat64
+	if err := row.Scan(&p.Price, &p.PreviousPrice, &p.Currency, &p.CategoryId, &p.SubCategoryId, &override, &rate); err != nil {
+		return nil, err
+	}
+	var overridePrice *int
+	if override.Valid {
+		v := int(override.Int64)
+		overridePrice = &v
+	}
+	var ratePtr *float64
+	if rate.Valid {
+		ratePtr = &rate.Float64
+	}
+	display, err := utils.ConvertProductPrice(p, currency, overridePrice, ratePtr)
+	if err != nil {
+		return nil, err
+	}
+	p.Display = display
+	return p, nil
+}
+
+// Retrieves the available stock of a product.
+func (pdb *ProductsTB) GetStock(ctx context.Context, id int) (int, error) {
+	var stock int
+	err := pdb.DB.QueryRow(ctx, SQL_GET_PRODUCT_STOCK, id).Scan(&stock)
+	return stock, err
+}
+
+// Retrieves the available stock of a product as part of the given transaction.
+func (pdb *ProductsTB) GetStockTx(ctx context.Context, tx *sqldb.Tx, id int) (int, error) {
+	var stock int
+	err := tx.QueryRow(ctx, SQL_GET_PRODUCT_STOCK, id).Scan(&stock)
+	return stock, err
+}
+
+// Adjusts the available stock of a product by delta and returns the new stock.
+// Returns sqldb.ErrNoRows if the product does not exist or the stock would become negative.
+func (pdb *ProductsTB) AdjustStock(ctx context.Context, id int, delta int) (int, error) {
+	var stock int
+	err := pdb.DB.QueryRow(ctx, SQL_ADJUST_PRODUCT_STOCK, delta, id).Scan(&stock)
+	return stock, err
+}
+
+// Adjusts the available stock of a product by delta, as AdjustStock does, as part of the given transaction.
+func (pdb *ProductsTB) AdjustStockTx(ctx context.Context, tx *sqldb.Tx, id int, delta int) (int, error) {
+	var stock int
+	err := tx.QueryRow(ctx, SQL_ADJUST_PRODUCT_STOCK, delta, id).Scan(&stock)
+	return stock, err
+}
+
+// Sets the available stock of a product as part of the given transaction.
+// Returns sqldb.ErrNoRows if the product does not exist.
+func (pdb *ProductsTB) SetStockTx(ctx context.Context, tx *sqldb.Tx, id int, stock int) error {
+	return tx.QueryRow(ctx, SQL_SET_PRODUCT_STOCK, stock, id).Scan(&stock)
+}
+
+// Reserves quantity units of a product's stock as part of the given transaction.
+// Returns sqldb.ErrNoRows if the product does not exist or there is not enough stock.
+func (pdb *ProductsTB) ReserveStockTx(ctx context.Context, tx *sqldb.Tx, id int, quantity int) (int, error) {
+	var stock int
+	err := tx.QueryRow(ctx, SQL_ADJUST_PRODUCT_STOCK, -quantity, id).Scan(&stock)
+	return stock, err
+}
+
+// Releases quantity units of previously reserved stock as part of the given transaction.
+func (pdb *ProductsTB) ReleaseStockTx(ctx context.Context, tx *sqldb.Tx, id int, quantity int) error {
+	var stock int
+	return tx.QueryRow(ctx, SQL_ADJUST_PRODUCT_STOCK, quantity, id).Scan(&stock)
+}
+
+// Retrieves up to p.Limit products matching the given filters, sorted by the given field.
+// If after is set, only products sorted after the cursor are returned.
+func (pdb *ProductsTB) List(ctx context.Context, p *models.ProductListParams, after *models.ProductCursor) ([]*models.Product, error) {
+	// Build the filter conditions and their arguments.
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+	if p.CategoryId > 0 {
+		addCondition("category_id = $%d", p.CategoryId)
+	}
+	if p.SubCategoryId > 0 {
+		addCondition("sub_category_id = $%d", p.SubCategoryId)
+	}
+	if p.MinPrice > 0 {
+		addCondition("price >= $%d", p.MinPrice)
+	}
+	if p.MaxPrice > 0 {
+		addCondition("price <= $%d", p.MaxPrice)
+	}
+	if p.Offered != "" {
+		addCondition("offered = $%d", p.Offered == "true")
+	}
+	// Continue after the cursor, using the product ID to break ties.
+	cmp := ">"
+	if p.Order == "desc" {
+		cmp = "<"
+	}
+	if after != nil {
+		switch p.Sort {
+		case "price":
+			addCondition("(price, id) "+cmp+" ($%d, $%d)", after.Price, after.ID)
+		case "name":
+			addCondition("(name, id) "+cmp+" ($%d, $%d)", after.Name, after.ID)
+		default:
+			addCondition("id "+cmp+" $%d", after.ID)
+		}
+	}
+
+	// Sort field and order are validated by utils.ValidateProductListParams.
+	query := SQL_LIST_PRODUCTS
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	direction := strings.ToUpper(p.Order)
+	if p.Sort == "id" {
+		query += fmt.Sprintf(" ORDER BY id %s", direction)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", p.Sort, direction, direction)
+	}
+	args = append(args, p.Limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	rows, err := pdb.DB.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Iterate through the rows and create a product for each row.
 	var products []*models.Product
 	for rows.Next() {
-		p := &models.Product{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CategoryId, &p.SubCategoryId, &p.ImageURL, &p.Price, &p.PreviousPrice, &p.Offered); err != nil {
+		pr := &models.Product{}
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.Description, &pr.CategoryId, &pr.SubCategoryId, &pr.ImageURL, &pr.Price, &pr.PreviousPrice, &pr.Offered, &pr.AverageRating, &pr.ReviewCount, &pr.Currency); err != nil {
 			return nil, err
 		}
-		products = append(products, p)
+		products = append(products, pr)
 	}
+	return products, nil
+}
 
-	return &models.Products{Data: products}, nil
-}
\ No newline at end of file
+// Sets the minimum trigram word similarity for a fuzzy match with the <% operator,
+// for the rest of the given transaction.
+func (pdb *ProductsTB) SetWordSimilarityThresholdTx(ctx context.Context, tx *sqldb.Tx, threshold float64) error {
+	_, err := tx.Exec(ctx, SQL_SET_WORD_SIMILARITY_THRESHOLD, strconv.FormatFloat(threshold, 'f', -1, 64))
+	return err
+}
+
+// Retrieves offered products whose name starts with, or closely resembles, the given prefix,
+// as part of the given transaction. likePattern is the ILIKE pattern for prefix matches; fuzzy
+// matches use the word similarity threshold set by SetWordSimilarityThresholdTx.
+func (pdb *ProductsTB) SuggestTx(ctx context.Context, tx *sqldb.Tx, prefix string, likePattern string, limit int) ([]*models.SearchSuggestion, error) {
+	rows, err := tx.Query(ctx, SQL_SUGGEST_PRODUCTS, prefix, likePattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []*models.SearchSuggestion
+	for rows.Next() {
+		s := &models.SearchSuggestion{}
+		if err := rows.Scan(&s.ID, &s.Name, &s.CategoryId, &s.SubCategoryId); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, nil
+}
+
+// Locks a product until the given transaction ends.
+func (pdb *ProductsTB) LockTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	return tx.QueryRow(ctx, SQL_LOCK_PRODUCT, id).Scan(&id)
+}
+
+// Recomputes the average rating and review count of a product from its reviews as part of the given transaction.
+func (pdb *ProductsTB) RefreshRatingTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_REFRESH_PRODUCT_RATING, id)
+	return err
+}
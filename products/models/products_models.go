@@ -1,6 +1,15 @@
 
 package products
 
+import "time"
+
+// Scheduled price statuses.
+const (
+	ScheduledPriceStatusPending   = "pending"   // waiting for its effective time
+	ScheduledPriceStatusApplied   = "applied"   // the price has been applied to the product
+	ScheduledPriceStatusCancelled = "cancelled" // cancelled before it was applied
+)
+
 // Product represents a product in the system.
 type Product struct {
 	ID             int    `json:"id"`             // unique identifier
@@ -12,6 +21,10 @@ type Product struct {
 	Price          int    `json:"price"`          // price of the product in cents
 	PreviousPrice  int    `json:"previousPrice"`  // previous price of the product in cents
 	Offered        bool   `json:"offered"`        // whether the product is offered
+	AverageRating  float64 `json:"averageRating"` // average review rating, from 1 to 5; 0 if not yet reviewed
+	ReviewCount    int    `json:"reviewCount"`    // number of reviews
+	Currency       string `json:"currency"`       // ISO 4217 currency code of the prices
+	Display        *ConvertedPrice `json:"display,omitempty"` // prices in the requested currency; only set when a currency is requested
 }
 
 // Products represents a collection of products.
@@ -29,8 +42,211 @@ type ProductRequestParams struct {
 	Price          int    `json:"price"`
 	PreviousPrice  int    `json:"previousPrice"`
 	Offered        bool   `json:"offered"`
+	Currency       string `json:"currency"`       // ISO 4217 currency code of the prices; defaults to USD
+}
+
+// ProductListParams represents the query parameters for listing products page by page.
+type ProductListParams struct {
+	Cursor        string `query:"cursor"`      // opaque cursor returned as nextCursor by the previous page
+	Limit         int    `query:"limit"`       // maximum number of products to return (default 20, max 100)
+	Sort          string `query:"sort"`        // sort field: "id" (default), "price" or "name"
+	Order         string `query:"order"`       // sort order: "asc" (default) or "desc"
+	CategoryId    int    `query:"category"`    // only products in this category
+	SubCategoryId int    `query:"subCategory"` // only products in this sub-category
+	MinPrice      int    `query:"minPrice"`    // only products priced at or above this amount in cents
+	MaxPrice      int    `query:"maxPrice"`    // only products priced at or below this amount in cents
+	Offered       string `query:"offered"`     // "true" or "false" to filter by whether the product is offered
+	Currency      string `query:"currency"`    // currency to display prices in; price filters apply to the product's own currency
+}
+
+// ProductCursor identifies the last product of a page, used to fetch the next page.
+type ProductCursor struct {
+	Sort  string `json:"s"` // sort field the cursor was created for
+	ID    int    `json:"i"` // ID of the last product
+	Price int    `json:"p"` // price of the last product
+	Name  string `json:"n"` // name of the last product
+}
+
+// ProductPage represents a page of products.
+type ProductPage struct {
+	Data       []*Product `json:"data"`
+	NextCursor string     `json:"nextCursor"` // cursor for the next page; empty when there are no more products
+}
+
+// ProductSearchParams represents the query parameters for a product search.
+type ProductSearchParams struct {
+	Limit         int `query:"limit"`       // maximum number of results to return (default 20, max 100)
+	Offset        int `query:"offset"`      // number of results to skip
+	CategoryId    int `query:"category"`    // only products in this category
+	SubCategoryId int `query:"subCategory"` // only products in this sub-category
+	Currency      string `query:"currency"` // currency to display prices in
+}
+
+// ProductSearchResult represents a product matching a search query.
+type ProductSearchResult struct {
+	Product       *Product `json:"product"`
+	Rank          float64  `json:"rank"`          // relevance of the product to the query
+	NameHighlight string   `json:"nameHighlight"` // HTML-escaped product name with matching terms wrapped in <mark> tags
+	Snippet       string   `json:"snippet"`       // HTML-escaped description fragments with matching terms wrapped in <mark> tags
+}
+
+// ProductSearchResults represents a page of product search results, most relevant first.
+type ProductSearchResults struct {
+	Data       []*ProductSearchResult `json:"data"`
+	NextOffset int                    `json:"nextOffset"` // offset of the next page; 0 when there are no more results
+}
+
+// SearchSuggestionParams represents the query parameters for search suggestions.
+type SearchSuggestionParams struct {
+	Limit int `query:"limit"` // maximum number of suggestions per group (default 5, max 20)
+}
+
+// SearchSuggestion represents a product, category or sub-category suggested for a search prefix.
+type SearchSuggestion struct {
+	ID            int    `json:"id"`                    // identifier of the suggested entity
+	Name          string `json:"name"`                  // name of the suggested entity
+	CategoryId    int    `json:"category,omitempty"`    // category of the suggested product or sub-category
+	SubCategoryId int    `json:"subCategory,omitempty"` // sub-category of the suggested product
+}
+
+// SearchSuggestions represents search suggestions grouped by type, best match first.
+type SearchSuggestions struct {
+	Products      []*SearchSuggestion `json:"products"`
+	Categories    []*SearchSuggestion `json:"categories"`
+	SubCategories []*SearchSuggestion `json:"subCategories"`
+}
+
+// ProductStock represents the available stock of a product.
+type ProductStock struct {
+	ProductID int `json:"productId"` // product identifier
+	Stock     int `json:"stock"`     // number of units available for sale
+}
+
+// ProductStocks represents a collection of product stock levels.
+type ProductStocks struct {
+	Data []*ProductStock `json:"data"`
+}
+
+// StockImportParams represents the parameters required to import the stock of products.
+type StockImportParams struct {
+	Data []*ProductStock `json:"data"` // absolute stock level of each product
+}
+
+// StockAdjustmentParams represents the parameters required to adjust the stock of a product.
+type StockAdjustmentParams struct {
+	Delta int `json:"delta"` // number of units to add (positive) or remove (negative)
+}
+
+// ProductPriceChange represents a recorded change of a product's price.
+type ProductPriceChange struct {
+	ID            int       `json:"id"`            // unique identifier
+	ProductID     int       `json:"productId"`     // product whose price changed
+	Price         int       `json:"price"`         // new price in cents
+	PreviousPrice int       `json:"previousPrice"` // previous price in cents
+	Actor         string    `json:"actor"`         // ID of the caller who made the change, or "system"
+	ChangedAt     time.Time `json:"changedAt"`     // when the price changed
+}
+
+// ProductPriceHistory represents the price changes of a product, oldest first.
+type ProductPriceHistory struct {
+	Data []*ProductPriceChange `json:"data"`
 }
 
+// ScheduledPrice represents a price that takes effect at a future time.
+type ScheduledPrice struct {
+	ID          int        `json:"id"`          // unique identifier
+	ProductID   int        `json:"productId"`   // product the price applies to
+	Price       int        `json:"price"`       // new price in cents
+	EffectiveAt time.Time  `json:"effectiveAt"` // when the price takes effect
+	Status      string     `json:"status"`      // pending, applied or cancelled
+	Actor       string     `json:"actor"`       // ID of the caller who scheduled the price
+	CreatedAt   time.Time  `json:"createdAt"`   // when the price was scheduled
+	AppliedAt   *time.Time `json:"appliedAt"`   // when the price was applied, if it has been
+}
+
+// ScheduledPrices represents a collection of scheduled prices.
+type ScheduledPrices struct {
+	Data []*ScheduledPrice `json:"data"`
+}
+
+// ScheduledPriceRequestParams represents the parameters required to schedule a price.
+type ScheduledPriceRequestParams struct {
+	Price       int       `json:"price"`       // new price in cents
+	EffectiveAt time.Time `json:"effectiveAt"` // when the price takes effect; must be in the future
+}
+
+// ProductCurrencyParams represents the query parameters for displaying a product in a currency.
+type ProductCurrencyParams struct {
+	Currency string `query:"currency"` // currency to display prices in
+}
+
+// ConvertedPrice represents the prices of a product in a requested currency.
+type ConvertedPrice struct {
+	Currency      string  `json:"currency"`      // ISO 4217 currency code of the prices
+	Price         int     `json:"price"`         // price in minor units of the currency
+	PreviousPrice int     `json:"previousPrice"` // previous price in minor units of the currency; 0 if there is no exchange rate
+	Rate          float64 `json:"rate"`          // exchange rate from the product's currency; 1 for the product's own currency, 0 if there is none
+	Override      bool    `json:"override"`      // whether the price is set explicitly for the currency instead of converted
+}
+
+// ExchangeRate represents the rate at which amounts in one currency convert into another.
+type ExchangeRate struct {
+	BaseCurrency  string    `json:"baseCurrency"`  // currency converted from
+	QuoteCurrency string    `json:"quoteCurrency"` // currency converted to
+	Rate          float64   `json:"rate"`          // units of the quote currency per unit of the base currency
+	UpdatedBy     string    `json:"updatedBy"`     // ID of the caller who last set the rate
+	UpdatedAt     time.Time `json:"updatedAt"`     // when the rate was last set
+}
+
+// ExchangeRates represents a collection of exchange rates.
+type ExchangeRates struct {
+	Data []*ExchangeRate `json:"data"`
+}
+
+// ExchangeRateRequestParams represents the parameters required to set an exchange rate.
+type ExchangeRateRequestParams struct {
+	Rate float64 `json:"rate"` // units of the quote currency per unit of the base currency
+}
+
+// PriceOverride represents a price set explicitly for a product in a currency.
+type PriceOverride struct {
+	ProductID int       `json:"productId"` // product the price applies to
+	Currency  string    `json:"currency"`  // ISO 4217 currency code of the price
+	Price     int       `json:"price"`     // price in minor units of the currency
+	UpdatedAt time.Time `json:"updatedAt"` // when the price was last set
+}
+
+// PriceOverrides represents a collection of price overrides.
+type PriceOverrides struct {
+	Data []*PriceOverride `json:"data"`
+}
+
+// PriceOverrideRequestParams represents the parameters required to set a price override.
+type PriceOverrideRequestParams struct {
+	Price int `json:"price"` // price in minor units of the currency
+}
+
+// ProductTaxonomy represents the existing categories and sub-categories products can be assigned to.
+type ProductTaxonomy struct {
+	CategoryIds   []int             `json:"categoryIds"`   // IDs of all categories
+	SubCategories []*SubCategoryRef `json:"subCategories"` // all sub-categories with their category
+}
+
+// SubCategoryRef identifies a sub-category and the category it belongs to.
+type SubCategoryRef struct {
+	ID         int `json:"id"`       // sub-category identifier
+	CategoryId int `json:"category"` // category the sub-category belongs to
+}
+
+// FieldViolation is returned as error details when a request field is invalid.
+type FieldViolation struct {
+	Field       string `json:"field"`       // JSON name of the offending field
+	Description string `json:"description"` // why the field is invalid
+}
+
+// ErrDetails marks FieldViolation as Encore error details.
+func (FieldViolation) ErrDetails() {}
+
 // ErrNameRequired is the error message for when the product name is missing.
 const ErrNameRequired = "product name is required"
 
@@ -41,4 +257,18 @@ const ErrCategoryInvalid = "invalid product category"
 const ErrImageURLRequired = "product image URL is required"
 
 // ErrPriceInvalid is the error message for when the product price is invalid.
-const ErrPriceInvalid = "product price must be greater than 0"
\ No newline at end of file
+const ErrPriceInvalid = "product price must be greater than 0"
+// Product change actions carried by product-changed events.
+const (
+	ProductCreated      = "created"       // the product was added to the catalog
+	ProductUpdated      = "updated"       // the product details were updated
+	ProductDeleted      = "deleted"       // the product was removed from the catalog
+	ProductPriceApplied = "price_applied" // a scheduled price took effect
+)
+
+// ProductChangedEvent is published on the product-changed topic after a product changes.
+type ProductChangedEvent struct {
+	Action     string    `json:"action"`      // kind of change, one of the product change actions
+	Product    *Product  `json:"product"`     // the product after the change, or as it was before deletion
+	OccurredAt time.Time `json:"occurredAt"`  // when the change was made
+}
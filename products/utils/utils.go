@@ -1,15 +1,48 @@
 package products
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	models "encore.app/products/models"
+	"encore.dev/beta/errs"
 )
 
+// Default and maximum number of products returned per page.
+const (
+	DefaultProductPageSize = 20
+	MaxProductPageSize     = 100
+)
+
+// Search suggestion settings.
+const (
+	DefaultSuggestionLimit = 5   // default number of suggestions per group
+	MaxSuggestionLimit     = 20  // maximum number of suggestions per group
+	MinSuggestionPrefix    = 2   // minimum prefix length for suggestions
+	SuggestionSimilarity   = 0.3 // minimum trigram word similarity for a fuzzy match
+)
+
+// InvalidField returns an InvalidArgument error naming the offending request field.
+func InvalidField(field string, description string) error {
+	return &errs.Error{
+		Code:    errs.InvalidArgument,
+		Message: description,
+		Details: models.FieldViolation{Field: field, Description: description},
+	}
+}
+
 func ValidateProductCategory(categoryId int) error {
 	// validate the product category
-	if categoryId < 0 || categoryId > 5 {
-		return errors.New(models.ErrCategoryInvalid)
+	if categoryId <= 0 {
+		return InvalidField("category", models.ErrCategoryInvalid)
 	}
 
 	return nil
@@ -18,24 +51,253 @@ func ValidateProductCategory(categoryId int) error {
 func ValidateProductRequestParams(p *models.ProductRequestParams) error {
 	// validate the product request parameters
 	if p.Name == "" {
-		return errors.New("product name is required")
+		return InvalidField("name", models.ErrNameRequired)
 	}
 	if p.Description == "" {
-		return errors.New("product description is required")
+		return InvalidField("description", "product description is required")
 	}
-	if p.CategoryId < 0 || p.CategoryId > 3 {
-		return errors.New("invalid product category; should be between 0 and 3")
+	if err := ValidateProductCategory(p.CategoryId); err != nil {
+		return err
 	}
-	if p.SubCategoryId < 0 || p.SubCategoryId > 10 {
-		return errors.New("invalid product sub-category; should be between 0 and 10")
+	if p.SubCategoryId <= 0 {
+		return InvalidField("subCategory", "invalid product sub-category")
 	}
 	if p.ImageURL == "" {
-		return errors.New("product image URL is required")
+		return InvalidField("imageUrl", models.ErrImageURLRequired)
+	}
+	if p.Price <= 0 {
+		return InvalidField("price", models.ErrPriceInvalid)
+	}
+	// products without a currency are priced in the default currency
+	if p.Currency == "" {
+		p.Currency = coremodels.DefaultCurrency
+	}
+	if err := coreutils.ValidateCurrency(p.Currency); err != nil {
+		return InvalidField("currency", err.Error())
+	}
+
+	return nil
+}
+
+// ValidateProductTaxonomy checks the category and sub-category exist in the taxonomy
+// and that the sub-category belongs to the category.
+func ValidateProductTaxonomy(t *models.ProductTaxonomy, categoryId int, subCategoryId int) error {
+	categoryFound := false
+	for _, id := range t.CategoryIds {
+		if id == categoryId {
+			categoryFound = true
+			break
+		}
+	}
+	if !categoryFound {
+		return InvalidField("category", fmt.Sprintf("category %d does not exist", categoryId))
+	}
+	for _, sc := range t.SubCategories {
+		if sc.ID == subCategoryId {
+			if sc.CategoryId != categoryId {
+				return InvalidField("subCategory", fmt.Sprintf("sub-category %d does not belong to category %d", subCategoryId, categoryId))
+			}
+			return nil
+		}
+	}
+	return InvalidField("subCategory", fmt.Sprintf("sub-category %d does not exist", subCategoryId))
+}
+
+// ApplyProductListDefaults fills in default values for unset product list parameters.
+func ApplyProductListDefaults(p *models.ProductListParams) {
+	if p.Limit == 0 {
+		p.Limit = DefaultProductPageSize
+	}
+	if p.Sort == "" {
+		p.Sort = "id"
+	}
+	if p.Order == "" {
+		p.Order = "asc"
+	}
+}
+
+func ValidateProductListParams(p *models.ProductListParams) error {
+	// validate the product list parameters
+	if p.Limit < 1 || p.Limit > MaxProductPageSize {
+		return errors.New("invalid limit; should be between 1 and 100")
+	}
+	if p.Sort != "id" && p.Sort != "price" && p.Sort != "name" {
+		return errors.New("invalid sort; should be one of id, price or name")
+	}
+	if p.Order != "asc" && p.Order != "desc" {
+		return errors.New("invalid order; should be asc or desc")
+	}
+	if p.CategoryId < 0 || p.SubCategoryId < 0 {
+		return errors.New("invalid category filter")
+	}
+	if p.MinPrice < 0 || p.MaxPrice < 0 || (p.MaxPrice > 0 && p.MinPrice > p.MaxPrice) {
+		return errors.New("invalid price range")
+	}
+	if p.Offered != "" && p.Offered != "true" && p.Offered != "false" {
+		return errors.New("invalid offered filter; should be true or false")
+	}
+	return nil
+}
+
+// EncodeProductCursor encodes a product cursor into an opaque string.
+func EncodeProductCursor(c *models.ProductCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeProductCursor decodes an opaque cursor string created for the given sort field.
+func DecodeProductCursor(s string, sort string) (*models.ProductCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	c := &models.ProductCursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	if c.Sort != sort {
+		return nil, errors.New("cursor does not match sort")
+	}
+	return c, nil
+}
+
+// ProductListCacheKey returns the cache key for a validated product list query.
+func ProductListCacheKey(p *models.ProductListParams) string {
+	return fmt.Sprintf("sort=%s:%s&category=%d&subCategory=%d&minPrice=%d&maxPrice=%d&offered=%s&limit=%d&cursor=%s",
+		p.Sort, p.Order, p.CategoryId, p.SubCategoryId, p.MinPrice, p.MaxPrice, p.Offered, p.Limit, p.Cursor)
+}
+
+// NormalizeSearchQuery lower-cases the query and collapses surrounding and repeated whitespace.
+func NormalizeSearchQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// ApplyProductSearchDefaults fills in default values for unset product search parameters.
+func ApplyProductSearchDefaults(p *models.ProductSearchParams) {
+	if p.Limit == 0 {
+		p.Limit = DefaultProductPageSize
+	}
+}
+
+func ValidateProductSearchParams(query string, p *models.ProductSearchParams) error {
+	// validate the product search parameters
+	if query == "" {
+		return errors.New("search query is required")
+	}
+	if p.Limit < 1 || p.Limit > MaxProductPageSize {
+		return errors.New("invalid limit; should be between 1 and 100")
+	}
+	if p.Offset < 0 {
+		return errors.New("invalid offset")
+	}
+	if p.CategoryId < 0 || p.SubCategoryId < 0 {
+		return errors.New("invalid category filter")
+	}
+	return nil
+}
+
+// Markers ts_headline wraps matching terms in, replaced by <mark> tags once the text is escaped.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// highlightReplacer replaces the highlight markers with <mark> tags.
+var highlightReplacer = strings.NewReplacer(HighlightStart, "<mark>", HighlightStop, "</mark>")
+
+// HighlightHTML HTML-escapes text highlighted by ts_headline with the highlight markers,
+// then wraps the highlighted terms in <mark> tags.
+func HighlightHTML(text string) string {
+	return highlightReplacer.Replace(html.EscapeString(text))
+}
+
+// ProductSearchCacheKey returns the cache key for a normalized search query and validated parameters.
+func ProductSearchCacheKey(query string, p *models.ProductSearchParams) string {
+	return fmt.Sprintf("q=%s&category=%d&subCategory=%d&limit=%d&offset=%d", query, p.CategoryId, p.SubCategoryId, p.Limit, p.Offset)
+}
+
+// ApplySearchSuggestionDefaults fills in default values for unset search suggestion parameters.
+func ApplySearchSuggestionDefaults(p *models.SearchSuggestionParams) {
+	if p.Limit == 0 {
+		p.Limit = DefaultSuggestionLimit
 	}
+}
+
+func ValidateSearchSuggestionParams(prefix string, p *models.SearchSuggestionParams) error {
+	// validate the search suggestion parameters
+	if len([]rune(prefix)) < MinSuggestionPrefix {
+		return errors.New("search prefix must be at least 2 characters")
+	}
+	if p.Limit < 1 || p.Limit > MaxSuggestionLimit {
+		return errors.New("invalid limit; should be between 1 and 20")
+	}
+	return nil
+}
+
+// PrefixLikePattern returns an ILIKE pattern matching values that start with prefix,
+// escaping any LIKE wildcards in the prefix.
+func PrefixLikePattern(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return r.Replace(prefix) + "%"
+}
+func ValidateScheduledPriceRequestParams(p *models.ScheduledPriceRequestParams, now time.Time) error {
+	// validate the scheduled price request parameters
 	if p.Price <= 0 {
-		return errors.New("product price must be greater than 0")
+		return InvalidField("price", models.ErrPriceInvalid)
 	}
+	if !p.EffectiveAt.After(now) {
+		return InvalidField("effectiveAt", "effective time must be in the future")
+	}
+	return nil
+}
 
+// ValidateExchangeRate checks that an exchange rate converts between two different, valid currencies.
+func ValidateExchangeRate(base string, quote string, p *models.ExchangeRateRequestParams) error {
+	if err := coreutils.ValidateCurrency(base); err != nil {
+		return InvalidField("baseCurrency", err.Error())
+	}
+	if err := coreutils.ValidateCurrency(quote); err != nil {
+		return InvalidField("quoteCurrency", err.Error())
+	}
+	if base == quote {
+		return InvalidField("quoteCurrency", "base and quote currency must differ")
+	}
+	if !(p.Rate > 0) || math.IsInf(p.Rate, 0) {
+		return InvalidField("rate", "rate must be greater than 0")
+	}
 	return nil
 }
 
+// ConvertProductPrice returns the prices of the product in the given currency, using the price
+// override for the currency if there is one and otherwise converting at the given rate from the
+// product's currency. Without a rate, the previous price is not converted and the rate is 0; a
+// rate is required for any other currency than the product's own that has no override.
+func ConvertProductPrice(p *models.Product, currency string, override *int, rate *float64) (*models.ConvertedPrice, error) {
+	if currency == "" || currency == p.Currency {
+		return &models.ConvertedPrice{Currency: p.Currency, Price: p.Price, PreviousPrice: p.PreviousPrice, Rate: 1}, nil
+	}
+	if rate == nil {
+		if override == nil {
+			return nil, MissingExchangeRate(p.Currency, currency)
+		}
+		return &models.ConvertedPrice{Currency: currency, Price: *override, Override: true}, nil
+	}
+	c := &models.ConvertedPrice{
+		Currency:      currency,
+		Price:         coreutils.ConvertAmount(p.Price, p.Currency, currency, *rate),
+		PreviousPrice: coreutils.ConvertAmount(p.PreviousPrice, p.Currency, currency, *rate),
+		Rate:          *rate,
+	}
+	if override != nil {
+		c.Price, c.Override = *override, true
+	}
+	return c, nil
+}
+
+// MissingExchangeRate returns the error reported when there is no exchange rate between two currencies.
+func MissingExchangeRate(base string, quote string) error {
+	return &errs.Error{
+		Code:    errs.FailedPrecondition,
+		Message: fmt.Sprintf("no exchange rate from %s to %s", base, quote),
+	}
+}
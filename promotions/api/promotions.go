@@ -0,0 +1,131 @@
+package promotions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/promotions/db"
+	models "encore.app/promotions/models"
+	utils "encore.app/promotions/utils"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// PromotionsTable instance.
+var PromotionsTable = &db.PromotionsTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints to manage promotions (catalog-admin only):
+
+- GET: /promotions/get/:id
+- GET: /promotions/all
+- POST: /promotions/add
+- PUT: /promotions/update/:id
+
+Promotions are not cached, since their usage count changes with every order.
+Coupons are redeemed through the cart price and order endpoints.
+*/
+
+// GET: /promotions/get/:id
+// Retrieves the promotion with the given ID.
+//encore:api auth method=GET path=/promotions/get/:id
+func GetPromotion(ctx context.Context, id int) (*models.Promotion, error) {
+	// Confirm the caller may manage promotions.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	r, err := PromotionsTable.Get(ctx, id)
+	if err != nil {
+		return nil, promotionError(err, id)
+	}
+	return r, nil
+}
+
+// GET: /promotions/all
+// Retrieves all promotions.
+//encore:api auth method=GET path=/promotions/all
+func GetPromotions(ctx context.Context) (*models.Promotions, error) {
+	// Confirm the caller may manage promotions.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	return PromotionsTable.GetAll(ctx)
+}
+
+// POST: /promotions/add
+// Creates a promotion.
+//encore:api auth method=POST path=/promotions/add
+func AddPromotion(ctx context.Context, p *models.PromotionRequestParams) (*models.Promotion, error) {
+	// Confirm the caller may manage promotions.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the promotion data.
+	p.Code = utils.NormalizeCouponCode(p.Code)
+	if err := utils.ValidatePromotionRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Insert the promotion into the database.
+	r, err := PromotionsTable.Insert(ctx, p)
+	if err != nil {
+		return nil, promotionError(err, 0)
+	}
+	return r, nil
+}
+
+// PUT: /promotions/update/:id
+// Updates the promotion with the given ID. Set active to false to stop a promotion.
+//encore:api auth method=PUT path=/promotions/update/:id
+func UpdatePromotion(ctx context.Context, id int, p *models.PromotionRequestParams) (*models.Promotion, error) {
+	// Confirm the caller may manage promotions.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the promotion data.
+	p.Code = utils.NormalizeCouponCode(p.Code)
+	if err := utils.ValidatePromotionRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Update the promotion in the database.
+	r, err := PromotionsTable.Update(ctx, id, p)
+	if err != nil {
+		return nil, promotionError(err, id)
+	}
+	return r, nil
+}
+
+// promotionError converts database errors for the promotion with the given ID into API errors.
+func promotionError(err error, id int) error {
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("promotion %d not found", id),
+		}
+	}
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return &errs.Error{
+			Code:    errs.AlreadyExists,
+			Message: "a promotion with this code already exists",
+		}
+	}
+	return err
+}
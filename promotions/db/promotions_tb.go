@@ -0,0 +1,160 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "encore.app/promotions/models"
+	"encore.dev/storage/sqldb"
+)
+
+type PromotionsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_PROMOTION_COLUMNS = `
+				id, code, description, kind, percent_off, amount_off, buy_quantity, get_quantity, scope, scope_id,
+				starts_at, ends_at, usage_limit, usage_count, active, created_at
+		`
+		SQL_GET_PROMOTION = `
+				SELECT` + SQL_PROMOTION_COLUMNS + `FROM promotions
+				WHERE id = $1
+		`
+		SQL_GET_PROMOTION_BY_CODE = `
+				SELECT` + SQL_PROMOTION_COLUMNS + `FROM promotions
+				WHERE code = $1
+		`
+		SQL_GET_ALL_PROMOTIONS = `
+				SELECT` + SQL_PROMOTION_COLUMNS + `FROM promotions
+				ORDER BY id
+		`
+		SQL_INSERT_PROMOTION = `
+				INSERT INTO promotions (code, description, kind, percent_off, amount_off, buy_quantity, get_quantity, scope, scope_id, starts_at, ends_at, usage_limit, active)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+				RETURNING id, created_at
+		`
+		SQL_UPDATE_PROMOTION = `
+				UPDATE promotions
+				SET code = $1, description = $2, kind = $3, percent_off = $4, amount_off = $5, buy_quantity = $6, get_quantity = $7,
+					scope = $8, scope_id = $9, starts_at = $10, ends_at = $11, usage_limit = $12, active = $13
+				WHERE id = $14
+				RETURNING usage_count, created_at
+		`
+		SQL_CLAIM_PROMOTION_USAGE = `
+				UPDATE promotions SET usage_count = usage_count + 1
+				WHERE id = $1 AND (usage_limit = 0 OR usage_count < usage_limit)
+				RETURNING usage_count
+		`
+)
+
+// scanner is implemented by *sqldb.Row and *sqldb.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPromotion scans a row of SQL_PROMOTION_COLUMNS into a promotion.
+func scanPromotion(row scanner) (*models.Promotion, error) {
+	p := &models.Promotion{}
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&p.ID, &p.Code, &p.Description, &p.Kind, &p.PercentOff, &p.AmountOff, &p.BuyQuantity, &p.GetQuantity, &p.Scope, &p.ScopeID,
+		&startsAt, &endsAt, &p.UsageLimit, &p.UsageCount, &p.Active, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.StartsAt, p.EndsAt = nullTime(startsAt), nullTime(endsAt)
+	return p, nil
+}
+
+// Retrieves a promotion from the database.
+func (tb *PromotionsTable) Get(ctx context.Context, id int) (*models.Promotion, error) {
+	return scanPromotion(tb.DB.QueryRow(ctx, SQL_GET_PROMOTION, id))
+}
+
+// Retrieves a promotion as part of the given transaction.
+func (tb *PromotionsTable) GetTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.Promotion, error) {
+	return scanPromotion(tx.QueryRow(ctx, SQL_GET_PROMOTION, id))
+}
+
+// Retrieves a promotion from the database by coupon code.
+func (tb *PromotionsTable) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return scanPromotion(tb.DB.QueryRow(ctx, SQL_GET_PROMOTION_BY_CODE, code))
+}
+
+// Retrieves a promotion by coupon code as part of the given transaction.
+func (tb *PromotionsTable) GetByCodeTx(ctx context.Context, tx *sqldb.Tx, code string) (*models.Promotion, error) {
+	return scanPromotion(tx.QueryRow(ctx, SQL_GET_PROMOTION_BY_CODE, code))
+}
+
+// Retrieves all promotions from the database.
+func (tb *PromotionsTable) GetAll(ctx context.Context) (*models.Promotions, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_PROMOTIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := &models.Promotions{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions.Data = append(promotions.Data, p)
+	}
+	return promotions, nil
+}
+
+// Inserts a promotion into the database.
+func (tb *PromotionsTable) Insert(ctx context.Context, p *models.PromotionRequestParams) (*models.Promotion, error) {
+	r := promotionFromParams(p)
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_PROMOTION, p.Code, p.Description, p.Kind, p.PercentOff, p.AmountOff, p.BuyQuantity, p.GetQuantity,
+		p.Scope, p.ScopeID, toNullTime(p.StartsAt), toNullTime(p.EndsAt), p.UsageLimit, p.Active).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Updates a promotion in the database.
+func (tb *PromotionsTable) Update(ctx context.Context, id int, p *models.PromotionRequestParams) (*models.Promotion, error) {
+	r := promotionFromParams(p)
+	r.ID = id
+	err := tb.DB.QueryRow(ctx, SQL_UPDATE_PROMOTION, p.Code, p.Description, p.Kind, p.PercentOff, p.AmountOff, p.BuyQuantity, p.GetQuantity,
+		p.Scope, p.ScopeID, toNullTime(p.StartsAt), toNullTime(p.EndsAt), p.UsageLimit, p.Active, id).Scan(&r.UsageCount, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Records one more use of the promotion as part of the given transaction.
+// Returns sqldb.ErrNoRows if the usage limit has been reached.
+func (tb *PromotionsTable) ClaimUsageTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	var count int
+	return tx.QueryRow(ctx, SQL_CLAIM_PROMOTION_USAGE, id).Scan(&count)
+}
+
+// promotionFromParams creates a promotion from the request parameters.
+func promotionFromParams(p *models.PromotionRequestParams) *models.Promotion {
+	return &models.Promotion{Code: p.Code, Description: p.Description, Kind: p.Kind, PercentOff: p.PercentOff, AmountOff: p.AmountOff,
+		BuyQuantity: p.BuyQuantity, GetQuantity: p.GetQuantity, Scope: p.Scope, ScopeID: p.ScopeID, StartsAt: p.StartsAt, EndsAt: p.EndsAt,
+		UsageLimit: p.UsageLimit, Active: p.Active}
+}
+
+// nullTime converts a nullable database time into a time pointer.
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// toNullTime converts a time pointer into a nullable database time.
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
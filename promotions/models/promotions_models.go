@@ -0,0 +1,100 @@
+package promotions
+
+import "time"
+
+// Promotion kinds.
+const (
+	PromotionKindPercentage = "percentage"  // PercentOff percent off eligible items
+	PromotionKindFixed      = "fixed"       // AmountOff off the eligible items, once per order
+	PromotionKindBuyXGetY   = "buy_x_get_y" // for every BuyQuantity units of an eligible product bought, GetQuantity more are free
+)
+
+// Promotion scopes, i.e. which items a promotion applies to.
+const (
+	PromotionScopeAll         = "all"          // every item
+	PromotionScopeCategory    = "category"     // items in the category ScopeID
+	PromotionScopeSubCategory = "sub_category" // items in the sub-category ScopeID
+	PromotionScopeProduct     = "product"      // items of the product ScopeID
+)
+
+// Promotion represents a discount rule redeemed with a coupon code.
+type Promotion struct {
+	ID          int        `json:"id"`          // unique identifier
+	Code        string     `json:"code"`        // coupon code, upper-case
+	Description string     `json:"description"` // description shown to shoppers
+	Kind        string     `json:"kind"`        // percentage, fixed or buy_x_get_y
+	PercentOff  int        `json:"percentOff"`  // percent off, for percentage promotions
+	AmountOff   int        `json:"amountOff"`   // amount off in minor units of the products' currency, for fixed promotions
+	BuyQuantity int        `json:"buyQuantity"` // units to buy, for buy_x_get_y promotions
+	GetQuantity int        `json:"getQuantity"` // free units, for buy_x_get_y promotions
+	Scope       string     `json:"scope"`       // all, category, sub_category or product
+	ScopeID     int        `json:"scopeId"`     // ID of the category, sub-category or product the promotion is scoped to
+	StartsAt    *time.Time `json:"startsAt"`    // time the promotion starts; immediately if nil
+	EndsAt      *time.Time `json:"endsAt"`      // time the promotion ends; never if nil
+	UsageLimit  int        `json:"usageLimit"`  // maximum number of orders; unlimited if 0
+	UsageCount  int        `json:"usageCount"`  // number of orders the promotion was applied to
+	Active      bool       `json:"active"`      // whether the promotion can be redeemed
+	CreatedAt   time.Time  `json:"createdAt"`   // time the promotion was created
+}
+
+// Promotions represents a collection of promotions.
+type Promotions struct {
+	Data []*Promotion `json:"data"`
+}
+
+// PromotionRequestParams represents the request parameters for creating or updating a promotion.
+type PromotionRequestParams struct {
+	Code        string     `json:"code"`
+	Description string     `json:"description"`
+	Kind        string     `json:"kind"`
+	PercentOff  int        `json:"percentOff"`
+	AmountOff   int        `json:"amountOff"`
+	BuyQuantity int        `json:"buyQuantity"`
+	GetQuantity int        `json:"getQuantity"`
+	Scope       string     `json:"scope"`
+	ScopeID     int        `json:"scopeId"`
+	StartsAt    *time.Time `json:"startsAt"`
+	EndsAt      *time.Time `json:"endsAt"`
+	UsageLimit  int        `json:"usageLimit"`
+	Active      bool       `json:"active"`
+}
+
+// PricedLine represents an item to be priced, with the product details promotions are scoped by.
+type PricedLine struct {
+	ProductID     int
+	CategoryId    int
+	SubCategoryId int
+	Quantity      int
+	UnitPrice     int     // price of one unit in minor units of Currency
+	Currency      string  // ISO 4217 currency code of the price
+	BaseCurrency  string  // ISO 4217 currency code the product is priced in
+	Rate          float64 // exchange rate from BaseCurrency into Currency; 0 if UnitPrice is a price override without a rate
+}
+
+// PriceBreakdownLine represents the price of a single item.
+type PriceBreakdownLine struct {
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
+	UnitPrice int `json:"unitPrice"` // price of one unit in cents
+	Subtotal  int `json:"subtotal"`  // price of all units before discount, in cents
+	Discount  int `json:"discount"`  // discount applied to the item, in cents
+	Total     int `json:"total"`     // price of all units after discount, in cents
+}
+
+// PriceBreakdown represents the price of a cart or order, before and after discount.
+type PriceBreakdown struct {
+	Lines       []*PriceBreakdownLine `json:"lines"`
+	Subtotal    int                   `json:"subtotal"`    // price before discount, in cents
+	Discount    int                   `json:"discount"`    // total discount, in cents
+	Total       int                   `json:"total"`       // price after discount, in cents
+	Currency    string                `json:"currency"`    // ISO 4217 currency code of all amounts
+	BaseCurrency string               `json:"baseCurrency"` // ISO 4217 currency code the products are priced in
+	ExchangeRate float64              `json:"exchangeRate"` // exchange rate used to convert prices from BaseCurrency into Currency; 0 if there is none
+	CouponCode  string                `json:"couponCode"`  // coupon code applied, if any
+	PromotionID int                   `json:"promotionId"` // ID of the promotion applied, if any
+}
+
+// Return type for mutations to promotions.
+type PromotionChangeRequestReturn struct {
+	ID int `json:"id"`
+}
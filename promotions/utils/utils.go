@@ -0,0 +1,170 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	coreutils "encore.app/core/utils"
+	models "encore.app/promotions/models"
+	"encore.dev/beta/errs"
+)
+
+// NormalizeCouponCode trims and upper-cases a coupon code.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidatePromotionRequestParams(p *models.PromotionRequestParams) error {
+	// validate the promotion request parameters
+	if p.Code == "" || strings.ContainsAny(p.Code, " \t\n") {
+		return errors.New("invalid code; should be non-empty and contain no spaces")
+	}
+	switch p.Kind {
+	case models.PromotionKindPercentage:
+		if p.PercentOff < 1 || p.PercentOff > 100 {
+			return errors.New("invalid percentOff; should be between 1 and 100")
+		}
+	case models.PromotionKindFixed:
+		if p.AmountOff <= 0 {
+			return errors.New("invalid amountOff; should be greater than 0")
+		}
+	case models.PromotionKindBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return errors.New("invalid buyQuantity or getQuantity; should be greater than 0")
+		}
+	default:
+		return errors.New("invalid kind; should be one of percentage, fixed or buy_x_get_y")
+	}
+	switch p.Scope {
+	case models.PromotionScopeAll:
+		if p.ScopeID != 0 {
+			return errors.New("scopeId must not be set for scope all")
+		}
+	case models.PromotionScopeCategory, models.PromotionScopeSubCategory, models.PromotionScopeProduct:
+		if p.ScopeID <= 0 {
+			return errors.New("scopeId is required")
+		}
+	default:
+		return errors.New("invalid scope; should be one of all, category, sub_category or product")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	if p.UsageLimit < 0 {
+		return errors.New("invalid usageLimit")
+	}
+	return nil
+}
+
+// ValidatePromotionUsable checks whether the promotion can be redeemed at the given time.
+func ValidatePromotionUsable(p *models.Promotion, now time.Time) error {
+	if !p.Active {
+		return errors.New("coupon is not active")
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return errors.New("coupon is not yet valid")
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return errors.New("coupon has expired")
+	}
+	if p.UsageLimit > 0 && p.UsageCount >= p.UsageLimit {
+		return errors.New("coupon usage limit reached")
+	}
+	return nil
+}
+
+// IsInEffect reports whether the promotion is active and within its validity window at the
+// given time, regardless of its usage.
+func IsInEffect(p *models.Promotion, now time.Time) bool {
+	return p.Active && (p.StartsAt == nil || !now.Before(*p.StartsAt)) && (p.EndsAt == nil || now.Before(*p.EndsAt))
+}
+
+// AppliesTo checks whether the promotion applies to the line.
+func AppliesTo(p *models.Promotion, l *models.PricedLine) bool {
+	switch p.Scope {
+	case models.PromotionScopeAll:
+		return true
+	case models.PromotionScopeCategory:
+		return l.CategoryId == p.ScopeID
+	case models.PromotionScopeSubCategory:
+		return l.SubCategoryId == p.ScopeID
+	case models.PromotionScopeProduct:
+		return l.ProductID == p.ScopeID
+	}
+	return false
+}
+
+// PriceLines prices the lines, applying the promotion if it is set.
+// All lines must be priced in the same currency, converted from the same base currency.
+func PriceLines(lines []*models.PricedLine, p *models.Promotion) *models.PriceBreakdown {
+	b := &models.PriceBreakdown{}
+	if len(lines) > 0 {
+		b.Currency, b.BaseCurrency, b.ExchangeRate = lines[0].Currency, lines[0].BaseCurrency, lines[0].Rate
+	}
+	// The fixed amount, set in the base currency, is spread over the eligible lines,
+	// in order, until used up.
+	remaining := 0
+	if p != nil {
+		b.CouponCode, b.PromotionID = p.Code, p.ID
+		if p.Kind == models.PromotionKindFixed && len(lines) > 0 {
+			remaining = coreutils.ConvertAmount(p.AmountOff, b.BaseCurrency, b.Currency, b.ExchangeRate)
+		}
+	}
+	for _, l := range lines {
+		bl := &models.PriceBreakdownLine{ProductID: l.ProductID, Quantity: l.Quantity, UnitPrice: l.UnitPrice, Subtotal: l.UnitPrice * l.Quantity}
+		if p != nil && AppliesTo(p, l) {
+			switch p.Kind {
+			case models.PromotionKindPercentage:
+				bl.Discount = bl.Subtotal * p.PercentOff / 100
+			case models.PromotionKindFixed:
+				bl.Discount = min(remaining, bl.Subtotal)
+				remaining -= bl.Discount
+			case models.PromotionKindBuyXGetY:
+				free := l.Quantity / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+				bl.Discount = free * l.UnitPrice
+			}
+		}
+		bl.Total = bl.Subtotal - bl.Discount
+		b.Lines = append(b.Lines, bl)
+		b.Subtotal += bl.Subtotal
+		b.Discount += bl.Discount
+	}
+	b.Total = b.Subtotal - b.Discount
+	return b
+}
+
+// ApplyCoupon confirms the promotion can be redeemed at the given time and applies to
+// at least one of the lines, and prices the lines with it.
+func ApplyCoupon(p *models.Promotion, lines []*models.PricedLine, now time.Time) (*models.PriceBreakdown, error) {
+	if err := ValidatePromotionUsable(p, now); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: err.Error(),
+		}
+	}
+	// A fixed amount off, set in the products' currency, cannot be converted without a rate.
+	if p.Kind == models.PromotionKindFixed && len(lines) > 0 && lines[0].Currency != lines[0].BaseCurrency && !(lines[0].Rate > 0) {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("coupon %s cannot be applied without an exchange rate from %s to %s", p.Code, lines[0].BaseCurrency, lines[0].Currency),
+		}
+	}
+	b := PriceLines(lines, p)
+	if b.Discount == 0 {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("coupon %s does not apply to any items", p.Code),
+		}
+	}
+	return b, nil
+}
+
+// CouponNotFound returns a NotFound error for the coupon code.
+func CouponNotFound(code string) error {
+	return &errs.Error{
+		Code:    errs.NotFound,
+		Message: fmt.Sprintf("coupon %s not found", code),
+	}
+}
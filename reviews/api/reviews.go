@@ -0,0 +1,344 @@
+package reviews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	coreutils "encore.app/core/utils"
+	ordersmodels "encore.app/orders/models"
+	products "encore.app/products/api"
+	productsdb "encore.app/products/db"
+	db "encore.app/reviews/db"
+	models "encore.app/reviews/models"
+	utils "encore.app/reviews/utils"
+	"encore.dev/beta/errs"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/cache"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// ReviewsTable instance.
+var ReviewsTable = &db.ReviewsTable{DB: PlamatioDB}
+
+// ProductsTB instance, used to maintain product ratings.
+var ProductsTB = &productsdb.ProductsTB{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup Caching
+
+// ReviewsCluster is the cache cluster for reviews.
+var ReviewsCluster = cache.NewCluster("reviews-cache-cluster", cache.ClusterConfig{
+    // Use LRU policy to evict keys when the cache reaches memory limit.
+    EvictionPolicy: cache.AllKeysLRU,
+})
+
+// Review Cache Keyspace to store reviews by ID.
+var ReviewCacheKeyspace = cache.NewStructKeyspace[int, models.Review](ReviewsCluster, cache.KeyspaceConfig{
+	KeyPattern:    "review-cache/:key",
+	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
+})
+
+// Product Reviews Cache Keyspace to store pages of reviews by product reviews version, product and list query.
+var ProductReviewsCacheKeyspace = cache.NewStructKeyspace[string, models.ReviewPage](ReviewsCluster, cache.KeyspaceConfig{
+	KeyPattern:    "product-reviews-cache/:key",
+	DefaultExpiry: cache.ExpireIn(24 * time.Hour),
+})
+
+// Product Reviews Version Keyspace to store the version of the cached review pages by product ID.
+// Bumping the version retires every cached page of the product's reviews.
+var ProductReviewsVersionKeyspace = cache.NewIntKeyspace[int](ReviewsCluster, cache.KeyspaceConfig{
+	KeyPattern: "product-reviews-version/:key",
+})
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Primary endpoints for reviews:
+
+- GET: /reviews/get/:id
+- GET: /reviews/product/:product_id
+- POST: /reviews/add
+- PUT: /reviews/update/:id
+- DELETE: /reviews/delete/:id
+
+Only users with a delivered order containing the product may review it.
+*/
+
+// GET: /reviews/get/:id
+// Retrieves the review with the given ID.
+//encore:api auth method=GET path=/reviews/get/:id
+func GetReview(ctx context.Context, id int) (*models.Review, error) {
+	// First, try retrieving the review from cache if it exists.
+	c, err := ReviewCacheKeyspace.Get(ctx, id)
+	// if review is found (i.e., no error), return it
+	if err == nil {
+		return &c, nil
+	}
+	// If the review is not found in cache, retrieve it from the database.
+	r, err := ReviewsTable.GetReview(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, reviewNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Fire a go routine to cache the review.
+	go func() {
+		// Cache the review.
+		if err := ReviewCacheKeyspace.Set(ctx, id, *r); err != nil {
+			// Log the error
+			rlog.Error("error caching review data", err)
+		}
+	}()
+	// Return the review.
+	return r, nil
+}
+
+// GET: /reviews/product/:product_id
+// Retrieves a page of reviews of the product, newest first.
+// Pass the returned nextCursor as cursor to retrieve the following page.
+//encore:api auth method=GET path=/reviews/product/:product_id
+func GetProductReviews(ctx context.Context, product_id int, p *models.ReviewListParams) (*models.ReviewPage, error) {
+	// Validate the query parameters.
+	utils.ApplyReviewListDefaults(p)
+	if err := utils.ValidateReviewListParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	var after *models.ReviewCursor
+	if p.Cursor != "" {
+		c, err := utils.DecodeReviewCursor(p.Cursor)
+		if err != nil {
+			return nil, &errs.Error{
+				Code:    errs.InvalidArgument,
+				Message: err.Error(),
+			}
+		}
+		after = c
+	}
+	// First, try retrieving the page from cache if it exists.
+	version, err := ProductReviewsVersionKeyspace.Get(ctx, product_id)
+	if err != nil {
+		version = 0
+	}
+	key := fmt.Sprintf("v%d/product=%d&limit=%d&cursor=%s", version, product_id, p.Limit, p.Cursor)
+	c, err := ProductReviewsCacheKeyspace.Get(ctx, key)
+	// if page is found (i.e., no error), return it
+	if err == nil {
+		return &c, nil
+	}
+	// If the page is not found in cache, retrieve it from the database.
+	// One extra review is requested to know whether there is a next page.
+	reviews, err := ReviewsTable.ListByProduct(ctx, product_id, after, p.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	r := &models.ReviewPage{Data: reviews}
+	if len(reviews) > p.Limit {
+		r.Data = reviews[:p.Limit]
+		last := r.Data[len(r.Data)-1]
+		r.NextCursor = utils.EncodeReviewCursor(&models.ReviewCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	// Fire a go routine to cache the page.
+	go func() {
+		// Cache the page.
+		if err := ProductReviewsCacheKeyspace.Set(ctx, key, *r); err != nil {
+			// Log the error
+			rlog.Error("error caching product reviews data", err)
+		}
+	}()
+	// Return the page.
+	return r, nil
+}
+
+// POST: /reviews/add
+// Creates a review of a product the user has received in a delivered order.
+// A user can review each product once.
+//encore:api auth method=POST path=/reviews/add
+func AddReview(ctx context.Context, p *models.ReviewRequestParams) (*models.Review, error) {
+	// Validate the review data.
+	if err := utils.ValidateReviewRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Confirm the caller is the reviewer.
+	if err := coreutils.RequireUser(p.UserID); err != nil {
+		return nil, err
+	}
+	// Confirm the user has received the product.
+	delivered, err := ReviewsTable.HasOrderedProduct(ctx, p.UserID, p.ProductID, ordersmodels.OrderStatusDelivered)
+	if err != nil {
+		return nil, err
+	}
+	if !delivered {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("product %d has not been delivered to the user", p.ProductID),
+		}
+	}
+	// Insert the review and refresh the product rating.
+	var r *models.Review
+	err = updateProductRating(ctx, p.ProductID, func(tx *sqldb.Tx) error {
+		var err error
+		r, err = ReviewsTable.InsertReviewTx(ctx, tx, p)
+		return err
+	})
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return nil, &errs.Error{
+			Code:    errs.AlreadyExists,
+			Message: fmt.Sprintf("user has already reviewed product %d", p.ProductID),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Return the review.
+	return r, nil
+}
+
+// PUT: /reviews/update/:id
+// Edits the review with the given ID.
+//encore:api auth method=PUT path=/reviews/update/:id
+func UpdateReview(ctx context.Context, id int, p *models.ReviewUpdateParams) (*models.Review, error) {
+	// Validate the review data.
+	if err := utils.ValidateReviewContent(p.Rating, p.Title, p.Body); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Retrieve the review and confirm the caller is the reviewer.
+	r, err := getReviewForChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Update the review and refresh the product rating.
+	r.Rating, r.Title, r.Body, r.UpdatedAt = p.Rating, p.Title, p.Body, time.Now().UTC()
+	err = updateProductRating(ctx, r.ProductID, func(tx *sqldb.Tx) error {
+		return ReviewsTable.UpdateReviewTx(ctx, tx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Fire a go routine to invalidate the cache for the review.
+	go invalidateReviewCache(ctx, id)
+	// Return the updated review.
+	return r, nil
+}
+
+// DELETE: /reviews/delete/:id
+// Deletes the review with the given ID.
+//encore:api auth method=DELETE path=/reviews/delete/:id
+func DeleteReview(ctx context.Context, id int) (*models.ReviewChangeRequestReturn, error) {
+	// Retrieve the review and confirm the caller is the reviewer.
+	r, err := getReviewForChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Delete the review and refresh the product rating.
+	err = updateProductRating(ctx, r.ProductID, func(tx *sqldb.Tx) error {
+		return ReviewsTable.DeleteReviewTx(ctx, tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Fire a go routine to invalidate the cache for the review.
+	go invalidateReviewCache(ctx, id)
+	// Return request status.
+	return &models.ReviewChangeRequestReturn{ReviewID: id}, nil
+}
+
+// getReviewForChange retrieves the review with the given ID from the database and
+// confirms the caller may change it.
+func getReviewForChange(ctx context.Context, id int) (*models.Review, error) {
+	r, err := ReviewsTable.GetReview(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, reviewNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(r.UserID); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// updateProductRating applies the review change and recomputes the product's average
+// rating and review count in a single transaction, then invalidates the cached
+// reviews and product listings of the product. The product is locked first, so
+// concurrent review changes of the same product refresh its rating one at a time.
+func updateProductRating(ctx context.Context, productID int, change func(tx *sqldb.Tx) error) error {
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the product until the rating is refreshed.
+	if err := ProductsTB.LockTx(ctx, tx, productID); err != nil {
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return &errs.Error{
+				Code:    errs.NotFound,
+				Message: fmt.Sprintf("product %d not found", productID),
+			}
+		}
+		return err
+	}
+	if err := change(tx); err != nil {
+		return err
+	}
+	if err := ProductsTB.RefreshRatingTx(ctx, tx, productID); err != nil {
+		return err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Fire a go routine to invalidate the cache for the product's reviews and the product.
+	go func() {
+		// Retire the cached pages of the product's reviews.
+		if _, err := ProductReviewsVersionKeyspace.Increment(ctx, productID, 1); err != nil {
+			// log error
+			rlog.Error("Error incrementing product reviews version", err)
+		}
+		// Invalidate the cached product, which carries the rating.
+		if err := products.InvalidateProductCache(ctx, productID); err != nil {
+			// log error
+			rlog.Error("Error deleting product cache", err)
+		}
+	}()
+	return nil
+}
+
+// invalidateReviewCache removes the review from cache.
+func invalidateReviewCache(ctx context.Context, id int) {
+	if _, err := ReviewCacheKeyspace.Delete(ctx, id); err != nil {
+		// log error
+		rlog.Error("Error deleting review cache", err)
+	}
+}
+
+// reviewNotFound returns a NotFound error for the review with the given ID.
+func reviewNotFound(id int) error {
+	return &errs.Error{
+		Code:    errs.NotFound,
+		Message: fmt.Sprintf("review %d not found", id),
+	}
+}
@@ -0,0 +1,106 @@
+package reviews
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/reviews/models"
+	"encore.dev/storage/sqldb"
+)
+
+type ReviewsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_REVIEW = `
+				SELECT product_id, user_id, rating, title, body, created_at, updated_at FROM reviews
+				WHERE id = $1
+		`
+		SQL_LIST_REVIEWS_BY_PRODUCT = `
+				SELECT id, product_id, user_id, rating, title, body, created_at, updated_at FROM reviews
+				WHERE product_id = $1
+					AND ($2::BIGINT = 0 OR (created_at, id) < ($3, $2))
+				ORDER BY created_at DESC, id DESC
+				LIMIT $4
+		`
+		SQL_INSERT_REVIEW = `
+				INSERT INTO reviews (product_id, user_id, rating, title, body, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $6)
+				RETURNING id
+		`
+		SQL_UPDATE_REVIEW = `
+				UPDATE reviews SET rating = $1, title = $2, body = $3, updated_at = $4
+				WHERE id = $5
+		`
+		SQL_DELETE_REVIEW = `
+				DELETE FROM reviews WHERE id = $1
+		`
+		SQL_HAS_ORDERED_PRODUCT = `
+				SELECT EXISTS (
+					SELECT 1 FROM orders o
+					INNER JOIN order_items oi ON oi.order_id = o.id
+					WHERE o.user_id = $1 AND oi.product_id = $2 AND o.status = $3
+				)
+		`
+)
+
+// Retrieves a review from the database.
+func (tb *ReviewsTable) GetReview(ctx context.Context, id int) (*models.Review, error) {
+	r := &models.Review{ID: id}
+	err := tb.DB.QueryRow(ctx, SQL_GET_REVIEW, id).Scan(&r.ProductID, &r.UserID, &r.Rating, &r.Title, &r.Body, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// Retrieves up to limit reviews of a product from the database, newest first.
+// If after is set, only reviews older than the cursor are returned.
+func (tb *ReviewsTable) ListByProduct(ctx context.Context, productId int, after *models.ReviewCursor, limit int) ([]*models.Review, error) {
+	afterID, afterCreatedAt := 0, time.Time{}
+	if after != nil {
+		afterID, afterCreatedAt = after.ID, after.CreatedAt
+	}
+	rows, err := tb.DB.Query(ctx, SQL_LIST_REVIEWS_BY_PRODUCT, productId, afterID, afterCreatedAt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []*models.Review
+	for rows.Next() {
+		r := &models.Review{}
+		if err := rows.Scan(&r.ID, &r.ProductID, &r.UserID, &r.Rating, &r.Title, &r.Body, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, nil
+}
+
+// Inserts a review into the database as part of the given transaction.
+func (tb *ReviewsTable) InsertReviewTx(ctx context.Context, tx *sqldb.Tx, p *models.ReviewRequestParams) (*models.Review, error) {
+	now := time.Now().UTC()
+	r := &models.Review{ProductID: p.ProductID, UserID: p.UserID, Rating: p.Rating, Title: p.Title, Body: p.Body, CreatedAt: now, UpdatedAt: now}
+	if err := tx.QueryRow(ctx, SQL_INSERT_REVIEW, p.ProductID, p.UserID, p.Rating, p.Title, p.Body, now).Scan(&r.ID); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Updates a review in the database as part of the given transaction.
+func (tb *ReviewsTable) UpdateReviewTx(ctx context.Context, tx *sqldb.Tx, r *models.Review) error {
+	_, err := tx.Exec(ctx, SQL_UPDATE_REVIEW, r.Rating, r.Title, r.Body, r.UpdatedAt, r.ID)
+	return err
+}
+
+// Deletes a review from the database as part of the given transaction.
+func (tb *ReviewsTable) DeleteReviewTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_REVIEW, id)
+	return err
+}
+
+// Checks whether the user has an order with the given status containing the product.
+func (tb *ReviewsTable) HasOrderedProduct(ctx context.Context, userId string, productId int, status string) (bool, error) {
+	var ok bool
+	err := tb.DB.QueryRow(ctx, SQL_HAS_ORDERED_PRODUCT, userId, productId, status).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,54 @@
+package reviews
+
+import "time"
+
+// Review represents a user's review of a product.
+type Review struct {
+	ID        int       `json:"id"`        // unique identifier
+	ProductID int       `json:"productId"` // product being reviewed
+	UserID    string    `json:"userId"`    // user who wrote the review
+	Rating    int       `json:"rating"`    // rating, from 1 to 5
+	Title     string    `json:"title"`     // title of the review
+	Body      string    `json:"body"`      // text of the review
+	CreatedAt time.Time `json:"createdAt"` // time the review was written
+	UpdatedAt time.Time `json:"updatedAt"` // time the review was last edited
+}
+
+// ReviewPage represents a page of reviews.
+type ReviewPage struct {
+	Data       []*Review `json:"data"`
+	NextCursor string    `json:"nextCursor"` // cursor for the next page; empty when there are no more reviews
+}
+
+// ReviewListParams represents the query parameters for listing reviews page by page.
+type ReviewListParams struct {
+	Cursor string `query:"cursor"` // opaque cursor returned as nextCursor by the previous page
+	Limit  int    `query:"limit"`  // maximum number of reviews to return (default 10, max 50)
+}
+
+// ReviewCursor identifies the last review of a page, used to fetch the next page.
+type ReviewCursor struct {
+	CreatedAt time.Time `json:"c"` // creation time of the last review
+	ID        int       `json:"i"` // ID of the last review
+}
+
+// ReviewRequestParams represents the request parameters for creating a review.
+type ReviewRequestParams struct {
+	ProductID int    `json:"productId"`
+	UserID    string `json:"userId"`
+	Rating    int    `json:"rating"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+}
+
+// ReviewUpdateParams represents the request parameters for editing a review.
+type ReviewUpdateParams struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// Return type for mutations to reviews.
+type ReviewChangeRequestReturn struct {
+	ReviewID int `json:"id"`
+}
@@ -0,0 +1,81 @@
+package reviews
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	models "encore.app/reviews/models"
+)
+
+// Review settings.
+const (
+	DefaultReviewPageSize = 10   // default number of reviews per page
+	MaxReviewPageSize     = 50   // maximum number of reviews per page
+	MaxReviewTitleLength  = 200  // maximum length of a review title
+	MaxReviewBodyLength   = 5000 // maximum length of a review body
+)
+
+func ValidateReviewContent(rating int, title string, body string) error {
+	// validate the review rating, title and body
+	if rating < 1 || rating > 5 {
+		return errors.New("invalid rating; should be between 1 and 5")
+	}
+	if strings.TrimSpace(title) == "" {
+		return errors.New("review title is required")
+	}
+	if len([]rune(title)) > MaxReviewTitleLength {
+		return fmt.Errorf("review title cannot be longer than %d characters", MaxReviewTitleLength)
+	}
+	if len([]rune(body)) > MaxReviewBodyLength {
+		return fmt.Errorf("review body cannot be longer than %d characters", MaxReviewBodyLength)
+	}
+	return nil
+}
+
+func ValidateReviewRequestParams(p *models.ReviewRequestParams) error {
+	// validate the review request parameters
+	if p.ProductID <= 0 {
+		return errors.New("invalid product ID")
+	}
+	if p.UserID == "" {
+		return errors.New("invalid user ID")
+	}
+	return ValidateReviewContent(p.Rating, p.Title, p.Body)
+}
+
+// ApplyReviewListDefaults fills in default values for unset review list parameters.
+func ApplyReviewListDefaults(p *models.ReviewListParams) {
+	if p.Limit == 0 {
+		p.Limit = DefaultReviewPageSize
+	}
+}
+
+func ValidateReviewListParams(p *models.ReviewListParams) error {
+	// validate the review list parameters
+	if p.Limit < 1 || p.Limit > MaxReviewPageSize {
+		return fmt.Errorf("invalid limit; should be between 1 and %d", MaxReviewPageSize)
+	}
+	return nil
+}
+
+// EncodeReviewCursor encodes a review cursor into an opaque string.
+func EncodeReviewCursor(c *models.ReviewCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeReviewCursor decodes an opaque review cursor string.
+func DecodeReviewCursor(s string) (*models.ReviewCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	c := &models.ReviewCursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return c, nil
+}
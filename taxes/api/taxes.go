@@ -0,0 +1,158 @@
+package taxes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	db "encore.app/taxes/db"
+	models "encore.app/taxes/models"
+	utils "encore.app/taxes/utils"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// TaxRatesTable instance.
+var TaxRatesTable = &db.TaxRatesTable{DB: PlamatioDB}
+
+// TaxExemptionsTable instance.
+var TaxExemptionsTable = &db.TaxExemptionsTable{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints to manage tax settings (catalog-admin only):
+
+- GET: /taxes/rates/all
+- PUT: /taxes/rates/set
+- DELETE: /taxes/rates/delete/:id
+- GET: /taxes/exemptions/all
+- POST: /taxes/exemptions/add
+- DELETE: /taxes/exemptions/delete/:id
+
+Tax is computed from these settings when an order is placed, using the country and
+state of the order's shipping address. Countries and states are matched case-insensitively.
+*/
+
+// GET: /taxes/rates/all
+// Retrieves all tax rates.
+//encore:api auth method=GET path=/taxes/rates/all
+func GetTaxRates(ctx context.Context) (*models.TaxRates, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	return TaxRatesTable.GetAll(ctx)
+}
+
+// PUT: /taxes/rates/set
+// Sets the tax rate of a country, or of a state within a country, replacing any existing rate.
+//encore:api auth method=PUT path=/taxes/rates/set
+func SetTaxRate(ctx context.Context, p *models.TaxRateRequestParams) (*models.TaxRate, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the tax rate data.
+	p.Country, p.State = utils.NormalizeRegion(p.Country), utils.NormalizeRegion(p.State)
+	if err := utils.ValidateTaxRateRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	return TaxRatesTable.Upsert(ctx, p)
+}
+
+// DELETE: /taxes/rates/delete/:id
+// Deletes the tax rate with the given ID.
+//encore:api auth method=DELETE path=/taxes/rates/delete/:id
+func DeleteTaxRate(ctx context.Context, id int) (*models.TaxChangeRequestReturn, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	if err := TaxRatesTable.Delete(ctx, id); err != nil {
+		return nil, taxError(err, "tax rate", id)
+	}
+	return &models.TaxChangeRequestReturn{ID: id}, nil
+}
+
+// GET: /taxes/exemptions/all
+// Retrieves all tax exemptions.
+//encore:api auth method=GET path=/taxes/exemptions/all
+func GetTaxExemptions(ctx context.Context) (*models.TaxExemptions, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	return TaxExemptionsTable.GetAll(ctx)
+}
+
+// POST: /taxes/exemptions/add
+// Exempts a product category from tax in a country, or in a state within a country.
+//encore:api auth method=POST path=/taxes/exemptions/add
+func AddTaxExemption(ctx context.Context, p *models.TaxExemptionRequestParams) (*models.TaxExemption, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the tax exemption data.
+	p.Country, p.State = utils.NormalizeRegion(p.Country), utils.NormalizeRegion(p.State)
+	if err := utils.ValidateTaxExemptionRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Insert the tax exemption into the database.
+	e, err := TaxExemptionsTable.Insert(ctx, p)
+	if sqldb.ErrCode(err) == sqlerr.ForeignKeyViolation {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: fmt.Sprintf("category %d does not exist", p.CategoryId),
+		}
+	}
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return nil, &errs.Error{
+			Code:    errs.AlreadyExists,
+			Message: "the category is already exempt in this region",
+		}
+	}
+	return e, err
+}
+
+// DELETE: /taxes/exemptions/delete/:id
+// Deletes the tax exemption with the given ID.
+//encore:api auth method=DELETE path=/taxes/exemptions/delete/:id
+func DeleteTaxExemption(ctx context.Context, id int) (*models.TaxChangeRequestReturn, error) {
+	// Confirm the caller may manage tax settings.
+	if err := coreutils.RequireRole(coremodels.RoleCatalogAdmin); err != nil {
+		return nil, err
+	}
+	if err := TaxExemptionsTable.Delete(ctx, id); err != nil {
+		return nil, taxError(err, "tax exemption", id)
+	}
+	return &models.TaxChangeRequestReturn{ID: id}, nil
+}
+
+// taxError converts database errors for the tax setting with the given ID into API errors.
+func taxError(err error, kind string, id int) error {
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("%s %d not found", kind, id),
+		}
+	}
+	return err
+}
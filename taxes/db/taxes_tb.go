@@ -0,0 +1,150 @@
+package taxes
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/taxes/models"
+	"encore.dev/storage/sqldb"
+)
+
+type TaxRatesTable struct {
+	DB *sqldb.Database
+}
+
+type TaxExemptionsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ALL_TAX_RATES = `
+				SELECT id, country, state, name, rate, updated_at FROM tax_rates
+				ORDER BY country, state
+		`
+		SQL_UPSERT_TAX_RATE = `
+				INSERT INTO tax_rates (country, state, name, rate, updated_at)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (country, state)
+				DO UPDATE SET name = EXCLUDED.name, rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+				RETURNING id
+		`
+		SQL_DELETE_TAX_RATE = `
+				DELETE FROM tax_rates WHERE id = $1 RETURNING id
+		`
+		SQL_GET_JURISDICTION_TAX_RATE = `
+				SELECT COALESCE(SUM(rate), 0) FROM tax_rates
+				WHERE country = $1 AND (state = '' OR state = $2)
+		`
+		SQL_GET_ALL_TAX_EXEMPTIONS = `
+				SELECT id, country, state, category_id, created_at FROM tax_exemptions
+				ORDER BY country, state, category_id
+		`
+		SQL_INSERT_TAX_EXEMPTION = `
+				INSERT INTO tax_exemptions (country, state, category_id, created_at)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id
+		`
+		SQL_DELETE_TAX_EXEMPTION = `
+				DELETE FROM tax_exemptions WHERE id = $1 RETURNING id
+		`
+		SQL_GET_JURISDICTION_TAX_EXEMPTIONS = `
+				SELECT DISTINCT category_id FROM tax_exemptions
+				WHERE country = $1 AND (state = '' OR state = $2)
+		`
+)
+
+// Retrieves all tax rates from the database.
+func (tb *TaxRatesTable) GetAll(ctx context.Context) (*models.TaxRates, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_TAX_RATES)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := &models.TaxRates{}
+	for rows.Next() {
+		r := &models.TaxRate{}
+		if err := rows.Scan(&r.ID, &r.Country, &r.State, &r.Name, &r.Rate, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates.Data = append(rates.Data, r)
+	}
+	return rates, nil
+}
+
+// Sets the tax rate of a country or state, replacing any existing rate.
+func (tb *TaxRatesTable) Upsert(ctx context.Context, p *models.TaxRateRequestParams) (*models.TaxRate, error) {
+	r := &models.TaxRate{Country: p.Country, State: p.State, Name: p.Name, Rate: p.Rate, UpdatedAt: time.Now()}
+	err := tb.DB.QueryRow(ctx, SQL_UPSERT_TAX_RATE, p.Country, p.State, p.Name, p.Rate, r.UpdatedAt).Scan(&r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Deletes a tax rate from the database.
+// Returns sqldb.ErrNoRows if the tax rate does not exist.
+func (tb *TaxRatesTable) Delete(ctx context.Context, id int) error {
+	return tb.DB.QueryRow(ctx, SQL_DELETE_TAX_RATE, id).Scan(&id)
+}
+
+// Retrieves the combined country and state tax rate as part of the given transaction.
+func (tb *TaxRatesTable) GetJurisdictionRateTx(ctx context.Context, tx *sqldb.Tx, country string, state string) (float64, error) {
+	var rate float64
+	err := tx.QueryRow(ctx, SQL_GET_JURISDICTION_TAX_RATE, country, state).Scan(&rate)
+	return rate, err
+}
+
+// Retrieves all tax exemptions from the database.
+func (tb *TaxExemptionsTable) GetAll(ctx context.Context) (*models.TaxExemptions, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_TAX_EXEMPTIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exemptions := &models.TaxExemptions{}
+	for rows.Next() {
+		e := &models.TaxExemption{}
+		if err := rows.Scan(&e.ID, &e.Country, &e.State, &e.CategoryId, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exemptions.Data = append(exemptions.Data, e)
+	}
+	return exemptions, nil
+}
+
+// Inserts a tax exemption into the database.
+func (tb *TaxExemptionsTable) Insert(ctx context.Context, p *models.TaxExemptionRequestParams) (*models.TaxExemption, error) {
+	e := &models.TaxExemption{Country: p.Country, State: p.State, CategoryId: p.CategoryId, CreatedAt: time.Now()}
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_TAX_EXEMPTION, p.Country, p.State, p.CategoryId, e.CreatedAt).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Deletes a tax exemption from the database.
+// Returns sqldb.ErrNoRows if the tax exemption does not exist.
+func (tb *TaxExemptionsTable) Delete(ctx context.Context, id int) error {
+	return tb.DB.QueryRow(ctx, SQL_DELETE_TAX_EXEMPTION, id).Scan(&id)
+}
+
+// Retrieves the categories exempt from tax in a country or state as part of the given transaction.
+func (tb *TaxExemptionsTable) GetJurisdictionExemptionsTx(ctx context.Context, tx *sqldb.Tx, country string, state string) ([]int, error) {
+	rows, err := tx.Query(ctx, SQL_GET_JURISDICTION_TAX_EXEMPTIONS, country, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
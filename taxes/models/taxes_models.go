@@ -0,0 +1,88 @@
+package taxes
+
+import "time"
+
+// TaxRate represents the tax rate of a country, or of a state within a country.
+// The rate of a state applies on top of the rate of its country.
+type TaxRate struct {
+	ID        int       `json:"id"`
+	Country   string    `json:"country"` // country the rate applies in
+	State     string    `json:"state"`   // state the rate applies in; empty for the country-wide rate
+	Name      string    `json:"name"`    // name of the tax, e.g. "GST"
+	Rate      float64   `json:"rate"`    // fraction of the taxable amount, e.g. 0.05 for 5%
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TaxRates represents a collection of tax rates.
+type TaxRates struct {
+	Data []*TaxRate `json:"data"`
+}
+
+// TaxRateRequestParams represents the parameters required to set a tax rate.
+type TaxRateRequestParams struct {
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+	Name    string  `json:"name"`
+	Rate    float64 `json:"rate"`
+}
+
+// TaxExemption represents a category of products that is not taxed in a country,
+// or in a state within a country.
+type TaxExemption struct {
+	ID         int       `json:"id"`
+	Country    string    `json:"country"`  // country the exemption applies in
+	State      string    `json:"state"`    // state the exemption applies in; empty for the whole country
+	CategoryId int       `json:"category"` // exempt product category
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// TaxExemptions represents a collection of tax exemptions.
+type TaxExemptions struct {
+	Data []*TaxExemption `json:"data"`
+}
+
+// TaxExemptionRequestParams represents the parameters required to create a tax exemption.
+type TaxExemptionRequestParams struct {
+	Country    string `json:"country"`
+	State      string `json:"state"`
+	CategoryId int    `json:"category"`
+}
+
+// TaxJurisdiction represents the tax rate and exempt categories that apply at an address.
+type TaxJurisdiction struct {
+	Country           string
+	State             string
+	Rate              float64 // combined country and state rate
+	ExemptCategoryIds []int
+}
+
+// TaxableLine represents an item to be taxed.
+type TaxableLine struct {
+	ProductID  int
+	CategoryId int
+	Quantity   int
+	Amount     int // price of all units after discount, in minor units
+}
+
+// TaxLine represents the tax on a single item.
+type TaxLine struct {
+	ProductID     int     `json:"productId"`
+	Quantity      int     `json:"quantity"`
+	TaxableAmount int     `json:"taxableAmount"` // price of all units after discount, in minor units
+	Rate          float64 `json:"rate"`          // rate applied; 0 if exempt
+	Tax           int     `json:"tax"`           // tax on the item, in minor units
+	Exempt        bool    `json:"exempt"`        // whether the item's category is exempt
+}
+
+// TaxBreakdown represents the tax on an order.
+type TaxBreakdown struct {
+	Country string     `json:"country"` // country the order ships to
+	State   string     `json:"state"`   // state the order ships to
+	Lines   []*TaxLine `json:"lines"`
+	Total   int        `json:"total"` // tax on the order, in minor units of the order currency
+}
+
+// Return type for mutations to tax rates and exemptions.
+type TaxChangeRequestReturn struct {
+	ID int `json:"id"`
+}
@@ -0,0 +1,59 @@
+package taxes
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+
+	models "encore.app/taxes/models"
+)
+
+// NormalizeRegion trims and upper-cases a country or state, so that tax settings
+// match addresses regardless of how they were entered.
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+func ValidateTaxRateRequestParams(p *models.TaxRateRequestParams) error {
+	// validate the tax rate request parameters
+	if p.Country == "" {
+		return errors.New("country is required")
+	}
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if !(p.Rate >= 0 && p.Rate < 1) {
+		return errors.New("invalid rate; should be a fraction between 0 and 1, e.g. 0.05 for 5%")
+	}
+	return nil
+}
+
+func ValidateTaxExemptionRequestParams(p *models.TaxExemptionRequestParams) error {
+	// validate the tax exemption request parameters
+	if p.Country == "" {
+		return errors.New("country is required")
+	}
+	if p.CategoryId <= 0 {
+		return errors.New("invalid category")
+	}
+	return nil
+}
+
+// ComputeTax computes the tax on each line at the jurisdiction's rate, rounding each
+// line to the nearest minor unit. Lines in an exempt category are not taxed.
+func ComputeTax(j *models.TaxJurisdiction, lines []*models.TaxableLine) *models.TaxBreakdown {
+	b := &models.TaxBreakdown{Country: j.Country, State: j.State}
+	for _, l := range lines {
+		tl := &models.TaxLine{ProductID: l.ProductID, Quantity: l.Quantity, TaxableAmount: l.Amount}
+		if slices.Contains(j.ExemptCategoryIds, l.CategoryId) {
+			tl.Exempt = true
+		} else {
+			tl.Rate = j.Rate
+			tl.Tax = int(math.Round(float64(l.Amount) * j.Rate))
+		}
+		b.Lines = append(b.Lines, tl)
+		b.Total += tl.Tax
+	}
+	return b
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	coreutils "encore.app/core/utils"
 	db "encore.app/users/db"
 	models "encore.app/users/models"
 	rlog "encore.dev/rlog"
@@ -46,8 +47,11 @@ var UserAddressesCacheKeyspace = cache.NewStructKeyspace[string, models.Addresse
 func GetAddress(ctx context.Context, id int) (*models.Address, error) {
 	// First, try retrieving the address from cache if it exists.
 	a, err := AddressCacheKeyspace.Get(ctx, id)
-	// if address is found (i.e., no error), return it
+	// if address is found (i.e., no error), return it if the caller owns it
 	if err == nil {
+		if err := coreutils.RequireUser(a.UserID); err != nil {
+			return nil, err
+		}
 		return &a, nil
 	}
 	// If the address is not found in cache, retrieve it from the database.
@@ -55,6 +59,10 @@ func GetAddress(ctx context.Context, id int) (*models.Address, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Confirm the caller owns the address.
+	if err := coreutils.RequireUser(r.UserID); err != nil {
+		return nil, err
+	}
 	// Fire a go routine to cache the address.
 	go func() {
 		// Cache the address.
@@ -71,6 +79,10 @@ func GetAddress(ctx context.Context, id int) (*models.Address, error) {
 // Retrieves all addresses for a user from the database with the given user ID.
 //encore:api auth method=GET path=/users/addresses/user/:user_id
 func GetUserAddresses(ctx context.Context, user_id string) (*models.Addresses, error) {
+	// Confirm the caller owns the addresses.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
 	// First, try retrieving the user addresses from cache if it exists.
 	a, err := UserAddressesCacheKeyspace.Get(ctx, user_id)
 	// if user addresses are found (i.e., no error), return them
@@ -98,6 +110,10 @@ func GetUserAddresses(ctx context.Context, user_id string) (*models.Addresses, e
 // Inserts an address into the database.
 //encore:api auth method=POST path=/users/addresses/add
 func AddAddress(ctx context.Context, newAddress *models.AddressRequestParams) (*models.Address, error) {
+	// Confirm the caller owns the address.
+	if err := coreutils.RequireUser(newAddress.UserID); err != nil {
+		return nil, err
+	}
 	// Insert the address into the database.
 	r, err := AddressesTable.InsertAddress(ctx, newAddress)
 	if err != nil {
@@ -111,6 +127,13 @@ func AddAddress(ctx context.Context, newAddress *models.AddressRequestParams) (*
 // Updates an address in the database.
 //encore:api auth method=PUT path=/users/addresses/update
 func UpdateAddress(ctx context.Context, updatedAddress *models.Address) (*models.AddressChangeRequestReturn, error) {
+	// Confirm the caller owns the address, both before and after the update.
+	if err := requireAddressOwner(ctx, updatedAddress.ID); err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(updatedAddress.UserID); err != nil {
+		return nil, err
+	}
 	// Update the address in the database.
 	err := AddressesTable.UpdateAddress(ctx, updatedAddress)
 	if err != nil {
@@ -144,7 +167,13 @@ func UpdateAddress(ctx context.Context, updatedAddress *models.Address) (*models
 // Deletes an address from the database.
 //encore:api auth method=DELETE path=/users/addresses/delete/:address_id/user/:user_id
 func DeleteAddress(ctx context.Context, address_id int, user_id string) (*models.AddressChangeRequestReturn, error) {
-
+	// Confirm the caller owns the address.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	if err := requireAddressOwner(ctx, address_id); err != nil {
+		return nil, err
+	}
 	// Delete the address from the database.
 	err := AddressesTable.DeleteAddress(ctx, address_id)
 	if err != nil {
@@ -171,4 +200,13 @@ func DeleteAddress(ctx context.Context, address_id int, user_id string) (*models
 
 	// Return request status.
 	return &models.AddressChangeRequestReturn{AddressId: address_id}, nil
-}
\ No newline at end of file
+}
+
+// requireAddressOwner confirms the authenticated caller owns the stored address with the given ID.
+func requireAddressOwner(ctx context.Context, id int) error {
+	a, err := AddressesTable.GetAddress(ctx, id)
+	if err != nil {
+		return err
+	}
+	return coreutils.RequireUser(a.UserID)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
 	db "encore.app/users/db"
 	models "encore.app/users/models"
 	rlog "encore.dev/rlog"
@@ -48,6 +50,10 @@ var RefUserCacheKeyspace = cache.NewStructKeyspace[string, models.User](UsersClu
 // Retrieves the user from the database with the given ID.
 //encore:api auth method=GET path=/users/get/:id
 func GetUser(ctx context.Context, id string) (*models.User, error) {
+	// Confirm the caller owns the user.
+	if err := coreutils.RequireUser(id); err != nil {
+		return nil, err
+	}
 	// First, try retrieving the user from cache if it exists.
 	u, err := UserCacheKeyspace.Get(ctx, id)
 	// if user is found (i.e., no error), return it
@@ -73,8 +79,12 @@ func GetUser(ctx context.Context, id string) (*models.User, error) {
 
 // GET: /users/all
 // Retrieves all users from the database.
-//encore:api private method=GET path=/users/all
+//encore:api auth method=GET path=/users/all
 func GetAllUsers(ctx context.Context) (*models.Users, error) {
+	// Confirm the caller may read all customer data.
+	if err := coreutils.RequireRole(coremodels.RoleSupport); err != nil {
+		return nil, err
+	}
 	// Retrieve all users from the database.
 	r, err := UsersTable.GetAllUsers(ctx)
 	if err != nil {
@@ -88,6 +98,10 @@ func GetAllUsers(ctx context.Context) (*models.Users, error) {
 // Inserts a user into the database.
 //encore:api auth method=POST path=/users/add
 func AddUser(ctx context.Context, newUser *models.User) (*models.User, error) {
+	// Confirm the caller owns the user.
+	if err := coreutils.RequireUser(newUser.ID); err != nil {
+		return nil, err
+	}
 	// Insert the user into the database.
 	r, err := UsersTable.InsertUser(ctx, newUser)
 	if err != nil {
@@ -101,6 +115,10 @@ func AddUser(ctx context.Context, newUser *models.User) (*models.User, error) {
 // Updates a user in the database.
 //encore:api auth method=PUT path=/users/update
 func UpdateUser(ctx context.Context, updatedUser *models.User) (*models.UserChangeRequestReturn, error) {
+	// Confirm the caller owns the user.
+	if err := coreutils.RequireUser(updatedUser.ID); err != nil {
+		return nil, err
+	}
 	// Update the user in the database.
 	err := UsersTable.UpdateUser(ctx, updatedUser)
 	if err != nil {
@@ -127,6 +145,10 @@ func UpdateUser(ctx context.Context, updatedUser *models.User) (*models.UserChan
 // Deletes a user from the database.
 //encore:api auth method=DELETE path=/users/delete/:id
 func DeleteUser(ctx context.Context, id string) (*models.UserChangeRequestReturn, error) {
+	// Confirm the caller owns the user.
+	if err := coreutils.RequireUser(id); err != nil {
+		return nil, err
+	}
 	// Delete the user from the database.
 	err := UsersTable.DeleteUser(ctx, id)
 	if err != nil {
@@ -40,6 +40,13 @@ func (tb *AddressesTable) GetAddress(ctx context.Context, id int) (*models.Addre
 	return a, err
 }
 
+// Retrieves an address as part of the given transaction.
+func (tb *AddressesTable) GetAddressTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.Address, error) {
+	a := &models.Address{ID: id}
+	err := tx.QueryRow(ctx, SQL_GET_ADDRESS, id).Scan(&a.Street, &a.City, &a.State, &a.Country, &a.ZipCode, &a.UserID)
+	return a, err
+}
+
 // Retrieves all addresses for a user from the database.
 func (tb *AddressesTable) GetUserAddresses(ctx context.Context, userID string) (*models.Addresses, error) {
 	rows, err := tb.DB.Query(ctx, SQL_GET_USER_ADDRESSES, userID)
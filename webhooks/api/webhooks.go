@@ -0,0 +1,306 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	coremodels "encore.app/core/models"
+	coreutils "encore.app/core/utils"
+	orders "encore.app/orders/api"
+	ordersmodels "encore.app/orders/models"
+	db "encore.app/webhooks/db"
+	models "encore.app/webhooks/models"
+	utils "encore.app/webhooks/utils"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"encore.dev/pubsub"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// WebhooksTable instance.
+var WebhooksTable = &db.WebhooksTable{DB: PlamatioDB}
+
+// WebhookDeliveriesTable instance.
+var WebhookDeliveriesTable = &db.WebhookDeliveriesTable{DB: PlamatioDB}
+
+// Maximum number of deliveries attempted per run of the delivery job.
+const deliveryBatchSize = 50
+
+// How long a claimed delivery is held off from other runs of the delivery job.
+const deliveryLease = 10 * time.Minute
+
+// httpClient sends the deliveries. Endpoints must respond within its timeout.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// ------------------------------------------------------
+// Setup Subscriptions
+
+// Enqueues a delivery of every order placed.
+var _ = pubsub.NewSubscription(orders.OrderCreatedTopic, "webhooks-order-created", pubsub.SubscriptionConfig[*ordersmodels.OrderCreatedEvent]{
+	Handler: EnqueueOrderCreated,
+})
+
+// Enqueues a delivery of every order status change.
+var _ = pubsub.NewSubscription(orders.OrderStatusChangedTopic, "webhooks-order-status-changed", pubsub.SubscriptionConfig[*ordersmodels.OrderStatusChangedEvent]{
+	Handler: EnqueueOrderStatusChanged,
+})
+
+// EnqueueOrderCreated enqueues a delivery of the order placed to every webhook registered for order.created.
+func EnqueueOrderCreated(ctx context.Context, event *ordersmodels.OrderCreatedEvent) error {
+	key := fmt.Sprintf("%s:%d", models.EventOrderCreated, event.Order.ID)
+	return enqueueEvent(ctx, models.EventOrderCreated, key, event.OccurredAt, event)
+}
+
+// EnqueueOrderStatusChanged enqueues a delivery of the order status change to every webhook
+// registered for order.status_changed.
+func EnqueueOrderStatusChanged(ctx context.Context, event *ordersmodels.OrderStatusChangedEvent) error {
+	key := fmt.Sprintf("%s:%d:%s", models.EventOrderStatusChanged, event.Order.ID, event.ToStatus)
+	return enqueueEvent(ctx, models.EventOrderStatusChanged, key, event.OccurredAt, event)
+}
+
+// enqueueEvent wraps the event in its envelope and enqueues a delivery of it to every
+// webhook registered for its type.
+func enqueueEvent(ctx context.Context, eventType string, key string, occurredAt time.Time, data any) error {
+	payload, err := json.Marshal(&models.EventEnvelope{Type: eventType, Key: key, OccurredAt: occurredAt, Data: data})
+	if err != nil {
+		return err
+	}
+	return WebhookDeliveriesTable.Enqueue(ctx, eventType, key, payload)
+}
+
+// ------------------------------------------------------
+// Setup Cron Jobs
+
+// Delivers pending webhook deliveries.
+var _ = cron.NewJob("deliver-webhooks", cron.JobConfig{
+	Title:    "Deliver pending webhook events",
+	Every:    1 * cron.Minute,
+	Endpoint: DeliverWebhooks,
+})
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Endpoints to manage webhooks of external integrators (super-admin only):
+
+- GET: /webhooks/all
+- POST: /webhooks/add
+- DELETE: /webhooks/delete/:id
+- GET: /webhooks/deliveries/:id
+- POST: /webhooks/replay/delivery/:id
+- POST: /webhooks/replay/all/:id
+- POST: /webhooks/deliver              (private, called by the deliver-webhooks cron job)
+
+Each event is POSTed as JSON to every webhook registered for its type, with the headers:
+
+- X-Plamatio-Event:     the event type
+- X-Plamatio-Delivery:  the ID of the delivery
+- X-Plamatio-Timestamp: the Unix time of the attempt
+- X-Plamatio-Signature: "sha256=" and the hex-encoded HMAC-SHA256, keyed with the webhook
+                        secret, of the timestamp, a "." and the body
+
+A delivery succeeds when the endpoint responds with a 2xx status. Otherwise it is retried
+with exponential backoff, and marked as failed after models.MaxAttempts attempts. Failed
+deliveries can be replayed. Receivers should use the event key in the body to ignore
+events they have already processed.
+*/
+
+// GET: /webhooks/all
+// Retrieves all webhooks. Secrets are never returned.
+//encore:api auth method=GET path=/webhooks/all
+func GetWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	return WebhooksTable.GetAll(ctx)
+}
+
+// POST: /webhooks/add
+// Registers a webhook for an event type. The secret used to sign its deliveries is only
+// returned once.
+//encore:api auth method=POST path=/webhooks/add
+func AddWebhook(ctx context.Context, p *models.WebhookRequestParams) (*models.RegisteredWebhook, error) {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the request parameters.
+	if err := utils.ValidateWebhookRequestParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Generate the secret.
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	// Insert the webhook into the database.
+	uid, _ := auth.UserID()
+	w := &models.Webhook{URL: p.URL, EventType: p.EventType, Description: p.Description, CreatedBy: string(uid), CreatedAt: time.Now().UTC()}
+	id, err := WebhooksTable.Insert(ctx, w, secret)
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return nil, &errs.Error{
+			Code:    errs.AlreadyExists,
+			Message: fmt.Sprintf("a webhook for %s is already registered at this url", p.EventType),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.ID = id
+	// Return the webhook and its secret.
+	return &models.RegisteredWebhook{Webhook: w, Secret: secret}, nil
+}
+
+// DELETE: /webhooks/delete/:id
+// Deletes the webhook with the given ID, along with its deliveries.
+//encore:api auth method=DELETE path=/webhooks/delete/:id
+func DeleteWebhook(ctx context.Context, id int) error {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return err
+	}
+	err := WebhooksTable.Delete(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("webhook %d not found", id),
+		}
+	}
+	return err
+}
+
+// GET: /webhooks/deliveries/:id
+// Retrieves the deliveries of the webhook with the given ID, newest first, optionally
+// only those in the given state.
+//encore:api auth method=GET path=/webhooks/deliveries/:id
+func GetDeliveries(ctx context.Context, id int, p *models.DeliveryListParams) (*models.Deliveries, error) {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	// Validate the query parameters.
+	if err := utils.ValidateDeliveryListParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	return WebhookDeliveriesTable.GetByWebhook(ctx, id, p.Status, p.Limit)
+}
+
+// POST: /webhooks/replay/delivery/:id
+// Replays the failed delivery with the given ID: it is attempted again on the next run
+// of the delivery job, with a fresh set of attempts.
+//encore:api auth method=POST path=/webhooks/replay/delivery/:id
+func ReplayDelivery(ctx context.Context, id int) (*models.ReplayReturn, error) {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	err := WebhookDeliveriesTable.Replay(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("failed delivery %d not found", id),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReplayReturn{Replayed: 1}, nil
+}
+
+// POST: /webhooks/replay/all/:id
+// Replays all failed deliveries of the webhook with the given ID.
+//encore:api auth method=POST path=/webhooks/replay/all/:id
+func ReplayFailedDeliveries(ctx context.Context, id int) (*models.ReplayReturn, error) {
+	// Confirm the caller may manage webhooks.
+	if err := coreutils.RequireRole(coremodels.RoleSuperAdmin); err != nil {
+		return nil, err
+	}
+	n, err := WebhookDeliveriesTable.ReplayFailed(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReplayReturn{Replayed: n}, nil
+}
+
+// POST: /webhooks/deliver
+// Attempts the webhook deliveries that are due, marking each as delivered, or scheduling
+// its next attempt if it fails.
+//encore:api private method=POST path=/webhooks/deliver
+func DeliverWebhooks(ctx context.Context) error {
+	now := time.Now()
+	// Claim the due deliveries.
+	due, err := WebhookDeliveriesTable.ClaimDue(ctx, now, now.Add(deliveryLease), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+	// Attempt each delivery, recording the outcome.
+	for _, dd := range due {
+		d := dd.Delivery
+		responseStatus, err := deliver(ctx, dd)
+		if err == nil {
+			if err := WebhookDeliveriesTable.MarkDelivered(ctx, d.ID, responseStatus, time.Now()); err != nil {
+				return err
+			}
+			continue
+		}
+		// log error
+		rlog.Error("Error delivering webhook", "delivery_id", d.ID, "webhook_id", d.WebhookID, "attempts", d.Attempts, "err", err)
+		next := time.Now().Add(utils.RetryDelay(d.Attempts))
+		if err := WebhookDeliveriesTable.MarkAttemptFailed(ctx, d.ID, responseStatus, err.Error(), next, d.Attempts >= models.MaxAttempts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver POSTs the signed payload of a delivery to its webhook, returning the HTTP status
+// of the response, or 0 if none was received. A non-2xx response is an error.
+func deliver(ctx context.Context, dd *models.DueDelivery) (int, error) {
+	d := dd.Delivery
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dd.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Plamatio-Webhooks/1.0")
+	req.Header.Set("X-Plamatio-Event", d.EventType)
+	req.Header.Set("X-Plamatio-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Plamatio-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Plamatio-Signature", utils.SignPayload(dd.Secret, timestamp, d.Payload))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain (a bounded amount of) the body, so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
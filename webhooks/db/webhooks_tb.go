@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	models "encore.app/webhooks/models"
+	"encore.dev/storage/sqldb"
+)
+
+type WebhooksTable struct {
+	DB *sqldb.Database
+}
+
+type WebhookDeliveriesTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_ALL_WEBHOOKS = `
+				SELECT id, url, event_type, description, created_by, created_at FROM webhooks
+				ORDER BY id
+		`
+		SQL_INSERT_WEBHOOK = `
+				INSERT INTO webhooks (url, event_type, secret, description, created_by, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id
+		`
+		SQL_DELETE_WEBHOOK = `
+				DELETE FROM webhooks WHERE id = $1 RETURNING id
+		`
+		SQL_ENQUEUE_WEBHOOK_DELIVERIES = `
+				INSERT INTO webhook_deliveries (webhook_id, event_type, event_key, payload, next_attempt_at, created_at)
+				SELECT id, event_type, $2, $3::jsonb, $4, $4 FROM webhooks
+				WHERE event_type = $1
+				ON CONFLICT (webhook_id, event_key) DO NOTHING
+		`
+		SQL_CLAIM_DUE_WEBHOOK_DELIVERIES = `
+				UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = $2
+				FROM webhooks w
+				WHERE d.webhook_id = w.id AND d.id IN (
+					SELECT id FROM webhook_deliveries
+					WHERE status = 'pending' AND next_attempt_at <= $1
+					ORDER BY next_attempt_at, id
+					LIMIT $3
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING d.id, d.webhook_id, d.event_type, d.event_key, d.payload, d.status, d.attempts, d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, w.url, w.secret
+		`
+		SQL_MARK_WEBHOOK_DELIVERY_DELIVERED = `
+				UPDATE webhook_deliveries SET status = 'delivered', response_status = $1, last_error = '', delivered_at = $2
+				WHERE id = $3
+		`
+		SQL_MARK_WEBHOOK_DELIVERY_ATTEMPT_FAILED = `
+				UPDATE webhook_deliveries SET response_status = $1, last_error = $2, next_attempt_at = $3, status = $4
+				WHERE id = $5
+		`
+		SQL_GET_WEBHOOK_DELIVERIES = `
+				SELECT id, webhook_id, event_type, event_key, payload, status, attempts, response_status, last_error, next_attempt_at, created_at, delivered_at FROM webhook_deliveries
+				WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+				ORDER BY id DESC
+				LIMIT $3
+		`
+		SQL_REPLAY_WEBHOOK_DELIVERY = `
+				UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1
+				WHERE id = $2 AND status = 'failed'
+				RETURNING id
+		`
+		SQL_REPLAY_FAILED_WEBHOOK_DELIVERIES = `
+				UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1
+				WHERE webhook_id = $2 AND status = 'failed'
+		`
+)
+
+// Retrieves all webhooks from the database. Secrets are not retrieved.
+func (tb *WebhooksTable) GetAll(ctx context.Context) (*models.Webhooks, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_ALL_WEBHOOKS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		w := &models.Webhook{}
+		if err := rows.Scan(&w.ID, &w.URL, &w.EventType, &w.Description, &w.CreatedBy, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return &models.Webhooks{Data: webhooks}, rows.Err()
+}
+
+// Inserts a webhook with the secret used to sign its deliveries into the database.
+func (tb *WebhooksTable) Insert(ctx context.Context, w *models.Webhook, secret string) (int, error) {
+	var id int
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_WEBHOOK, w.URL, w.EventType, secret, w.Description, w.CreatedBy, w.CreatedAt).Scan(&id)
+	return id, err
+}
+
+// Deletes a webhook and its deliveries from the database.
+// Returns sqldb.ErrNoRows if there is no webhook with the given ID.
+func (tb *WebhooksTable) Delete(ctx context.Context, id int) error {
+	var deleted int
+	return tb.DB.QueryRow(ctx, SQL_DELETE_WEBHOOK, id).Scan(&deleted)
+}
+
+// Creates a pending delivery of an event for every webhook registered for its type. An
+// event that was already enqueued for a webhook, identified by its key, is not enqueued again.
+func (tb *WebhookDeliveriesTable) Enqueue(ctx context.Context, eventType string, eventKey string, payload []byte) error {
+	_, err := tb.DB.Exec(ctx, SQL_ENQUEUE_WEBHOOK_DELIVERIES, eventType, eventKey, string(payload), time.Now())
+	return err
+}
+
+// Claims up to limit deliveries that are due, oldest first, counting an attempt for each
+// and holding them off until leaseUntil, so no other run attempts them in the meantime.
+func (tb *WebhookDeliveriesTable) ClaimDue(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]*models.DueDelivery, error) {
+	rows, err := tb.DB.Query(ctx, SQL_CLAIM_DUE_WEBHOOK_DELIVERIES, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*models.DueDelivery
+	for rows.Next() {
+		dd := &models.DueDelivery{Delivery: &models.Delivery{}}
+		d := dd.Delivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.EventKey, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt, &dd.URL, &dd.Secret); err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		due = append(due, dd)
+	}
+	return due, rows.Err()
+}
+
+// Marks a delivery as delivered, recording the HTTP status of the response.
+func (tb *WebhookDeliveriesTable) MarkDelivered(ctx context.Context, id int, responseStatus int, now time.Time) error {
+	_, err := tb.DB.Exec(ctx, SQL_MARK_WEBHOOK_DELIVERY_DELIVERED, responseStatus, now, id)
+	return err
+}
+
+// Records a failed attempt to deliver an event. The delivery is retried at nextAttemptAt,
+// or marked as failed if failed is true.
+func (tb *WebhookDeliveriesTable) MarkAttemptFailed(ctx context.Context, id int, responseStatus int, lastError string, nextAttemptAt time.Time, failed bool) error {
+	status := models.DeliveryStatusPending
+	if failed {
+		status = models.DeliveryStatusFailed
+	}
+	_, err := tb.DB.Exec(ctx, SQL_MARK_WEBHOOK_DELIVERY_ATTEMPT_FAILED, responseStatus, lastError, nextAttemptAt, status, id)
+	return err
+}
+
+// Retrieves up to limit deliveries of a webhook, newest first, only those in the given state if one is given.
+func (tb *WebhookDeliveriesTable) GetByWebhook(ctx context.Context, webhookId int, status string, limit int) (*models.Deliveries, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_WEBHOOK_DELIVERIES, webhookId, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.Delivery
+	for rows.Next() {
+		d := &models.Delivery{}
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.EventKey, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	return &models.Deliveries{Data: deliveries}, rows.Err()
+}
+
+// Moves a failed delivery back to pending, to be attempted on the next run of the
+// delivery job. Returns sqldb.ErrNoRows if there is no failed delivery with the given ID.
+func (tb *WebhookDeliveriesTable) Replay(ctx context.Context, id int) error {
+	var replayed int
+	return tb.DB.QueryRow(ctx, SQL_REPLAY_WEBHOOK_DELIVERY, time.Now(), id).Scan(&replayed)
+}
+
+// Moves all failed deliveries of a webhook back to pending. Returns the number of deliveries replayed.
+func (tb *WebhookDeliveriesTable) ReplayFailed(ctx context.Context, webhookId int) (int, error) {
+	r, err := tb.DB.Exec(ctx, SQL_REPLAY_FAILED_WEBHOOK_DELIVERIES, time.Now(), webhookId)
+	if err != nil {
+		return 0, err
+	}
+	return int(r.RowsAffected()), nil
+}
@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types a webhook may be registered for.
+const (
+	EventOrderCreated       = "order.created"        // an order was placed
+	EventOrderStatusChanged = "order.status_changed" // an order moved to a new status
+)
+
+// Delivery states of a webhook delivery. A delivery is pending until the endpoint accepts
+// it, and failed once MaxAttempts attempts have failed; failed deliveries may be replayed.
+const (
+	DeliveryStatusPending   = "pending"   // waiting to be delivered
+	DeliveryStatusDelivered = "delivered" // accepted by the endpoint with a 2xx response
+	DeliveryStatusFailed    = "failed"    // gave up after MaxAttempts attempts
+)
+
+// MaxAttempts is the number of attempts made to deliver an event before the delivery is marked as failed.
+const MaxAttempts = 12
+
+// Webhook represents an endpoint of an external integrator that is sent events of one type.
+type Webhook struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`         // endpoint the events are POSTed to
+	EventType   string    `json:"eventType"`   // one of the event types
+	Description string    `json:"description"` // e.g. the name of the integrator
+	CreatedBy   string    `json:"createdBy"`   // ID of the user who registered the webhook
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Webhooks represents a collection of webhooks.
+type Webhooks struct {
+	Data []*Webhook `json:"data"`
+}
+
+// WebhookRequestParams represents the parameters required to register a webhook.
+type WebhookRequestParams struct {
+	URL         string `json:"url"`
+	EventType   string `json:"eventType"`
+	Description string `json:"description"`
+}
+
+// RegisteredWebhook represents a newly registered webhook. The secret used to sign its
+// deliveries is only returned once.
+type RegisteredWebhook struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret"`
+}
+
+// Delivery represents the delivery of an event to a webhook.
+type Delivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhookId"`
+	EventType      string          `json:"eventType"`
+	EventKey       string          `json:"eventKey"`       // identifies the delivered event
+	Payload        json.RawMessage `json:"payload"`        // JSON body POSTed to the webhook
+	Status         string          `json:"status"`         // one of the delivery states
+	Attempts       int             `json:"attempts"`       // number of attempts made
+	ResponseStatus int             `json:"responseStatus"` // HTTP status of the last response; 0 if none was received
+	LastError      string          `json:"lastError"`      // error of the last failed attempt, if any
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`  // earliest time of the next attempt
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`    // when the event was delivered; nil until then
+}
+
+// Deliveries represents a collection of webhook deliveries.
+type Deliveries struct {
+	Data []*Delivery `json:"data"`
+}
+
+// DeliveryListParams represents the query parameters for listing the deliveries of a webhook.
+type DeliveryListParams struct {
+	Status string `query:"status"` // only deliveries in this state, if given
+	Limit  int    `query:"limit"`  // maximum number of deliveries, newest first; defaults to 50
+}
+
+// DueDelivery represents a delivery due to be attempted, with the endpoint and secret of its webhook.
+type DueDelivery struct {
+	Delivery *Delivery
+	URL      string
+	Secret   string
+}
+
+// EventEnvelope represents the JSON body POSTed to a webhook.
+type EventEnvelope struct {
+	Type       string    `json:"type"`       // one of the event types
+	Key        string    `json:"key"`        // identifies the event; the same for every attempt and replay
+	OccurredAt time.Time `json:"occurredAt"` // when the event occurred
+	Data       any       `json:"data"`       // the event
+}
+
+// ReplayReturn represents the result of replaying failed deliveries.
+type ReplayReturn struct {
+	Replayed int `json:"replayed"` // number of deliveries moved back to pending
+}
@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	models "encore.app/webhooks/models"
+)
+
+// Default and maximum number of deliveries returned when listing the deliveries of a webhook.
+const (
+	defaultDeliveryListLimit = 50
+	maxDeliveryListLimit     = 500
+)
+
+// Bounds of the delay between attempts to deliver an event.
+const (
+	minRetryDelay = time.Minute
+	maxRetryDelay = 6 * time.Hour
+)
+
+// ValidateWebhookRequestParams checks that a webhook has an absolute http(s) URL and a known event type.
+func ValidateWebhookRequestParams(p *models.WebhookRequestParams) error {
+	if p == nil {
+		return errors.New("empty webhook request")
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	if !IsValidEventType(p.EventType) {
+		return errors.New("invalid event type")
+	}
+	return nil
+}
+
+// IsValidEventType reports whether eventType is one of the event types webhooks may be registered for.
+func IsValidEventType(eventType string) bool {
+	return eventType == models.EventOrderCreated || eventType == models.EventOrderStatusChanged
+}
+
+// ValidateDeliveryListParams checks the delivery list parameters and applies the default limit.
+func ValidateDeliveryListParams(p *models.DeliveryListParams) error {
+	if p.Status != "" && p.Status != models.DeliveryStatusPending && p.Status != models.DeliveryStatusDelivered && p.Status != models.DeliveryStatusFailed {
+		return errors.New("invalid status")
+	}
+	if p.Limit < 0 || p.Limit > maxDeliveryListLimit {
+		return errors.New("limit must be between 1 and " + strconv.Itoa(maxDeliveryListLimit))
+	}
+	if p.Limit == 0 {
+		p.Limit = defaultDeliveryListLimit
+	}
+	return nil
+}
+
+// GenerateWebhookSecret generates a new secret of the form "whsec_<secret>" used to sign deliveries.
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// SignPayload returns the signature of a delivery: the hex-encoded HMAC-SHA256, keyed with
+// the webhook secret, of the Unix timestamp of the attempt, a ".", and the payload.
+// Receivers recompute it to check the delivery came from Plamatio and was not altered.
+func SignPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay returns how long to wait before the next attempt to deliver an event that
+// has failed the given number of times. The delay doubles with each attempt.
+func RetryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,24 @@
+package wishlist
+
+import (
+	"context"
+	"time"
+
+	cartmodels "encore.app/cart/models"
+	outboxdb "encore.app/outbox/db"
+	"encore.dev/storage/sqldb"
+)
+
+// ------------------------------------------------------
+// Setup Events
+
+// OutboxTable instance, used to publish cart events.
+var OutboxTable = &outboxdb.OutboxTable{DB: PlamatioDB}
+
+// enqueueCartItemAddedTx adds a cart-changed event for the cart item added when a wishlist
+// item is moved to the cart to the outbox as part of the given transaction. The outbox
+// relay publishes it once the transaction is committed.
+func enqueueCartItemAddedTx(ctx context.Context, tx *sqldb.Tx, item *cartmodels.CartItem) error {
+	event := &cartmodels.CartChangedEvent{Action: cartmodels.CartItemsAdded, UserID: item.UserID, Items: []*cartmodels.CartItem{item}, OccurredAt: time.Now()}
+	return OutboxTable.InsertTx(ctx, tx, "cart-changed", event)
+}
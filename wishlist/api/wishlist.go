@@ -0,0 +1,307 @@
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cart "encore.app/cart/api"
+	cartdb "encore.app/cart/db"
+	cartmodels "encore.app/cart/models"
+	coreutils "encore.app/core/utils"
+	productsdb "encore.app/products/db"
+	db "encore.app/wishlist/db"
+	models "encore.app/wishlist/models"
+	utils "encore.app/wishlist/utils"
+	"encore.dev/beta/errs"
+	rlog "encore.dev/rlog"
+	"encore.dev/storage/cache"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+)
+
+// ------------------------------------------------------
+// Setup Database
+
+// Database instance for Plamatio Backend.
+var PlamatioDB = sqldb.Named("plamatio_db")
+
+// WishlistItemsTable instance.
+var WishlistItemsTable = &db.WishlistItemsTable{DB: PlamatioDB}
+
+// CartItemsTable instance, used to move wishlist items to the cart.
+var CartItemsTable = &cartdb.CartItemsTable{DB: PlamatioDB}
+
+// ProductsTB instance, used to check product stock.
+var ProductsTB = &productsdb.ProductsTB{DB: PlamatioDB}
+
+// ------------------------------------------------------
+// Setup Caching
+
+// WishlistCluster is the cache cluster for wishlist items.
+var WishlistCluster = cache.NewCluster("wishlist-cache-cluster", cache.ClusterConfig{
+    // Use LRU policy to evict keys when the cache reaches memory limit.
+    EvictionPolicy: cache.AllKeysLRU,
+})
+
+// Wishlist Item Cache Keyspace to store wishlist items data by ID.
+var WishlistItemCacheKeyspace = cache.NewStructKeyspace[int, models.WishlistItem](WishlistCluster, cache.KeyspaceConfig{
+	KeyPattern:    "wishlist-item-cache/:key",
+	DefaultExpiry: cache.ExpireIn(2 * time.Hour),
+})
+
+// Wishlist Items Cache Keyspace to store all wishlist items for a user.
+var WishlistItemsCacheKeyspace = cache.NewStructKeyspace[string, models.WishlistItems](WishlistCluster, cache.KeyspaceConfig{
+	KeyPattern:    "user-wishlist-items-cache/:key",
+	DefaultExpiry: cache.ExpireIn(2 * time.Hour),
+})
+
+// ------------------------------------------------------
+// Setup API
+
+/*
+Primary endpoints for the wishlist:
+
+- GET: /wishlist/get/:id
+- GET: /wishlist/all/:user_id
+- POST: /wishlist/add
+- DELETE: /wishlist/delete/:id/user/:user_id
+- POST: /wishlist/move/:id
+*/
+
+// GET: /wishlist/get/:id
+// Retrieves the wishlist item from the database with the given ID.
+//encore:api auth method=GET path=/wishlist/get/:id
+func GetWishlistItem(ctx context.Context, id int) (*models.WishlistItem, error) {
+	// First, try retrieving the wishlist item from cache if it exists.
+	c, err := WishlistItemCacheKeyspace.Get(ctx, id)
+	// if wishlist item is found (i.e., no error), return it if the caller owns it
+	if err == nil {
+		if err := coreutils.RequireUser(c.UserID); err != nil {
+			return nil, err
+		}
+		return &c, nil
+	}
+	// If the wishlist item is not found in cache, retrieve it from the database.
+	r, err := WishlistItemsTable.GetWishlistItem(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, wishlistItemNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the caller owns the wishlist item.
+	if err := coreutils.RequireUser(r.UserID); err != nil {
+		return nil, err
+	}
+	// Fire go routine to cache the wishlist item.
+	go func() {
+		// Cache the wishlist item.
+		if err := WishlistItemCacheKeyspace.Set(ctx, id, *r); err != nil {
+			// log error
+			rlog.Error("Error caching wishlist item", err)
+		}
+	}()
+	// Return the wishlist item.
+	return r, nil
+}
+
+// GET: /wishlist/all/:user_id
+// Retrieves all wishlist items for a user from the database, most recently saved first.
+//encore:api auth method=GET path=/wishlist/all/:user_id
+func GetWishlistItems(ctx context.Context, user_id string) (*models.WishlistItems, error) {
+	// Confirm the caller owns the wishlist.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	// First, try retrieving all wishlist items for a user from cache if they exist.
+	c, err := WishlistItemsCacheKeyspace.Get(ctx, user_id)
+	// if wishlist items are found (i.e., no error), return them
+	if err == nil {
+		return &c, nil
+	}
+	// If the wishlist items are not found in cache, retrieve them from the database.
+	r, err := WishlistItemsTable.GetWishlistItemsByUser(ctx, user_id)
+	if err != nil {
+		return nil, err
+	}
+	// Fire go routine to cache the wishlist items.
+	go func() {
+		// Cache the wishlist items.
+		if err := WishlistItemsCacheKeyspace.Set(ctx, user_id, *r); err != nil {
+			// log error
+			rlog.Error("Error caching user wishlist items", err)
+		}
+	}()
+	// Return the wishlist items.
+	return r, nil
+}
+
+// POST: /wishlist/add
+// Saves a product to a user's wishlist. Saving a product already in the wishlist
+// returns the existing wishlist item.
+//encore:api auth method=POST path=/wishlist/add
+func AddWishlistItem(ctx context.Context, newWishlistItem *models.NewWishlistItem) (*models.WishlistItem, error) {
+	// validate data
+	if err := utils.ValidateNewWishlistItem(newWishlistItem); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Confirm the caller owns the wishlist.
+	if err := coreutils.RequireUser(newWishlistItem.UserID); err != nil {
+		return nil, err
+	}
+	// Insert the wishlist item into the database.
+	r, err := WishlistItemsTable.InsertWishlistItem(ctx, newWishlistItem)
+	if sqldb.ErrCode(err) == sqlerr.ForeignKeyViolation {
+		return nil, &errs.Error{
+			Code:    errs.NotFound,
+			Message: fmt.Sprintf("product %d not found", newWishlistItem.ProductID),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the cache for the user's wishlist items.
+	go invalidateWishlistCache(ctx, 0, newWishlistItem.UserID)
+
+	return r, nil
+}
+
+// DELETE: /wishlist/delete/:id/user/:user_id
+// Removes a wishlist item from the database.
+//encore:api auth method=DELETE path=/wishlist/delete/:id/user/:user_id
+func DeleteWishlistItem(ctx context.Context, id int, user_id string) (*models.WishlistChangeRequestReturn, error) {
+	// Confirm the caller owns the wishlist item.
+	if err := coreutils.RequireUser(user_id); err != nil {
+		return nil, err
+	}
+	current, err := WishlistItemsTable.GetWishlistItem(ctx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, wishlistItemNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := coreutils.RequireUser(current.UserID); err != nil {
+		return nil, err
+	}
+	// Delete the wishlist item from the database.
+	if err := WishlistItemsTable.DeleteWishlistItem(ctx, id); err != nil {
+		return nil, err
+	}
+	// Fire go routine to invalidate the cache for the wishlist item and the user's wishlist items.
+	go invalidateWishlistCache(ctx, id, current.UserID)
+
+	return &models.WishlistChangeRequestReturn{WishlistItemID: id}, nil
+}
+
+// POST: /wishlist/move/:id
+// Moves a wishlist item to the user's cart: the product is added to the cart and
+// removed from the wishlist in a single database transaction.
+//encore:api auth method=POST path=/wishlist/move/:id
+func MoveToCart(ctx context.Context, id int, p *models.MoveToCartParams) (*cartmodels.CartItem, error) {
+	// validate data
+	if err := utils.ValidateMoveToCartParams(p); err != nil {
+		return nil, &errs.Error{
+			Code:    errs.InvalidArgument,
+			Message: err.Error(),
+		}
+	}
+	// Start the transaction.
+	tx, err := PlamatioDB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Retrieve (and lock) the wishlist item.
+	wi, err := WishlistItemsTable.GetWishlistItemForUpdateTx(ctx, tx, id)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, wishlistItemNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Confirm the caller owns the wishlist item.
+	if err := coreutils.RequireUser(wi.UserID); err != nil {
+		return nil, err
+	}
+	// Confirm there is enough stock for the requested quantity, together with the units of
+	// the product already in the cart.
+	stock, err := ProductsTB.GetStockTx(ctx, tx, wi.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	cartItems, err := CartItemsTable.GetCartItemsByUserTx(ctx, tx, wi.UserID)
+	if err != nil {
+		return nil, err
+	}
+	requested := p.Quantity
+	for _, ci := range cartItems.Data {
+		if ci.ProductID == wi.ProductID {
+			requested += ci.Quantity
+		}
+	}
+	if requested > stock {
+		return nil, &errs.Error{
+			Code:    errs.FailedPrecondition,
+			Message: fmt.Sprintf("insufficient stock for product %d: requested %d, available %d", wi.ProductID, requested, stock),
+		}
+	}
+	// Add the product to the cart and remove it from the wishlist.
+	ci, err := CartItemsTable.InsertCartItemTx(ctx, tx, wi.ProductID, p.Quantity, wi.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := WishlistItemsTable.DeleteWishlistItemTx(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	// Record the added cart item in the outbox.
+	if err := enqueueCartItemAddedTx(ctx, tx, ci); err != nil {
+		return nil, err
+	}
+	// Commit the transaction.
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Fire go routine to invalidate the cache for the user's wishlist and cart items.
+	go func() {
+		invalidateWishlistCache(ctx, id, wi.UserID)
+		// Invalidate the cache for the user's cart items.
+		if err := cart.InvalidateCartCache(ctx, wi.UserID); err != nil {
+			// log error
+			rlog.Error("Error deleting user cart items cache", err)
+		}
+	}()
+
+	return ci, nil
+}
+
+// invalidateWishlistCache removes the wishlist item (if id is set) and the user's wishlist items from cache.
+func invalidateWishlistCache(ctx context.Context, id int, userID string) {
+	// Invalidate the cache for the wishlist item.
+	if id > 0 {
+		if _, err := WishlistItemCacheKeyspace.Delete(ctx, id); err != nil {
+			// log error
+			rlog.Error("Error deleting wishlist item cache", err)
+		}
+	}
+	// Invalidate the cache for the user's wishlist items.
+	if _, err := WishlistItemsCacheKeyspace.Delete(ctx, userID); err != nil {
+		// log error
+		rlog.Error("Error deleting user wishlist items cache", err)
+	}
+}
+
+// wishlistItemNotFound returns a NotFound error for the wishlist item with the given ID.
+func wishlistItemNotFound(id int) error {
+	return &errs.Error{
+		Code:    errs.NotFound,
+		Message: fmt.Sprintf("wishlist item %d not found", id),
+	}
+}
@@ -0,0 +1,103 @@
+package wishlist
+
+import (
+	"context"
+	"errors"
+
+	models "encore.app/wishlist/models"
+	utils "encore.app/wishlist/utils"
+	"encore.dev/storage/sqldb"
+)
+
+type WishlistItemsTable struct {
+	DB *sqldb.Database
+}
+
+const (
+		SQL_GET_WISHLIST_ITEM = `
+				SELECT product_id, user_id, created_at FROM wishlist_items
+				WHERE id = $1
+		`
+		SQL_GET_WISHLIST_ITEMS_BY_USER = `
+				SELECT id, product_id, user_id, created_at FROM wishlist_items
+				WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC
+		`
+		SQL_INSERT_WISHLIST_ITEM = `
+				INSERT INTO wishlist_items (product_id, user_id) VALUES ($1, $2)
+				ON CONFLICT (user_id, product_id) DO UPDATE SET product_id = EXCLUDED.product_id
+				RETURNING id, created_at
+		`
+		SQL_DELETE_WISHLIST_ITEM = `
+				DELETE FROM wishlist_items WHERE id = $1
+		`
+		SQL_GET_WISHLIST_ITEM_FOR_UPDATE = `
+				SELECT product_id, user_id, created_at FROM wishlist_items
+				WHERE id = $1
+				FOR UPDATE
+		`
+)
+
+// Retrieves a wishlist item from the database.
+func (tb *WishlistItemsTable) GetWishlistItem(ctx context.Context, id int) (*models.WishlistItem, error) {
+	wi := &models.WishlistItem{ID: id}
+	err := tb.DB.QueryRow(ctx, SQL_GET_WISHLIST_ITEM, id).Scan(&wi.ProductID, &wi.UserID, &wi.CreatedAt)
+	return wi, err
+}
+
+// Retrieves all wishlist items for a user from the database, most recently saved first.
+func (tb *WishlistItemsTable) GetWishlistItemsByUser(ctx context.Context, userId string) (*models.WishlistItems, error) {
+	rows, err := tb.DB.Query(ctx, SQL_GET_WISHLIST_ITEMS_BY_USER, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wishlistItems []*models.WishlistItem
+	for rows.Next() {
+		wi := &models.WishlistItem{}
+		if err := rows.Scan(&wi.ID, &wi.ProductID, &wi.UserID, &wi.CreatedAt); err != nil {
+			return nil, err
+		}
+		wishlistItems = append(wishlistItems, wi)
+	}
+	return &models.WishlistItems{Data: wishlistItems}, nil
+}
+
+// Inserts a wishlist item into the database. Saving a product already in the
+// user's wishlist returns the existing wishlist item.
+func (tb *WishlistItemsTable) InsertWishlistItem(ctx context.Context, newWishlistItem *models.NewWishlistItem) (*models.WishlistItem, error) {
+	// validate wishlist item data
+	if err := utils.ValidateNewWishlistItem(newWishlistItem); err != nil {
+		return nil, err
+	}
+	wi := &models.WishlistItem{ProductID: newWishlistItem.ProductID, UserID: newWishlistItem.UserID}
+	err := tb.DB.QueryRow(ctx, SQL_INSERT_WISHLIST_ITEM, wi.ProductID, wi.UserID).Scan(&wi.ID, &wi.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return wi, nil
+}
+
+// Deletes a wishlist item from the database.
+func (tb *WishlistItemsTable) DeleteWishlistItem(ctx context.Context, id int) error {
+	// Validate ID
+	if id <= 0 {
+		return errors.New("invalid wishlist item ID")
+	}
+	_, err := tb.DB.Exec(ctx, SQL_DELETE_WISHLIST_ITEM, id)
+	return err
+}
+
+// Retrieves (and locks) a wishlist item as part of the given transaction.
+func (tb *WishlistItemsTable) GetWishlistItemForUpdateTx(ctx context.Context, tx *sqldb.Tx, id int) (*models.WishlistItem, error) {
+	wi := &models.WishlistItem{ID: id}
+	err := tx.QueryRow(ctx, SQL_GET_WISHLIST_ITEM_FOR_UPDATE, id).Scan(&wi.ProductID, &wi.UserID, &wi.CreatedAt)
+	return wi, err
+}
+
+// Deletes a wishlist item as part of the given transaction.
+func (tb *WishlistItemsTable) DeleteWishlistItemTx(ctx context.Context, tx *sqldb.Tx, id int) error {
+	_, err := tx.Exec(ctx, SQL_DELETE_WISHLIST_ITEM, id)
+	return err
+}
@@ -0,0 +1,32 @@
+package wishlist
+
+import "time"
+
+// WishlistItem represents a product a user saved for later.
+type WishlistItem struct {
+	ID        int       `json:"id"`         // ID is the unique identifier of the wishlist item.
+	ProductID int       `json:"product_id"` // ProductID is the identifier of the saved product.
+	UserID    string    `json:"user_id"`    // UserID is the identifier of the user who owns the wishlist item.
+	CreatedAt time.Time `json:"created_at"` // CreatedAt is the time the product was saved.
+}
+
+// WishlistItems represents a collection of wishlist items.
+type WishlistItems struct {
+	Data []*WishlistItem `json:"data"` // Data is the list of wishlist items.
+}
+
+// NewWishlistItem represents a product to be saved to a user's wishlist.
+type NewWishlistItem struct {
+	ProductID int    `json:"product_id"` // ProductID is the identifier of the product to be saved.
+	UserID    string `json:"user_id"`    // UserID is the identifier of the user who owns the wishlist.
+}
+
+// MoveToCartParams represents the request parameters for moving a wishlist item to the cart.
+type MoveToCartParams struct {
+	Quantity int `json:"quantity"` // Quantity is the number of items to add to the cart; defaults to 1.
+}
+
+// Return type for wishlist mutation requests.
+type WishlistChangeRequestReturn struct {
+	WishlistItemID int `json:"id"` // WishlistItemID is the identifier of the wishlist item.
+}
@@ -0,0 +1,30 @@
+package wishlist
+
+import (
+	"errors"
+
+	models "encore.app/wishlist/models"
+)
+
+func ValidateNewWishlistItem(newWishlistItem *models.NewWishlistItem) error {
+	if newWishlistItem == nil {
+		return errors.New("empty new wishlist item object")
+	}
+	if newWishlistItem.ProductID <= 0 {
+		return errors.New("invalid product ID")
+	}
+	if newWishlistItem.UserID == "" {
+		return errors.New("invalid user ID")
+	}
+	return nil
+}
+
+func ValidateMoveToCartParams(p *models.MoveToCartParams) error {
+	if p.Quantity == 0 {
+		p.Quantity = 1
+	}
+	if p.Quantity < 0 {
+		return errors.New("invalid quantity, cannot be less than 1")
+	}
+	return nil
+}